@@ -1,5 +1,161 @@
 package main
 
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// runFunc - run command with positional arguments
+type runFunc func(ctx context.Context, client pb.ProductsServiceClient, args []string) error
+
+// command - subcommand of client
+type command struct {
+	name    string
+	args    string
+	summary string
+	// define flags of command and return function which runs it
+	define func(fs *flag.FlagSet) runFunc
+}
+
+// commands - all subcommands except completion, which doesn't connect to server
+var commands = []command{
+	{name: "fetch", args: "URL", summary: "download CSV file of products by server and save their prices", define: defineFetch},
+	{name: "list", summary: "print page of products or all products", define: defineList},
+	{name: "get", args: "ID|NAME", summary: "print product with prices of its sources", define: defineGet},
+	{name: "history", args: "ID|NAME", summary: "print history of price of product", define: defineHistory},
+	{name: "watch", args: "ID|NAME", summary: "print changes of product until interrupted", define: defineWatch},
+}
+
+func init() {
+	log.SetPrefix("client: ")
+	log.SetFlags(0)
+}
+
+// command-line client of ProductsService
 func main() {
-	
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage())
+		os.Exit(2)
+	}
+
+	name, arguments := os.Args[1], os.Args[2:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage())
+		return
+	case "completion":
+		if err := completion(os.Stdout, arguments); err != nil {
+			log.Println(err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		log.Printf("unknown command %q", name)
+		fmt.Fprint(os.Stderr, usage())
+		os.Exit(2)
+	}
+
+	fs, conn, run := newFlagSet(cmd)
+	args, err := parseArgs(fs, arguments)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cc, err := conn.dial(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cc.Close()
+
+	if err := run(ctx, pb.NewProductsServiceClient(cc), args); err != nil {
+		printError(os.Stderr, err)
+		stop()
+		_ = cc.Close()
+		os.Exit(1)
+	}
+}
+
+// newFlagSet - return flags of command with connection flags and function which runs it
+func newFlagSet(cmd command) (*flag.FlagSet, *connOptions, runFunc) {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	conn := &connOptions{}
+	conn.register(fs)
+	run := cmd.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s\n  %s\n\nflags:\n", strings.TrimSpace(program()+" "+cmd.name+" [flags] "+cmd.args), cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs, conn, run
+}
+
+// parseArgs - parse flags which can follow positional arguments, like `fetch URL -wait`
+// return positional arguments
+func parseArgs(fs *flag.FlagSet, arguments []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(arguments); err != nil {
+			return nil, err
+		}
+		arguments = fs.Args()
+		if len(arguments) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, arguments[0])
+		arguments = arguments[1:]
+	}
+}
+
+// exactArgs - return error with usage if number of positional arguments differs from n
+func exactArgs(args []string, n int, usage string) error {
+	if len(args) != n {
+		return fmt.Errorf("usage: %s %s", program(), usage)
+	}
+	return nil
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// usage - return list of commands
+func usage() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "usage: %s <command> [flags] [arguments]\n\ncommands:\n", program())
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-16s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintf(&b, "  %-16s %s\n", "completion", "print bash or zsh completion script")
+	fmt.Fprintf(&b, "\nrun `%s <command> -h` for flags of command\n", program())
+	return b.String()
+}
+
+// program - name of binary, it is used in usage and completion scripts
+func program() string {
+	name := os.Args[0]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
 }
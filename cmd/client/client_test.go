@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	fetch, ok := findCommand("fetch")
+	require.True(t, ok)
+
+	tests := []struct {
+		name         string
+		arguments    []string
+		want         []string
+		fullSnapshot bool
+		insecure     bool
+		wantErr      bool
+		wantHelp     bool
+	}{
+		{name: "positional only", arguments: []string{"http://csv/products.csv"}, want: []string{"http://csv/products.csv"}},
+		{name: "flags before argument", arguments: []string{"-full-snapshot", "-insecure", "http://csv/products.csv"}, want: []string{"http://csv/products.csv"}, fullSnapshot: true, insecure: true},
+		{name: "flags after argument", arguments: []string{"http://csv/products.csv", "-full-snapshot", "-insecure"}, want: []string{"http://csv/products.csv"}, fullSnapshot: true, insecure: true},
+		{name: "several arguments", arguments: []string{"a", "-full-snapshot", "b"}, want: []string{"a", "b"}, fullSnapshot: true},
+		{name: "no arguments", arguments: nil, want: nil},
+		{name: "unknown flag", arguments: []string{"http://csv/products.csv", "-verbose"}, wantErr: true},
+		{name: "invalid format", arguments: []string{"-o", "csv", "http://csv/products.csv"}, wantErr: true},
+		{name: "help", arguments: []string{"-h"}, wantErr: true, wantHelp: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, conn, _ := newFlagSet(fetch)
+			fs.SetOutput(io.Discard)
+
+			args, err := parseArgs(fs, tt.arguments)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantHelp, errors.Is(err, flag.ErrHelp))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, args)
+			assert.Equal(t, tt.fullSnapshot, fs.Lookup("full-snapshot").Value.(flag.Getter).Get())
+			assert.Equal(t, tt.insecure, conn.insecure)
+		})
+	}
+}
+
+func TestExactArgs(t *testing.T) {
+	assert.NoError(t, exactArgs([]string{"apple"}, 1, "get ID|NAME"))
+	assert.Error(t, exactArgs(nil, 1, "get ID|NAME"))
+	assert.Error(t, exactArgs([]string{"apple", "pear"}, 1, "get ID|NAME"))
+}
+
+func TestFindCommand(t *testing.T) {
+	for _, cmd := range commands {
+		found, ok := findCommand(cmd.name)
+		assert.True(t, ok, cmd.name)
+		assert.Equal(t, cmd.name, found.name)
+	}
+	_, ok := findCommand("completion")
+	assert.False(t, ok, "completion doesn't connect to server")
+	_, ok = findCommand("delete")
+	assert.False(t, ok)
+}
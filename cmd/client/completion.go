@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// characters which can't be in name of shell function
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// completion - write completion script of shell from arguments, commands and flags are taken
+// from definitions of commands, so script has to be regenerated after upgrade of client
+func completion(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s completion bash|zsh", program())
+	}
+
+	var script string
+	switch args[0] {
+	case "bash":
+		script = bashCompletion()
+	case "zsh":
+		// zsh runs bash completion functions by bashcompinit
+		script = "autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion()
+	default:
+		return fmt.Errorf("completion: unknown shell %q, bash and zsh are supported", args[0])
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// bashCompletion - return script which completes commands, their flags and values of some flags
+func bashCompletion() string {
+	name := program()
+	function := "_" + nonIdentifier.ReplaceAllString(name, "_") + "_complete"
+
+	names := []string{"completion", "help"}
+	var cases strings.Builder
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+		fs, _, _ := newFlagSet(cmd)
+		var flags []string
+		fs.VisitAll(func(f *flag.Flag) {
+			flags = append(flags, "-"+f.Name)
+		})
+		fmt.Fprintf(&cases, "\t%s) flags=%q ;;\n", cmd.name, strings.Join(flags, " "))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# completion of %s, load it with: source <(%s completion bash)\n", name, name)
+	fmt.Fprintf(&b, "%s() {\n", function)
+	b.WriteString(`	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" flags=""
+	if [ "$COMP_CWORD" -eq 1 ]; then
+`)
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString(`		return
+	fi
+	case "$prev" in
+	-o) COMPREPLY=($(compgen -W "table json csv" -- "$cur")); return ;;
+	-price-mode) COMPREPLY=($(compgen -W "latest lowest source" -- "$cur")); return ;;
+`)
+	fmt.Fprintf(&b, "\t-sort) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(sortFields, " "))
+	b.WriteString(`	-ca|-cert|-key) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+	esac
+	case "${COMP_WORDS[1]}" in
+	completion) COMPREPLY=($(compgen -W "bash zsh" -- "$cur")); return ;;
+`)
+	b.WriteString(cases.String())
+	b.WriteString(`	esac
+	COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+}
+`)
+	fmt.Fprintf(&b, "complete -F %s %s\n", function, name)
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	args := os.Args
+	os.Args = []string{"/usr/local/bin/products-client"}
+	defer func() { os.Args = args }()
+
+	tests := []struct {
+		name    string
+		args    []string
+		prefix  string
+		wantErr string
+	}{
+		{name: "bash", args: []string{"bash"}, prefix: "# completion of products-client"},
+		{name: "zsh", args: []string{"zsh"}, prefix: "autoload -U +X bashcompinit && bashcompinit\n# completion of products-client"},
+		{name: "unknown shell", args: []string{"fish"}, wantErr: `completion: unknown shell "fish", bash and zsh are supported`},
+		{name: "without shell", args: nil, wantErr: "usage: products-client completion bash|zsh"},
+		{name: "several shells", args: []string{"bash", "zsh"}, wantErr: "usage: products-client completion bash|zsh"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := completion(&b, tt.args)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				assert.Empty(t, b.String())
+				return
+			}
+			require.NoError(t, err)
+
+			script := b.String()
+			assert.True(t, strings.HasPrefix(script, tt.prefix), script)
+			// name of binary is turned into name of shell function
+			assert.Contains(t, script, "_products_client_complete() {")
+			assert.True(t, strings.HasSuffix(script, "complete -F _products_client_complete products-client\n"))
+		})
+	}
+}
+
+func TestBashCompletionCommands(t *testing.T) {
+	script := bashCompletion()
+
+	assert.Contains(t, script, `compgen -W "completion help fetch list get history watch"`)
+	for _, cmd := range commands {
+		var flags []string
+		fs, _, _ := newFlagSet(cmd)
+		fs.VisitAll(func(f *flag.Flag) {
+			flags = append(flags, "-"+f.Name)
+		})
+		// connection flags and flags of command
+		assert.Subset(t, flags, []string{"-address", "-insecure", "-token", "-api-key", "-o"}, "command %s", cmd.name)
+		assert.Contains(t, script, "\t"+cmd.name+`) flags="`+strings.Join(flags, " ")+`" ;;`)
+	}
+	assert.Contains(t, script, `-o) COMPREPLY=($(compgen -W "table json csv" -- "$cur"))`)
+	assert.Contains(t, script, `-sort) COMPREPLY=($(compgen -W "`+strings.Join(sortFields, " ")+`"`)
+}
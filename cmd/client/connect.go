@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"os"
+	"time"
+)
+
+// connOptions - connection flags, defaults of address and credentials are taken from environment
+type connOptions struct {
+	address     string
+	insecure    bool
+	caFile      string
+	certFile    string
+	keyFile     string
+	serverName  string
+	skipVerify  bool
+	token       string
+	apiKey      string
+	dialTimeout time.Duration
+}
+
+func (o *connOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.address, "address", envOr("PRODUCTS_ADDRESS", "localhost:50051"), "host:port of server, $PRODUCTS_ADDRESS")
+	fs.BoolVar(&o.insecure, "insecure", os.Getenv("PRODUCTS_INSECURE") == "true", "connect without TLS, token and API key can't be sent then, $PRODUCTS_INSECURE")
+	fs.StringVar(&o.caFile, "ca", os.Getenv("PRODUCTS_CA_FILE"), "PEM file with CA certificates of server instead of system ones, $PRODUCTS_CA_FILE")
+	fs.StringVar(&o.certFile, "cert", os.Getenv("PRODUCTS_CERT_FILE"), "PEM file with client certificate for mtls, $PRODUCTS_CERT_FILE")
+	fs.StringVar(&o.keyFile, "key", os.Getenv("PRODUCTS_KEY_FILE"), "PEM file with key of client certificate, $PRODUCTS_KEY_FILE")
+	fs.StringVar(&o.serverName, "server-name", "", "name of server in its certificate if it differs from host of address")
+	fs.BoolVar(&o.skipVerify, "insecure-skip-verify", false, "don't verify certificate of server")
+	fs.StringVar(&o.token, "token", os.Getenv("PRODUCTS_TOKEN"), "JWT sent as Bearer token, $PRODUCTS_TOKEN")
+	fs.StringVar(&o.apiKey, "api-key", os.Getenv("PRODUCTS_API_KEY"), "API key, $PRODUCTS_API_KEY")
+	fs.DurationVar(&o.dialTimeout, "dial-timeout", 10*time.Second, "time for connecting to server")
+}
+
+// dial - connect to server with TLS, without it only with -insecure,
+// calls carry token and API key if they are set
+func (o *connOptions) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	creds, err := o.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds), grpc.WithBlock()}
+	if o.token != "" || o.apiKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&callCredentials{token: o.token, apiKey: o.apiKey}))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.dialTimeout)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, o.address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %v", o.address, err)
+	}
+	return cc, nil
+}
+
+// transportCredentials - return TLS credentials, credentials without TLS only for -insecure
+// without certificates, token and API key
+func (o *connOptions) transportCredentials() (credentials.TransportCredentials, error) {
+	if o.insecure {
+		if o.caFile != "" || o.certFile != "" || o.keyFile != "" {
+			return nil, fmt.Errorf("-insecure can't be used with -ca, -cert and -key")
+		}
+		if o.token != "" || o.apiKey != "" {
+			return nil, fmt.Errorf("token and API key are sent only over TLS, remove -insecure")
+		}
+		return insecure.NewCredentials(), nil
+	}
+
+	cfg, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+func (o *connOptions) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.serverName,
+		InsecureSkipVerify: o.skipVerify,
+	}
+	if o.caFile != "" {
+		data, err := os.ReadFile(o.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("CA file: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA file %s has no certificates", o.caFile)
+		}
+	}
+	if o.certFile != "" || o.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// callCredentials - add authorization and x-api-key metadata to calls
+type callCredentials struct {
+	token  string
+	apiKey string
+}
+
+func (c *callCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md := make(map[string]string, 2)
+	if c.token != "" {
+		md["authorization"] = "Bearer " + c.token
+	}
+	if c.apiKey != "" {
+		md["x-api-key"] = c.apiKey
+	}
+	return md, nil
+}
+
+// RequireTransportSecurity - token and API key are never sent without TLS
+func (c *callCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+func envOr(key, value string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return value
+}
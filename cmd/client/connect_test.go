@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTransportCredentials(t *testing.T) {
+	dir := t.TempDir()
+
+	// certificate of test server is used as CA
+	server := httptest.NewTLSServer(nil)
+	server.Close()
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+	emptyFile := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(emptyFile, []byte("not a certificate"), 0600))
+
+	tests := []struct {
+		name     string
+		opts     connOptions
+		protocol string
+		wantErr  string
+	}{
+		{name: "TLS by default", opts: connOptions{}, protocol: "tls"},
+		{name: "TLS with token and API key", opts: connOptions{token: "jwt", apiKey: "key"}, protocol: "tls"},
+		{name: "TLS with CA file", opts: connOptions{caFile: caFile}, protocol: "tls"},
+		{name: "TLS without verification", opts: connOptions{skipVerify: true}, protocol: "tls"},
+		{name: "insecure", opts: connOptions{insecure: true}, protocol: "insecure"},
+		{name: "insecure with token", opts: connOptions{insecure: true, token: "jwt"}, wantErr: "token and API key are sent only over TLS, remove -insecure"},
+		{name: "insecure with API key", opts: connOptions{insecure: true, apiKey: "key"}, wantErr: "token and API key are sent only over TLS, remove -insecure"},
+		{name: "insecure with CA file", opts: connOptions{insecure: true, caFile: caFile}, wantErr: "-insecure can't be used with -ca, -cert and -key"},
+		{name: "insecure with client certificate", opts: connOptions{insecure: true, certFile: "cert.pem", keyFile: "key.pem"}, wantErr: "-insecure can't be used with -ca, -cert and -key"},
+		{name: "missing CA file", opts: connOptions{caFile: filepath.Join(dir, "missing.pem")}, wantErr: "CA file: "},
+		{name: "CA file without certificates", opts: connOptions{caFile: emptyFile}, wantErr: "has no certificates"},
+		{name: "missing client certificate", opts: connOptions{certFile: filepath.Join(dir, "cert.pem"), keyFile: filepath.Join(dir, "key.pem")}, wantErr: "client certificate: "},
+		{name: "client key without certificate", opts: connOptions{keyFile: filepath.Join(dir, "key.pem")}, wantErr: "client certificate: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := tt.opts.transportCredentials()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.protocol, creds.Info().SecurityProtocol)
+		})
+	}
+}
+
+func TestDialRejectsCredentialsWithoutTLS(t *testing.T) {
+	opts := &connOptions{address: "localhost:1", insecure: true, token: "jwt", dialTimeout: time.Second}
+	_, err := opts.dial(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sent only over TLS")
+}
+
+func TestConnFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want connOptions
+	}{
+		{
+			name: "defaults",
+			want: connOptions{address: "localhost:50051", dialTimeout: 10 * time.Second},
+		},
+		{
+			name: "environment",
+			env:  map[string]string{"PRODUCTS_ADDRESS": "products:443", "PRODUCTS_INSECURE": "true", "PRODUCTS_TOKEN": "jwt", "PRODUCTS_API_KEY": "key"},
+			want: connOptions{address: "products:443", insecure: true, token: "jwt", apiKey: "key", dialTimeout: 10 * time.Second},
+		},
+		{
+			name: "flags override environment",
+			env:  map[string]string{"PRODUCTS_ADDRESS": "products:443", "PRODUCTS_INSECURE": "true"},
+			args: []string{"-address", "localhost:9000", "-insecure=false", "-ca", "ca.pem", "-server-name", "products", "-dial-timeout", "3s"},
+			want: connOptions{address: "localhost:9000", caFile: "ca.pem", serverName: "products", dialTimeout: 3 * time.Second},
+		},
+		{
+			name: "only true enables insecure",
+			env:  map[string]string{"PRODUCTS_INSECURE": "1"},
+			want: connOptions{address: "localhost:50051", dialTimeout: 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PRODUCTS_ADDRESS", "PRODUCTS_INSECURE", "PRODUCTS_CA_FILE", "PRODUCTS_CERT_FILE", "PRODUCTS_KEY_FILE", "PRODUCTS_TOKEN", "PRODUCTS_API_KEY"} {
+				t.Setenv(key, tt.env[key])
+				if _, ok := tt.env[key]; !ok {
+					os.Unsetenv(key)
+				}
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			var got connOptions
+			got.register(fs)
+			require.NoError(t, fs.Parse(tt.args))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCallCredentials(t *testing.T) {
+	tests := []struct {
+		name  string
+		creds callCredentials
+		want  map[string]string
+	}{
+		{name: "token", creds: callCredentials{token: "jwt"}, want: map[string]string{"authorization": "Bearer jwt"}},
+		{name: "API key", creds: callCredentials{apiKey: "key"}, want: map[string]string{"x-api-key": "key"}},
+		{name: "both", creds: callCredentials{token: "jwt", apiKey: "key"}, want: map[string]string{"authorization": "Bearer jwt", "x-api-key": "key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := tt.creds.GetRequestMetadata(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, md)
+			assert.True(t, tt.creds.RequireTransportSecurity())
+		})
+	}
+}
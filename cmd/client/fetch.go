@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// interval of redrawing of elapsed time
+const elapsedInterval = 100 * time.Millisecond
+
+// defineFetch - fetch URL, with -wait rejected fetch is retried after time from RetryInfo of error,
+// e.g. when queue of fetches of server is full or server of the file is unavailable
+func defineFetch(fs *flag.FlagSet) runFunc {
+	source := fs.String("source", "", "source of prices, host of URL by default")
+	currency := fs.String("currency", "", "ISO 4217 currency of lines without the third column, default currency of server by default")
+	fullSnapshot := fs.Bool("full-snapshot", false, "file has all products of source, products missing in it are discontinued")
+	wait := fs.Bool("wait", false, "wait and retry while fetch is rejected with time after which it can be retried")
+	maxWait := fs.Duration("max-wait", 10*time.Minute, "maximum time of waiting with -wait")
+	timeout := fs.Duration("timeout", 0, "deadline of fetch, 0 means deadline of server")
+	quiet := fs.Bool("quiet", false, "don't show elapsed time of fetch")
+	format := newFormatFlag(formatTable, formatJSON)
+	fs.Var(format, "o", "output format: table or json")
+
+	return func(ctx context.Context, client pb.ProductsServiceClient, args []string) error {
+		if err := exactArgs(args, 1, "fetch [flags] URL"); err != nil {
+			return err
+		}
+		req := &pb.FetchRequest{
+			Url:          args[0],
+			SourceId:     *source,
+			Currency:     *currency,
+			FullSnapshot: *fullSnapshot,
+		}
+
+		waitUntil := time.Now().Add(*maxWait)
+		for {
+			resp, err := fetch(ctx, client, req, *timeout, !*quiet)
+			if err == nil {
+				return printFetch(resp, format.value)
+			}
+
+			delay, ok := retryDelay(err)
+			if !*wait || !ok || time.Now().Add(delay).After(waitUntil) {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s, retry in %s\n", status.Convert(err).Message(), delay.Round(time.Second))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// fetch - call Fetch, it returns when the file is processed, the service has no asynchronous fetches
+func fetch(ctx context.Context, client pb.ProductsServiceClient, req *pb.FetchRequest, timeout time.Duration, elapsed bool) (*pb.FetchResponse, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if elapsed {
+		defer showElapsed(os.Stderr, "fetching "+req.GetUrl())()
+	}
+	return client.Fetch(ctx, req)
+}
+
+func printFetch(resp *pb.FetchResponse, format string) error {
+	if format == formatJSON {
+		return writeJSON(os.Stdout, resp)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "created\t%d\n", resp.GetCreated())
+	fmt.Fprintf(tw, "updated\t%d\n", resp.GetUpdated())
+	fmt.Fprintf(tw, "restored\t%d\n", resp.GetRestored())
+	fmt.Fprintf(tw, "discontinued\t%d\n", resp.GetDiscontinued())
+	return tw.Flush()
+}
+
+// showElapsed - show spinner with time of running fetch on terminal until returned function is called,
+// it isn't progress, server doesn't report it
+func showElapsed(w *os.File, label string) func() {
+	if info, err := w.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	started := time.Now()
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(elapsedInterval)
+		defer ticker.Stop()
+
+		frames := `|/-\`
+		for i := 0; ; i++ {
+			fmt.Fprintf(w, "\r%c %s %s", frames[i%len(frames)], label, time.Since(started).Round(time.Second))
+			select {
+			case <-ticker.C:
+			case <-done:
+				// clear the line
+				fmt.Fprint(w, "\r\033[K")
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// retryDelay - return time from RetryInfo of status error, false if error has no RetryInfo
+func retryDelay(err error) (time.Duration, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"io"
+	"os"
+	"strings"
+)
+
+// fields which products can be sorted by, see ListRequest.order_by
+var sortFields = []string{"name", "price", "currency", "price_source", "updated", "price_updates", "revision"}
+
+// defineList - print one page of products or, with -all, all products streamed by StreamProducts
+func defineList(fs *flag.FlagSet) runFunc {
+	sortBy := fs.String("sort", "", "field[:asc|:desc] to sort by: "+strings.Join(sortFields, ", "))
+	pageSize := fs.Int("page-size", 20, "products on page, with -all size of batches read by server")
+	page := fs.Int("page", 0, "number of page")
+	pageToken := fs.String("page-token", "", "token of the next page from the previous page, used instead of -page")
+	all := fs.Bool("all", false, "print all products")
+	discontinued := fs.Bool("discontinued", false, "include discontinued products")
+	priceMode := fs.String("price-mode", "latest", "price of product: latest, lowest or source")
+	source := fs.String("source", "", "source of prices for -price-mode source")
+	currency := fs.String("currency", "", "ISO 4217 currency which prices are converted into")
+	format := newFormatFlag(formatTable, formatJSON, formatCSV)
+	fs.Var(format, "o", "output format: table, json or csv, with -all json is one product per line")
+
+	return func(ctx context.Context, client pb.ProductsServiceClient, args []string) error {
+		if err := exactArgs(args, 0, "list [flags]"); err != nil {
+			return err
+		}
+		orderBy, err := parseSort(*sortBy)
+		if err != nil {
+			return err
+		}
+		mode, ok := pb.PriceMode_value["PRICE_MODE_"+strings.ToUpper(*priceMode)]
+		if !ok {
+			return fmt.Errorf("price mode must be latest, lowest or source")
+		}
+		req := &pb.ListRequest{
+			OrderBy:             orderBy,
+			PageSize:            int32(*pageSize),
+			PageNumber:          int32(*page),
+			PageToken:           *pageToken,
+			IncludeDiscontinued: *discontinued,
+			PriceMode:           pb.PriceMode(mode),
+			Source:              *source,
+			Currency:            *currency,
+		}
+
+		if *all {
+			return streamProducts(ctx, client, req, format.value)
+		}
+		return listPage(ctx, client, req, format.value)
+	}
+}
+
+func listPage(ctx context.Context, client pb.ProductsServiceClient, req *pb.ListRequest, format string) error {
+	resp, err := client.List(ctx, req)
+	if err != nil {
+		return err
+	}
+	if format == formatJSON {
+		return writeJSON(os.Stdout, resp)
+	}
+
+	table := newProductTable(os.Stdout, format)
+	for _, p := range resp.GetProducts() {
+		table.write(p)
+	}
+	if err := table.flush(); err != nil {
+		return err
+	}
+	// stdout is kept for products, e.g. for CSV
+	if resp.GetNextPageToken() != "" {
+		fmt.Fprintf(os.Stderr, "next page: -page %d or -page-token %s\n", resp.GetNextPageNumber(), resp.GetNextPageToken())
+	}
+	return nil
+}
+
+func streamProducts(ctx context.Context, client pb.ProductsServiceClient, req *pb.ListRequest, format string) error {
+	stream, err := client.StreamProducts(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	var table *productTable
+	if format != formatJSON {
+		table = newProductTable(os.Stdout, format)
+	}
+	for {
+		p, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if table != nil {
+				_ = table.flush()
+			}
+			return err
+		}
+		if table == nil {
+			if err := writeJSONLine(os.Stdout, p); err != nil {
+				return err
+			}
+			continue
+		}
+		table.write(p)
+	}
+	if table != nil {
+		return table.flush()
+	}
+	return nil
+}
+
+// parseSort - take field[:asc|:desc] or -field for descending order
+// return order_by of ListRequest, empty value means order of server
+func parseSort(value string) (map[string]int32, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	field, direction := value, int32(1)
+	if strings.HasPrefix(field, "-") {
+		field, direction = field[1:], -1
+	}
+	if i := strings.LastIndex(field, ":"); i >= 0 {
+		switch strings.ToLower(field[i+1:]) {
+		case "asc":
+		case "desc":
+			direction = -1
+		default:
+			return nil, fmt.Errorf("sort %q: direction must be asc or desc", value)
+		}
+		field = field[:i]
+	}
+	return map[string]int32{field: direction}, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/mapping"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// messages are written with names of fields from products.proto like the gateway writes them
+var (
+	jsonOptions     = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true, Multiline: true}
+	jsonLineOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+)
+
+// formatFlag - value of -o flag
+type formatFlag struct {
+	value   string
+	allowed []string
+}
+
+func newFormatFlag(allowed ...string) *formatFlag {
+	return &formatFlag{value: allowed[0], allowed: allowed}
+}
+
+func (f *formatFlag) String() string {
+	return f.value
+}
+
+func (f *formatFlag) Set(value string) error {
+	for _, allowed := range f.allowed {
+		if value == allowed {
+			f.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("format must be one of %s", strings.Join(f.allowed, ", "))
+}
+
+// writeJSON - write message as indented JSON
+func writeJSON(w io.Writer, msg proto.Message) error {
+	data, err := jsonOptions.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// writeJSONLine - write message as one line of JSON, for streams of messages
+func writeJSONLine(w io.Writer, msg proto.Message) error {
+	data, err := jsonLineOptions.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// productTable - write products as columns aligned by tabs or as CSV with header
+type productTable struct {
+	tw *tabwriter.Writer
+	cw *csv.Writer
+}
+
+var productColumns = []string{"ID", "NAME", "PRICE", "CURRENCY", "SOURCE", "UPDATED", "UPDATES", "DISCONTINUED"}
+
+func newProductTable(w io.Writer, format string) *productTable {
+	t := &productTable{}
+	if format == formatCSV {
+		t.cw = csv.NewWriter(w)
+		header := make([]string, len(productColumns))
+		for i, column := range productColumns {
+			header[i] = strings.ToLower(column)
+		}
+		_ = t.cw.Write(header)
+		return t
+	}
+	t.tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(t.tw, strings.Join(productColumns, "\t"))
+	return t
+}
+
+func (t *productTable) write(p *pb.Product) {
+	amount, currency := money(p.GetPrice())
+	row := []string{
+		p.GetId(),
+		p.GetName(),
+		amount,
+		currency,
+		p.GetPriceSource(),
+		timestamp(p.GetUpdated()),
+		strconv.FormatUint(uint64(p.GetPriceUpdates()), 10),
+		timestamp(p.GetDiscontinued()),
+	}
+	if t.cw != nil {
+		_ = t.cw.Write(row)
+		return
+	}
+	fmt.Fprintln(t.tw, strings.Join(row, "\t"))
+}
+
+func (t *productTable) flush() error {
+	if t.cw != nil {
+		t.cw.Flush()
+		return t.cw.Error()
+	}
+	return t.tw.Flush()
+}
+
+// money - return exact amount and currency of price, empty strings for product without price
+func money(m *pb.Money) (string, string) {
+	if m == nil {
+		return "", ""
+	}
+	amount, currency, err := mapping.MoneyFromGrpc(m)
+	if err != nil {
+		return "invalid", m.GetCurrencyCode()
+	}
+	return amount.String(), currency
+}
+
+// timestamp - return time in local zone or empty string if it isn't set
+func timestamp(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().Local().Format(time.RFC3339)
+}
+
+// printError - write message of status error with its code and details:
+// violations of fields, reason of error and time after which call can be retried
+func printError(w io.Writer, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "error: %s: %s\n", st.Code(), st.Message())
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				fmt.Fprintf(w, "  field %s: %s\n", v.GetField(), v.GetDescription())
+			}
+		case *errdetails.ErrorInfo:
+			fmt.Fprintf(w, "  reason: %s%s\n", d.GetReason(), metadata(d.GetMetadata()))
+		case *errdetails.RetryInfo:
+			fmt.Fprintf(w, "  retry after: %s\n", d.GetRetryDelay().AsDuration().Round(time.Second))
+		}
+	}
+}
+
+// metadata - return metadata of ErrorInfo like " (key=value, ...)" sorted by keys
+func metadata(md map[string]string) string {
+	if len(md) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(md))
+	for key, value := range md {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return " (" + strings.Join(pairs, ", ") + ")"
+}
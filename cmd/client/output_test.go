@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+var (
+	testUpdated      = time.Date(2021, 3, 4, 15, 16, 17, 0, time.UTC)
+	testDiscontinued = testUpdated.Add(time.Hour)
+)
+
+// testProducts - active product and discontinued product with name which has to be quoted in CSV
+func testProducts() []*pb.Product {
+	return []*pb.Product{
+		{
+			Id:           "6041dc9f2a1b3c4d5e6f7081",
+			Name:         "apple",
+			Price:        &pb.Money{CurrencyCode: "USD", Units: 10, Nanos: 500000000},
+			PriceSource:  "manual",
+			Updated:      timestamppb.New(testUpdated),
+			PriceUpdates: 2,
+		},
+		{
+			Id:           "6041dc9f2a1b3c4d5e6f7082",
+			Name:         "pear, green",
+			Price:        &pb.Money{CurrencyCode: "EUR", Units: -1, Nanos: -1},
+			PriceSource:  "feed",
+			Updated:      timestamppb.New(testUpdated),
+			Discontinued: timestamppb.New(testDiscontinued),
+		},
+	}
+}
+
+func TestProductTable(t *testing.T) {
+	updated := testUpdated.Local().Format(time.RFC3339)
+	discontinued := testDiscontinued.Local().Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		format   string
+		products []*pb.Product
+		want     string
+	}{
+		{
+			name:   "table",
+			format: formatTable,
+			products: []*pb.Product{
+				{Id: "1", Name: "apple", Price: &pb.Money{CurrencyCode: "USD", Units: 10, Nanos: 500000000}, PriceSource: "manual", PriceUpdates: 2},
+				{Id: "2", Name: "banana", Price: &pb.Money{CurrencyCode: "EUR", Nanos: -1}, PriceSource: "feed"},
+			},
+			want: "ID  NAME    PRICE         CURRENCY  SOURCE  UPDATED  UPDATES  DISCONTINUED\n" +
+				"1   apple   10.5          USD       manual           2        \n" +
+				"2   banana  -0.000000001  EUR       feed             0        \n",
+		},
+		{
+			name:     "empty table",
+			format:   formatTable,
+			products: nil,
+			want:     "ID  NAME  PRICE  CURRENCY  SOURCE  UPDATED  UPDATES  DISCONTINUED\n",
+		},
+		{
+			name:     "csv",
+			format:   formatCSV,
+			products: testProducts(),
+			want: "id,name,price,currency,source,updated,updates,discontinued\n" +
+				"6041dc9f2a1b3c4d5e6f7081,apple,10.5,USD,manual," + updated + ",2,\n" +
+				"6041dc9f2a1b3c4d5e6f7082,\"pear, green\",-1.000000001,EUR,feed," + updated + ",0," + discontinued + "\n",
+		},
+		{
+			name:     "csv without price",
+			format:   formatCSV,
+			products: []*pb.Product{{Id: "1", Name: "apple"}},
+			want:     "id,name,price,currency,source,updated,updates,discontinued\n1,apple,,,,,0,\n",
+		},
+		{
+			name:     "csv with invalid price",
+			format:   formatCSV,
+			products: []*pb.Product{{Id: "1", Name: "apple", Price: &pb.Money{CurrencyCode: "USD", Units: 1, Nanos: -1}}},
+			want:     "id,name,price,currency,source,updated,updates,discontinued\n1,apple,invalid,USD,,,0,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			table := newProductTable(&b, tt.format)
+			for _, p := range tt.products {
+				table.write(p)
+			}
+			require.NoError(t, table.flush())
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	product := &pb.Product{Id: "1", Name: "apple", Price: &pb.Money{CurrencyCode: "USD", Units: 3}, PriceSource: "manual"}
+	// names of fields from products.proto, unpopulated fields are written too
+	want := map[string]interface{}{
+		"id":            "1",
+		"name":          "apple",
+		"price":         map[string]interface{}{"currency_code": "USD", "units": "3", "nanos": float64(0)},
+		"price_source":  "manual",
+		"price_updates": float64(0),
+		"updated":       nil,
+		"discontinued":  nil,
+	}
+
+	tests := []struct {
+		name      string
+		write     func(b *bytes.Buffer) error
+		multiline bool
+	}{
+		{name: "indented", write: func(b *bytes.Buffer) error { return writeJSON(b, product) }, multiline: true},
+		{name: "one line", write: func(b *bytes.Buffer) error { return writeJSONLine(b, product) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, tt.write(&b))
+
+			var got map[string]interface{}
+			require.NoError(t, json.Unmarshal(b.Bytes(), &got))
+			for key, value := range want {
+				assert.Equal(t, value, got[key], "field %s", key)
+			}
+			assert.Equal(t, tt.multiline, bytes.Count(b.Bytes(), []byte("\n")) > 1)
+		})
+	}
+}
+
+func TestFormatFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "default", allowed: []string{formatTable, formatJSON, formatCSV}, want: formatTable},
+		{name: "json", allowed: []string{formatTable, formatJSON, formatCSV}, value: formatJSON, want: formatJSON},
+		{name: "csv", allowed: []string{formatTable, formatJSON, formatCSV}, value: formatCSV, want: formatCSV},
+		{name: "not allowed", allowed: []string{formatTable, formatJSON}, value: formatCSV, want: formatTable, wantErr: "format must be one of table, json"},
+		{name: "unknown", allowed: []string{formatJSON}, value: "yaml", want: formatJSON, wantErr: "format must be one of json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFormatFlag(tt.allowed...)
+			if tt.value != "" {
+				err := f.Set(tt.value)
+				if tt.wantErr != "" {
+					require.Error(t, err)
+					assert.Equal(t, tt.wantErr, err.Error())
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			assert.Equal(t, tt.want, f.String())
+		})
+	}
+}
+
+func TestPrintError(t *testing.T) {
+	badRequest, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "currency", Description: "invalid currency \"X\""}},
+	})
+	require.NoError(t, err)
+	unavailable, err := status.New(codes.Unavailable, "CSV file can't be downloaded").WithDetails(
+		&errdetails.ErrorInfo{Reason: "FEED_UNREACHABLE", Metadata: map[string]string{"host": "example.com", "http_status": "503"}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(30 * time.Second)},
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "not status", err: errors.New("connection refused"), want: "error: connection refused\n"},
+		{name: "status without details", err: status.Error(codes.NotFound, "product not found"), want: "error: NotFound: product not found\n"},
+		{
+			name: "field violations",
+			err:  badRequest.Err(),
+			want: "error: InvalidArgument: invalid request\n  field currency: invalid currency \"X\"\n",
+		},
+		{
+			name: "reason and retry",
+			err:  unavailable.Err(),
+			want: "error: Unavailable: CSV file can't be downloaded\n  reason: FEED_UNREACHABLE (host=example.com, http_status=503)\n  retry after: 30s\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			printError(&b, tt.err)
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+}
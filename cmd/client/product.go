@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ids of products are hex ObjectIDs, other arguments are names
+var idPattern = regexp.MustCompile(`^[0-9a-fA-F]{24}$`)
+
+// productKey - take argument of command, it is name if byName is true
+// return id or name of product
+func productKey(arg string, byName bool) (id, name string) {
+	if !byName && idPattern.MatchString(arg) {
+		return arg, ""
+	}
+	return "", arg
+}
+
+func getProduct(ctx context.Context, client pb.ProductsServiceClient, id, name string) (*pb.Product, error) {
+	req := &pb.GetProductRequest{Key: &pb.GetProductRequest_Name{Name: name}}
+	if id != "" {
+		req.Key = &pb.GetProductRequest_Id{Id: id}
+	}
+	return client.GetProduct(ctx, req)
+}
+
+// defineGet - print product and prices of its sources
+func defineGet(fs *flag.FlagSet) runFunc {
+	byName := fs.Bool("name", false, "argument is name even if it looks like id")
+	format := newFormatFlag(formatTable, formatJSON)
+	fs.Var(format, "o", "output format: table or json")
+
+	return func(ctx context.Context, client pb.ProductsServiceClient, args []string) error {
+		if err := exactArgs(args, 1, "get [flags] ID|NAME"); err != nil {
+			return err
+		}
+		id, name := productKey(args[0], *byName)
+		p, err := getProduct(ctx, client, id, name)
+		if err != nil {
+			return err
+		}
+		if format.value == formatJSON {
+			return writeJSON(os.Stdout, p)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		amount, currency := money(p.GetPrice())
+		fmt.Fprintf(tw, "id\t%s\n", p.GetId())
+		fmt.Fprintf(tw, "name\t%s\n", p.GetName())
+		fmt.Fprintf(tw, "price\t%s %s\n", amount, currency)
+		fmt.Fprintf(tw, "source\t%s\n", p.GetPriceSource())
+		fmt.Fprintf(tw, "updated\t%s\n", timestamp(p.GetUpdated()))
+		fmt.Fprintf(tw, "updates\t%d\n", p.GetPriceUpdates())
+		fmt.Fprintf(tw, "revision\t%d\n", p.GetRevision())
+		if p.GetDiscontinued() != nil {
+			fmt.Fprintf(tw, "discontinued\t%s\n", timestamp(p.GetDiscontinued()))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if len(p.GetSources()) == 0 {
+			return nil
+		}
+
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tPRICE\tCURRENCY\tUPDATED\tUPDATES\tDISCONTINUED")
+		for _, sp := range p.GetSources() {
+			amount, currency := money(sp.GetPrice())
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", sp.GetSource(), amount, currency,
+				timestamp(sp.GetUpdated()), sp.GetPriceUpdates(), timestamp(sp.GetDiscontinued()))
+		}
+		return tw.Flush()
+	}
+}
+
+// defineHistory - print changes of price of product, the latest first like server returns them
+func defineHistory(fs *flag.FlagSet) runFunc {
+	byName := fs.Bool("name", false, "argument is name even if it looks like id")
+	source := fs.String("source", "", "print changes only of this source")
+	format := newFormatFlag(formatTable, formatJSON, formatCSV)
+	fs.Var(format, "o", "output format: table, json or csv")
+
+	return func(ctx context.Context, client pb.ProductsServiceClient, args []string) error {
+		if err := exactArgs(args, 1, "history [flags] ID|NAME"); err != nil {
+			return err
+		}
+		id, name := productKey(args[0], *byName)
+		req := &pb.GetPriceHistoryRequest{Key: &pb.GetPriceHistoryRequest_Name{Name: name}, Source: *source}
+		if id != "" {
+			req.Key = &pb.GetPriceHistoryRequest_Id{Id: id}
+		}
+		resp, err := client.GetPriceHistory(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		switch format.value {
+		case formatJSON:
+			return writeJSON(os.Stdout, resp)
+		case formatCSV:
+			w := csv.NewWriter(os.Stdout)
+			_ = w.Write([]string{"changed", "price", "currency", "source", "kind", "principal"})
+			for _, c := range resp.GetChanges() {
+				amount, currency := money(c.GetPrice())
+				_ = w.Write([]string{timestamp(c.GetChanged()), amount, currency, c.GetSource(), c.GetKind(), c.GetPrincipal()})
+			}
+			w.Flush()
+			return w.Error()
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CHANGED\tPRICE\tCURRENCY\tSOURCE\tKIND\tPRINCIPAL")
+		for _, c := range resp.GetChanges() {
+			amount, currency := money(c.GetPrice())
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", timestamp(c.GetChanged()), amount, currency,
+				c.GetSource(), c.GetKind(), c.GetPrincipal())
+		}
+		return tw.Flush()
+	}
+}
+
+// defineWatch - poll product and print it when its revision changes, until interrupted,
+// server has no stream of changes, so changes between polls are merged
+func defineWatch(fs *flag.FlagSet) runFunc {
+	byName := fs.Bool("name", false, "argument is name even if it looks like id")
+	interval := fs.Duration("interval", 5*time.Second, "time between polls")
+	format := newFormatFlag(formatTable, formatJSON)
+	fs.Var(format, "o", "output format: table or json, json is one product per line")
+
+	return func(ctx context.Context, client pb.ProductsServiceClient, args []string) error {
+		if err := exactArgs(args, 1, "watch [flags] ID|NAME"); err != nil {
+			return err
+		}
+		if *interval <= 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+		id, name := productKey(args[0], *byName)
+
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+
+		var revision uint64
+		seen := false
+		for {
+			p, err := getProduct(ctx, client, id, name)
+			switch {
+			case ctx.Err() != nil:
+				// interrupted by user
+				return nil
+			case err != nil && !transient(err):
+				return err
+			case err != nil:
+				printError(os.Stderr, err)
+			case !seen || p.GetRevision() != revision:
+				seen, revision = true, p.GetRevision()
+				if err := printChange(p, format.value); err != nil {
+					return err
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// transient - return true if poll can be repeated after error
+func transient(err error) bool {
+	if _, ok := retryDelay(err); ok {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// printChange - write one line with state of watched product
+func printChange(p *pb.Product, format string) error {
+	if format == formatJSON {
+		return writeJSONLine(os.Stdout, p)
+	}
+	amount, currency := money(p.GetPrice())
+	fields := []string{
+		time.Now().Format(time.RFC3339),
+		"revision " + strconv.FormatUint(p.GetRevision(), 10),
+		p.GetName(),
+		strings.TrimSpace(amount + " " + currency),
+		p.GetPriceSource(),
+	}
+	if p.GetDiscontinued() != nil {
+		fields = append(fields, "discontinued "+timestamp(p.GetDiscontinued()))
+	}
+	_, err := fmt.Println(strings.Join(fields, "  "))
+	return err
+}
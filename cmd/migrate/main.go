@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/database"
+	"github.com/ArturChopikian/grpc-server/database/migrations"
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const usage = `usage: migrate <command>
+  status        print applied and the latest known versions
+  up            apply all migrations
+  down VERSION  roll back migrations newer than VERSION`
+
+func init() {
+	log.SetPrefix("Migrate: ")
+
+	// env file is optional, variables can be set in environment
+	_ = godotenv.Load()
+}
+
+// apply or roll back migrations of MongoDB schema
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := configs.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// progress of migrations is written to stderr
+	logger := logrus.NewEntry(logrus.StandardLogger())
+
+	coll, err := database.NewMongoDBCollection(cfg, logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := coll.Database().Client().Disconnect(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	if err := run(ctx, migrations.NewRunner(coll.Database(), cfg, logger), os.Args[1:]); err != nil {
+		log.Println(err)
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// run - run command from arguments
+func run(ctx context.Context, runner *migrations.Runner, args []string) error {
+	switch args[0] {
+	case "status":
+		current, latest, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied version: %d\nlatest version: %d\n", current, latest)
+		return nil
+	case "up":
+		return runner.Up(ctx)
+	case "down":
+		if len(args) != 2 {
+			return fmt.Errorf("down requires version\n%s", usage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return runner.Down(ctx, version)
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+}
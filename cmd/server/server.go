@@ -1,94 +1,204 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	csv_server "github.com/ArturChopikian/csv_http_server"
 	"github.com/ArturChopikian/grpc-server/configs"
-	"github.com/ArturChopikian/grpc-server/database"
+	"github.com/ArturChopikian/grpc-server/internal/csvserver"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/health"
+	"github.com/ArturChopikian/grpc-server/internal/logging"
+	"github.com/ArturChopikian/grpc-server/internal/metrics"
+	"github.com/ArturChopikian/grpc-server/internal/outbox"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/bolt"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/memory"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/postgres"
 	"github.com/ArturChopikian/grpc-server/internal/server"
+	"github.com/ArturChopikian/grpc-server/internal/tracing"
 	"github.com/joho/godotenv"
-	"io"
-	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-func init() {
-	log.SetPrefix("Server: ")
+// time for exporting remaining spans on shutdown
+const tracingShutdownTimeout = 5 * time.Second
+
+func main() {
+	// failures before logger from config is created are written in the same format to stderr
+	startup := logging.Startup()
 
 	// load env file
 	if err := godotenv.Load(); err != nil {
-		log.Fatal(err)
+		startup.Fatal(err)
 	}
-}
+	// format of startup logger can be set in env file
+	startup = logging.Startup()
 
-func main() {
-	// open file for save logs
-	f, err := os.OpenFile("info.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	// create new config from .env file
+	cfg, err := configs.NewConfig()
 	if err != nil {
-		log.Fatal(err)
+		startup.Fatal(err)
 	}
-	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-			log.Fatal(err)
+
+	// write logs with format, level and outputs from config
+	logger, closeLog, err := logging.New(&cfg.Log)
+	if err != nil {
+		startup.Fatal(err)
+	}
+	defer func() {
+		if err := closeLog(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
 		}
-	}(f)
+	}()
 
-	// write logs to file and console
-	wrt := io.MultiWriter(os.Stdout, f)
-	log.SetOutput(wrt)
+	// export spans of calls, fetches and storage, it must be done before storage is opened
+	shutdownTracing, err := tracing.Init(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error(err)
+		}
+	}()
 
-	// create new config from .env file
-	cfg, err := configs.NewConfig()
+	// open storage of products with driver from config
+	repos, err := repository.Open(cfg, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer func(repos *repository.Repository) {
+		if err := repos.Close(); err != nil {
+			logger.Error(err)
+		}
+	}(repos)
+
+	// create sink for product change events
+	sink, err := outbox.NewSink(cfg, logger)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(err)
 	}
+	defer func(sink outbox.Sink) {
+		if err := sink.Close(); err != nil {
+			logger.Error(err)
+		}
+	}(sink)
+
+	// run relay which publishes events from outbox to the sink
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// get mongoDB collection
-	coll, err := database.NewMongoDBCollection(cfg)
+	relay, err := outbox.NewRelay(repos.Outbox, sink, cfg, logger)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(err)
 	}
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
 
 	// define two variables for CSV Server
 	folder := cfg.SeverCSV.Folder
 	address := fmt.Sprintf("%s:%s", cfg.SeverCSV.Host, cfg.SeverCSV.Port)
 
 	// create logs for CSV Server
-	csvLog := log.New(wrt, "CSV SERVER: ", log.Ldate|log.Ltime)
+	csvLog := logger.WithField("component", "csv_server")
 	// create CSV Server
-	csvServer, err := csv_server.NewCSVServer(folder, address)
+	csvServer, err := csvserver.New(folder, address)
 	if err != nil {
 		csvLog.Fatal(err)
 	}
 
-	// map csv handlers and run csv server
+	// run csv server
 	go func() {
-		if err := csvServer.MapHandlers(); err != nil {
-			csvLog.Fatal(err)
-		}
 		if err := csvServer.Run(); err != nil {
 			csvLog.Fatal(err)
 		}
 	}()
 
 	// create new products server
-	s, err := server.NewProductsServer(cfg, coll)
+	s, err := server.NewProductsServer(cfg, repos, logger)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(err)
 	}
 
 	// map handlers for product server
 	s.MapHandler()
 
-	// run server
-	if err := s.Run(); err != nil {
-		log.Fatal(err)
+	// check storage, CSV server and outbox relay, only storage is required for serving calls
+	checker := health.NewChecker(cfg, logger, []string{pb.ProductsService_ServiceDesc.ServiceName},
+		health.Check{Name: "storage", Critical: true, Fn: repos.Ping},
+		health.TCPCheck("csv_server", address, false),
+		health.Check{Name: "outbox_relay", Fn: relay.Check},
+	)
+	s.MapHealth(checker)
+	go checker.Run(ctx)
+
+	// run HTTP server with /healthz, /readyz and /metrics
+	var opsServer *http.Server
+	if cfg.Health.Port != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/", checker.Handler())
+
+		opsServer = &http.Server{
+			Addr:    fmt.Sprintf("%s:%s", cfg.Health.Host, cfg.Health.Port),
+			Handler: mux,
+		}
+		go func() {
+			if err := opsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal(err)
+			}
+		}()
 	}
 
+	// run server until it fails or signal for shutdown comes
 	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	<-quit
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- s.Run()
+	}()
+
+	select {
+	case err := <-serverErr:
+		logger.WithError(err).Error("server stopped")
+	case sig := <-quit:
+		logger.WithField("signal", sig.String()).Info("shutdown by signal")
+	}
+	signal.Stop(quit)
+
+	// load balancers stop sending calls while running ones are finished
+	checker.Shutdown()
+
+	// running calls are waited for shutdown timeout, after it running fetches are interrupted
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(),
+		time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+	defer cancelShutdown()
+
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		logger.Error(err)
+	}
+	if err := csvServer.Shutdown(shutdownCtx); err != nil {
+		csvLog.Error(err)
+	}
+	if opsServer != nil {
+		if err := opsServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err)
+		}
+	}
 
-	s.Stop()
+	// relay is stopped before sink is closed and storage is disconnected by deferred calls
+	cancel()
+	<-relayDone
+	logger.Info("server is stopped")
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/bolt"
+	"github.com/ArturChopikian/grpc-server/internal/repository/conformance"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/memory"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/postgres"
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	log.SetPrefix("Storage check: ")
+
+	// env file is optional, variables can be set in environment
+	_ = godotenv.Load()
+}
+
+// check that storage driver from STORAGE_DRIVER behaves like MongoDB repositories,
+// storage must be empty
+func main() {
+	cfg, err := configs.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// errors of repositories are written to stderr
+	repos, err := repository.Open(cfg, logrus.NewEntry(logrus.StandardLogger()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func(repos *repository.Repository) {
+		if err := repos.Close(); err != nil {
+			log.Println(err)
+		}
+	}(repos)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	failures := conformance.Run(ctx, repos)
+	for _, err := range failures {
+		log.Println(err)
+	}
+	if len(failures) > 0 {
+		log.Printf("driver %s: %d checks failed", cfg.Storage.Driver, len(failures))
+		cancel()
+		_ = repos.Close()
+		os.Exit(1)
+	}
+	log.Printf("driver %s: all checks passed", cfg.Storage.Driver)
+}
@@ -5,7 +5,15 @@ import "github.com/kelseyhightower/envconfig"
 type Config struct {
 	Server   ServerConfig
 	SeverCSV SeverCSVConfig
+	Storage  StorageConfig
+	Health   HealthConfig
+	Gateway  GatewayConfig
+	Tracing  TracingConfig
+	Auth     AuthConfig
+	Limits   LimitsConfig
 	MongoDB  MongoDBConfig
+	Outbox   OutboxConfig
+	Currency CurrencyConfig
 	Log      LogConfig
 }
 
@@ -13,6 +21,42 @@ type ServerConfig struct {
 	Host    string `envconfig:"host"`
 	Port    string `envconfig:"port"`
 	Network string `envconfig:"network"`
+	// time in seconds for running calls to finish on shutdown, after it fetches are interrupted
+	ShutdownTimeout int `envconfig:"shutdown_timeout" default:"30"`
+	// deadline in seconds of calls without deadline, longer deadlines of callers are cut to max timeout,
+	// both can be overridden for methods by their names like Fetch:600, 0 means no deadline
+	DefaultTimeout    int            `envconfig:"default_timeout" default:"30"`
+	MaxTimeout        int            `envconfig:"max_timeout" default:"300"`
+	MethodTimeouts    map[string]int `envconfig:"method_timeouts" default:"Fetch:600,StreamProducts:600,Watch:0,ServerReflectionInfo:0"`
+	MethodMaxTimeouts map[string]int `envconfig:"method_max_timeouts" default:"Fetch:3600,StreamProducts:3600,Watch:0,ServerReflectionInfo:0"`
+	// maximum size in bytes of received and sent messages, 0 means default of gRPC
+	MaxRecvMsgSize int `envconfig:"max_recv_msg_size" default:"4194304"`
+	MaxSendMsgSize int `envconfig:"max_send_msg_size" default:"16777216"`
+	// PEM files with certificate and private key of the server, calls are served with TLS if they are set
+	TLSCertFile string `envconfig:"tls_cert_file"`
+	TLSKeyFile  string `envconfig:"tls_key_file"`
+	// PEM file with certificate authorities of client certificates
+	TLSClientCAFile string `envconfig:"tls_client_ca_file"`
+	// request: client certificates are verified if clients send them, require: calls without them are rejected
+	TLSClientAuth string `envconfig:"tls_client_auth" default:"request"`
+	// minimal version of TLS: 1.2 or 1.3
+	TLSMinVersion string `envconfig:"tls_min_version" default:"1.2"`
+	// interval in seconds between checks of certificate files, changed files are loaded without restart
+	TLSReloadInterval int `envconfig:"tls_reload_interval" default:"10"`
+}
+
+type LimitsConfig struct {
+	// calls per minute and burst of every caller for each method of ProductsService, 0 rate means no limit,
+	// both can be overridden for methods by their names like Fetch:6
+	Rate         int            `envconfig:"rate" default:"600"`
+	Burst        int            `envconfig:"burst" default:"100"`
+	MethodRates  map[string]int `envconfig:"method_rates" default:"Fetch:6"`
+	MethodBursts map[string]int `envconfig:"method_bursts" default:"Fetch:3"`
+	// fetches which run at once, 0 means no limit, other fetches wait in queue of fetch queue size
+	MaxFetches     int `envconfig:"max_fetches" default:"4"`
+	FetchQueueSize int `envconfig:"fetch_queue_size" default:"16"`
+	// time in seconds after which fetch rejected because of full queue or unreachable CSV file can be retried
+	FetchRetryAfter int `envconfig:"fetch_retry_after" default:"30"`
 }
 
 type SeverCSVConfig struct {
@@ -21,23 +65,175 @@ type SeverCSVConfig struct {
 	Folder string `envconfig:"folder"`
 }
 
+type HealthConfig struct {
+	// address of HTTP server with /healthz, /readyz and /metrics, empty port disables it
+	Host string `envconfig:"host"`
+	Port string `envconfig:"port" default:"8081"`
+	// interval between checks of dependencies and timeout of one check in seconds
+	Interval int `envconfig:"interval" default:"5"`
+	Timeout  int `envconfig:"timeout" default:"2"`
+}
+
+type GatewayConfig struct {
+	// address of HTTP/JSON gateway, empty port disables it,
+	// it is served with TLS certificate of the server if it is set
+	Host string `envconfig:"host"`
+	Port string `envconfig:"port"`
+	// serve gRPC-Web calls of ProductsService on the port of gateway
+	GRPCWeb bool `envconfig:"grpc_web"`
+	// origins of web pages which can call gateway and gRPC-Web, * allows all origins
+	CORSOrigins []string `envconfig:"cors_origins"`
+}
+
+type TracingConfig struct {
+	// where spans are exported: none, otlp, stdout or file
+	Exporter string `envconfig:"exporter" default:"none"`
+	// address of OpenTelemetry collector for otlp exporter, spans are sent over gRPC
+	OTLPEndpoint string `envconfig:"otlp_endpoint" default:"localhost:4317"`
+	// connect to collector without TLS
+	OTLPInsecure bool `envconfig:"otlp_insecure"`
+	// file for file exporter, one JSON span per line
+	File string `envconfig:"file" default:"traces.json"`
+	// part of new traces which are recorded from 0 to 1, traces started by callers keep their decision
+	SampleRatio float64 `envconfig:"sample_ratio" default:"1"`
+	// name of the server in traces
+	ServiceName string `envconfig:"service_name" default:"grpc-server"`
+}
+
+type AuthConfig struct {
+	// authentication of callers: api_key, jwt and mtls, comma separated, they are tried in this order,
+	// empty list disables authentication and authorization, then all callers are anonymous
+	Methods []string `envconfig:"methods"`
+	// JSON file with API keys like [{"key": "secret", "name": "importer", "roles": ["ingest"]}],
+	// key is sent in x-api-key metadata
+	APIKeysFile string `envconfig:"api_keys_file" default:"api_keys.json"`
+	// JSON Web Key Set file with public keys of tokens, token is sent in authorization metadata as Bearer,
+	// issuer and audience are checked if they are set, roles are taken from the claim
+	JWKSFile      string `envconfig:"jwks_file" default:"jwks.json"`
+	JWTIssuer     string `envconfig:"jwt_issuer"`
+	JWTAudience   string `envconfig:"jwt_audience"`
+	JWTRolesClaim string `envconfig:"jwt_roles_claim" default:"roles"`
+	// roles which can call read methods and mutation methods like Fetch,
+	// roles of client certificate are its organizational units
+	ReaderRoles []string `envconfig:"reader_roles" default:"reader,ingest,admin"`
+	IngestRoles []string `envconfig:"ingest_roles" default:"ingest,admin"`
+	// allow callers without credentials to call read methods
+	AnonymousRead bool `envconfig:"anonymous_read"`
+}
+
+type StorageConfig struct {
+	// storage driver of products: mongodb, postgres, bolt or memory
+	Driver string `envconfig:"driver" default:"mongodb"`
+	// connection string of PostgreSQL for postgres driver
+	PostgresDSN string `envconfig:"postgres_dsn"`
+	// database file for bolt driver
+	BoltPath string `envconfig:"bolt_path" default:"products.db"`
+}
+
 type MongoDBConfig struct {
 	User       string `envconfig:"user"`
 	Password   string `envconfig:"password"`
 	URI        string `envconfig:"uri"`
 	Database   string `envconfig:"database"`
 	Collection string `envconfig:"collection"`
-	Timeout    int    `envconfig:"timeout"`
+	// timeout of connecting and of each ping in seconds
+	Timeout int `envconfig:"timeout" default:"10"`
+	// database with credentials of user, by default it is from URI or "admin"
+	AuthSource string `envconfig:"auth_source"`
+	// SCRAM-SHA-1, SCRAM-SHA-256, MONGODB-X509, etc., by default it is negotiated with server
+	AuthMechanism string `envconfig:"auth_mechanism"`
+	// name of replica set, connection is checked against it
+	ReplicaSet string `envconfig:"replica_set"`
+	// retry failed writes once, it requires replica set
+	RetryWrites bool `envconfig:"retry_writes" default:"true"`
+	// connect with TLS, it is enabled by tls_ca_file and tls_certificate_key_file too
+	TLS bool `envconfig:"tls"`
+	// PEM file with certificate authorities of server
+	TLSCAFile string `envconfig:"tls_ca_file"`
+	// PEM file with certificate and private key of client for mutual TLS or MONGODB-X509
+	TLSCertificateKeyFile string `envconfig:"tls_certificate_key_file"`
+	// don't verify certificate of server, only for development
+	TLSInsecure bool `envconfig:"tls_insecure"`
+	// limits of connections to each server, 0 - default of driver
+	MinPoolSize uint64 `envconfig:"min_pool_size"`
+	MaxPoolSize uint64 `envconfig:"max_pool_size"`
+	// idle connection is closed after this time in seconds, 0 - never
+	MaxConnIdleTime int `envconfig:"max_conn_idle_time"`
+	// number of pings on start before giving up, between pings waiting time is doubled
+	ConnectAttempts int `envconfig:"connect_attempts" default:"5"`
+	// waiting time before the second ping in seconds
+	ConnectBackoff int `envconfig:"connect_backoff" default:"1"`
+	// collection for product change events, it must be in the same database
+	OutboxCollection string `envconfig:"outbox_collection" default:"outbox"`
+	// collection for exchange rates, it is used if rates file isn't set
+	RatesCollection string `envconfig:"rates_collection" default:"rates"`
+	// collection for state of fetch jobs
+	JobsCollection string `envconfig:"jobs_collection" default:"fetch_jobs"`
+	// read preference of List and StreamProducts: primary, primaryPreferred, secondary,
+	// secondaryPreferred or nearest, other reads and writes always use primary
+	ReadPreference string `envconfig:"read_preference" default:"primary"`
+	// maximum replication lag of secondary in seconds for List, at least 90, 0 - no limit
+	MaxStaleness int `envconfig:"max_staleness"`
+	// read concern of List and StreamProducts: local, available, majority or linearizable,
+	// empty - default of server
+	ReadConcern string `envconfig:"read_concern"`
+	// collection with applied migrations of schema
+	MigrationsCollection string `envconfig:"migrations_collection" default:"schema_migrations"`
+	// apply migrations on start, otherwise only check that schema isn't newer than the server
+	Migrate bool `envconfig:"migrate" default:"true"`
+}
+
+type OutboxConfig struct {
+	// where events are published: log, file or webhook
+	Sink       string `envconfig:"sink" default:"log"`
+	File       string `envconfig:"file" default:"outbox.log"`
+	WebhookURL string `envconfig:"webhook_url"`
+	// poll interval and webhook timeout in seconds
+	PollInterval int   `envconfig:"poll_interval" default:"1"`
+	Timeout      int   `envconfig:"timeout" default:"10"`
+	BatchSize    int64 `envconfig:"batch_size" default:"100"`
+	// time in hours for keeping published events, then relay deletes them, 0 - keep forever
+	Retention int `envconfig:"retention" default:"168"`
+}
+
+type CurrencyConfig struct {
+	// ISO 4217 code of prices without currency
+	Default string `envconfig:"default" default:"USD"`
+	// JSON file with exchange rates like {"USD": 1, "EUR": 1.08},
+	// if it is set rates can't be changed by rpc
+	RatesFile string `envconfig:"rates_file"`
 }
 
 type LogConfig struct {
-	Prefix string `envconfig:"prefix"`
+	// name of the server in every line
+	Prefix string `envconfig:"prefix" default:"server"`
+	// format of lines: logfmt or json
+	Format string `envconfig:"format" default:"logfmt"`
+	// minimal level of lines: debug, info, warn or error
+	Level string `envconfig:"level" default:"info"`
+	// where lines are written: stdout, stderr and file, comma separated
+	Outputs []string `envconfig:"outputs" default:"stdout,file"`
+	// file output, it is rotated when it grows to max size in megabytes,
+	// rotated files are removed when there are more than max backups or they are older than max age in days
+	File       string `envconfig:"file" default:"info.log"`
+	MaxSize    int    `envconfig:"max_size" default:"100"`
+	MaxBackups int    `envconfig:"max_backups" default:"5"`
+	MaxAge     int    `envconfig:"max_age" default:"30"`
+	Compress   bool   `envconfig:"compress"`
 }
 
 const (
 	serverGroup    = "server"
 	csvServerGroup = "csv_server"
+	storageGroup   = "storage"
+	healthGroup    = "health"
+	gatewayGroup   = "gateway"
+	tracingGroup   = "tracing"
+	authGroup      = "auth"
+	limitsGroup    = "limits"
 	mongodbGroup   = "mongodb"
+	outboxGroup    = "outbox"
+	currencyGroup  = "currency"
 	logGroup       = "log"
 )
 
@@ -50,9 +246,33 @@ func NewConfig() (*Config, error) {
 	if err := envconfig.Process(csvServerGroup, &config.SeverCSV); err != nil {
 		return &Config{}, err
 	}
+	if err := envconfig.Process(storageGroup, &config.Storage); err != nil {
+		return &Config{}, err
+	}
+	if err := envconfig.Process(healthGroup, &config.Health); err != nil {
+		return &Config{}, err
+	}
+	if err := envconfig.Process(gatewayGroup, &config.Gateway); err != nil {
+		return &Config{}, err
+	}
+	if err := envconfig.Process(tracingGroup, &config.Tracing); err != nil {
+		return &Config{}, err
+	}
+	if err := envconfig.Process(authGroup, &config.Auth); err != nil {
+		return &Config{}, err
+	}
+	if err := envconfig.Process(limitsGroup, &config.Limits); err != nil {
+		return &Config{}, err
+	}
 	if err := envconfig.Process(mongodbGroup, &config.MongoDB); err != nil {
 		return &Config{}, err
 	}
+	if err := envconfig.Process(outboxGroup, &config.Outbox); err != nil {
+		return &Config{}, err
+	}
+	if err := envconfig.Process(currencyGroup, &config.Currency); err != nil {
+		return &Config{}, err
+	}
 	if err := envconfig.Process(logGroup, &config.Log); err != nil {
 		return &Config{}, err
 	}
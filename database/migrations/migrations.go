@@ -0,0 +1,339 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"sort"
+	"time"
+)
+
+// SchemaAheadError - database was migrated by newer version of the server
+var SchemaAheadError = errors.New("schema of database is newer than the server")
+
+// LockLostError - lock of migrations expired and was taken by other runner while migrations ran
+var LockLostError = errors.New("lock of migrations is lost")
+
+// lock document in collection of migrations
+const (
+	lockId = "lock"
+	// lock of crashed runner is taken over after this time
+	lockTTL = 10 * time.Minute
+	// expiration of held lock is moved forward with this interval while migrations run
+	lockRenew = lockTTL / 5
+	// pause between attempts to take the lock
+	lockRetry = time.Second
+)
+
+// Migration - one versioned change of MongoDB schema
+// Up and Down must be idempotent, they aren't run inside transaction,
+// because indexes can't be created in transactions,
+// Down can be nil if the change doesn't need to be undone, for example backfill of fields
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database, cfg *configs.Config) error
+	Down    func(ctx context.Context, db *mongo.Database, cfg *configs.Config) error
+}
+
+// applied - state of applied migration in collection of migrations
+type applied struct {
+	Version int       `bson:"_id"`
+	Name    string    `bson:"name"`
+	Applied time.Time `bson:"applied"`
+}
+
+// Runner - apply and roll back migrations of the database,
+// applied versions are stored in migrations collection from config
+type Runner struct {
+	db         *mongo.Database
+	cfg        *configs.Config
+	state      *mongo.Collection
+	migrations []Migration
+	log        *logrus.Entry
+}
+
+// NewRunner - return runner of all known migrations of the database
+func NewRunner(db *mongo.Database, cfg *configs.Config, log *logrus.Entry) *Runner {
+	known := make([]Migration, len(all))
+	copy(known, all)
+	sort.Slice(known, func(i, j int) bool {
+		return known[i].Version < known[j].Version
+	})
+
+	return &Runner{
+		db:         db,
+		cfg:        cfg,
+		state:      db.Collection(cfg.MongoDB.MigrationsCollection),
+		migrations: known,
+		log:        log,
+	}
+}
+
+// Status - return the latest applied version and the latest version known to the server
+func (r *Runner) Status(ctx context.Context) (current, latest int, err error) {
+	versions, err := r.applied(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for version := range versions {
+		if version > current {
+			current = version
+		}
+	}
+	return current, r.latest(), nil
+}
+
+// Check - return SchemaAheadError if database has migrations unknown to the server
+func (r *Runner) Check(ctx context.Context) error {
+	current, latest, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("migrations: version %d, server knows up to %d: %w", current, latest, SchemaAheadError)
+	}
+	return nil
+}
+
+// Up - apply all migrations which are not applied yet in order of versions
+func (r *Runner) Up(ctx context.Context) error {
+	l, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer l.release()
+	// migrations stop when the lock is lost
+	ctx = l.ctx
+
+	if err := r.Check(ctx); err != nil {
+		return err
+	}
+	versions, err := r.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range r.migrations {
+		if versions[m.Version] {
+			continue
+		}
+		if err := m.Up(ctx, r.db, r.cfg); err != nil {
+			return fmt.Errorf("migrations: up %d_%s: %v", m.Version, m.Name, err)
+		}
+		if err := l.held(ctx); err != nil {
+			return fmt.Errorf("migrations: save %d_%s: %w", m.Version, m.Name, err)
+		}
+		state := applied{Version: m.Version, Name: m.Name, Applied: time.Now()}
+		if _, err := r.state.InsertOne(ctx, state); err != nil {
+			return fmt.Errorf("migrations: save %d_%s: %v", m.Version, m.Name, err)
+		}
+		r.log.WithFields(logrus.Fields{"version": m.Version, "name": m.Name}).Info("migrations: applied")
+	}
+	return nil
+}
+
+// Down - roll back applied migrations with versions greater than the version in reverse order
+func (r *Runner) Down(ctx context.Context, version int) error {
+	l, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer l.release()
+	// migrations stop when the lock is lost
+	ctx = l.ctx
+
+	if err := r.Check(ctx); err != nil {
+		return err
+	}
+	versions, err := r.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(r.migrations) - 1; i >= 0; i-- {
+		m := r.migrations[i]
+		if m.Version <= version || !versions[m.Version] {
+			continue
+		}
+		if m.Down != nil {
+			if err := m.Down(ctx, r.db, r.cfg); err != nil {
+				return fmt.Errorf("migrations: down %d_%s: %v", m.Version, m.Name, err)
+			}
+		}
+		if err := l.held(ctx); err != nil {
+			return fmt.Errorf("migrations: save %d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := r.state.DeleteOne(ctx, bson.M{"_id": m.Version}); err != nil {
+			return fmt.Errorf("migrations: save %d_%s: %v", m.Version, m.Name, err)
+		}
+		r.log.WithFields(logrus.Fields{"version": m.Version, "name": m.Name}).Info("migrations: rolled back")
+	}
+	return nil
+}
+
+// latest - return the latest known version
+func (r *Runner) latest() int {
+	if len(r.migrations) == 0 {
+		return 0
+	}
+	return r.migrations[len(r.migrations)-1].Version
+}
+
+// applied - return set of applied versions
+func (r *Runner) applied(ctx context.Context) (map[int]bool, error) {
+	cur, err := r.state.Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, fmt.Errorf("migrations: read state: %v", err)
+	}
+
+	var states []applied
+	if err := cur.All(ctx, &states); err != nil {
+		return nil, fmt.Errorf("migrations: read state: %v", err)
+	}
+
+	result := make(map[int]bool, len(states))
+	for _, s := range states {
+		result[s.Version] = true
+	}
+	return result, nil
+}
+
+// lease - lock of migrations held by the runner
+type lease struct {
+	state *mongo.Collection
+	owner string
+	log   *logrus.Entry
+	// ctx is cancelled when the lock is released or lost
+	ctx    context.Context
+	cancel context.CancelFunc
+	// closed when renewal of the lock is stopped
+	stopped chan struct{}
+}
+
+// lock - take lock in collection of migrations, so only one runner changes the schema,
+// wait while other runner holds it,
+// the lock is renewed in background until it is released, so it doesn't expire while long migrations run
+func (r *Runner) lock(ctx context.Context) (*lease, error) {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+
+	for {
+		now := time.Now()
+		// lock of crashed runner is expired
+		if _, err := r.state.DeleteOne(ctx, bson.M{"_id": lockId, "expires": bson.M{"$lt": now}}); err != nil {
+			return nil, fmt.Errorf("migrations: lock: %v", err)
+		}
+
+		_, err := r.state.InsertOne(ctx, bson.M{"_id": lockId, "owner": owner, "expires": now.Add(lockTTL)})
+		if err == nil {
+			break
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("migrations: lock: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("migrations: lock is held by other runner: %v", ctx.Err())
+		case <-time.After(lockRetry):
+		}
+	}
+
+	l := &lease{state: r.state, owner: owner, log: r.log.WithField("owner", owner), stopped: make(chan struct{})}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	go l.renew()
+	return l, nil
+}
+
+// renew - move expiration of the lock forward until the lease is cancelled,
+// cancel the lease if the lock was taken by other runner
+func (l *lease) renew() {
+	defer close(l.stopped)
+	ticker := time.NewTicker(lockRenew)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		res, err := l.state.UpdateOne(l.ctx,
+			bson.M{"_id": lockId, "owner": l.owner},
+			bson.M{"$set": bson.M{"expires": time.Now().Add(lockTTL)}})
+		switch {
+		case l.ctx.Err() != nil:
+			return
+		case err != nil:
+			// the next attempt is made before the lock expires
+			l.log.WithError(err).Warn("migrations: lock is not renewed")
+		case res.MatchedCount == 0:
+			l.log.Error("migrations: lock is taken by other runner")
+			l.cancel()
+			return
+		}
+	}
+}
+
+// held - return LockLostError if the lock isn't held by the runner anymore,
+// it is checked before version is recorded, so version of other runner isn't overwritten
+func (l *lease) held(ctx context.Context) error {
+	n, err := l.state.CountDocuments(ctx, bson.M{"_id": lockId, "owner": l.owner, "expires": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("check lock: %v", err)
+	}
+	if n == 0 {
+		return LockLostError
+	}
+	return nil
+}
+
+// release - stop renewal and delete the lock
+func (l *lease) release() {
+	l.cancel()
+	<-l.stopped
+
+	// lock is released even if ctx of migrations is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := l.state.DeleteOne(ctx, bson.M{"_id": lockId, "owner": l.owner}); err != nil {
+		l.log.WithError(err).Error("migrations: unlock")
+	}
+}
+
+// dropIndexes - drop indexes of the collection by names, missing indexes are skipped
+func dropIndexes(ctx context.Context, coll *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		if _, err := coll.Indexes().DropOne(ctx, name); err != nil && !isIndexNotFound(err) {
+			return fmt.Errorf("drop index %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// isIndexNotFound - return true if error is IndexNotFound (27) or namespace doesn't exist (26)
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 27 || cmdErr.Code == 26
+	}
+	return false
+}
+
+// index - return model of index with the name
+func index(name string, keys bson.D, unique bool) mongo.IndexModel {
+	opts := options.Index().SetName(name)
+	if unique {
+		opts.SetUnique(true)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}
+}
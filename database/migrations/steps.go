@@ -0,0 +1,157 @@
+package migrations
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// all - known migrations, new migration is added to the end with the next version
+var all = []Migration{
+	{
+		Version: 1,
+		Name:    "create_products_indexes",
+		Up:      createProductsIndexes,
+		Down:    dropProductsIndexes,
+	},
+	{
+		Version: 2,
+		Name:    "create_outbox_indexes",
+		Up:      createOutboxIndexes,
+		Down:    dropOutboxIndexes,
+	},
+	{
+		Version: 3,
+		Name:    "backfill_currency_and_sources",
+		Up:      backfillCurrencyAndSources,
+	},
+	{
+		Version: 4,
+		Name:    "convert_prices_to_decimal",
+		Up:      convertPricesToDecimal,
+	},
+}
+
+// names of indexes of products
+const (
+	productsNameIndex    = "name_unique"
+	productsPriceIndex   = "price_id"
+	productsUpdatedIndex = "updated_id"
+	productsSourcesIndex = "sources_source"
+)
+
+// createProductsIndexes - unique name for Get and Create,
+// price and updated with id for ordering and page tokens of List,
+// source of prices for UpdatePrice and DiscontinueMissing
+func createProductsIndexes(ctx context.Context, db *mongo.Database, cfg *configs.Config) error {
+	_, err := db.Collection(cfg.MongoDB.Collection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		index(productsNameIndex, bson.D{{Key: "name", Value: 1}}, true),
+		index(productsPriceIndex, bson.D{{Key: "price", Value: 1}, {Key: "_id", Value: 1}}, false),
+		index(productsUpdatedIndex, bson.D{{Key: "updated", Value: 1}, {Key: "_id", Value: 1}}, false),
+		index(productsSourcesIndex, bson.D{{Key: "sources.source", Value: 1}}, false),
+	})
+	return err
+}
+
+func dropProductsIndexes(ctx context.Context, db *mongo.Database, cfg *configs.Config) error {
+	return dropIndexes(ctx, db.Collection(cfg.MongoDB.Collection),
+		productsNameIndex, productsPriceIndex, productsUpdatedIndex, productsSourcesIndex)
+}
+
+// name of index of not published events
+const outboxPendingIndex = "published_at_id"
+
+// createOutboxIndexes - not published events in order of ids for the relay
+func createOutboxIndexes(ctx context.Context, db *mongo.Database, cfg *configs.Config) error {
+	_, err := db.Collection(cfg.MongoDB.OutboxCollection).Indexes().CreateOne(ctx,
+		index(outboxPendingIndex, bson.D{{Key: "published_at", Value: 1}, {Key: "_id", Value: 1}}, false))
+	return err
+}
+
+func dropOutboxIndexes(ctx context.Context, db *mongo.Database, cfg *configs.Config) error {
+	return dropIndexes(ctx, db.Collection(cfg.MongoDB.OutboxCollection), outboxPendingIndex)
+}
+
+// backfillCurrencyAndSources - products saved before prices had currency get default currency,
+// products saved before prices were tracked per source get one source with their price if its source is known,
+// products without price_source came from fetches of unknown feeds, they keep their own price
+// and get the source from the first fetch of their feed
+func backfillCurrencyAndSources(ctx context.Context, db *mongo.Database, cfg *configs.Config) error {
+	coll := db.Collection(cfg.MongoDB.Collection)
+
+	withoutCurrency := bson.M{"$or": bson.A{
+		bson.M{"currency": bson.M{"$exists": false}},
+		bson.M{"currency": nil},
+		bson.M{"currency": ""},
+	}}
+	update := bson.M{"$set": bson.M{"currency": cfg.Currency.Default}}
+	if _, err := coll.UpdateMany(ctx, withoutCurrency, update); err != nil {
+		return err
+	}
+
+	// prices of sources and history have currency of product
+	for _, field := range []string{"sources", "history"} {
+		filter := bson.M{field: bson.M{"$elemMatch": bson.M{"currency": bson.M{"$exists": false}}}}
+		pipeline := bson.A{bson.M{"$set": bson.M{field: bson.M{"$map": bson.M{
+			"input": "$" + field,
+			"as":    "e",
+			"in": bson.M{"$mergeObjects": bson.A{
+				"$$e",
+				bson.M{"currency": bson.M{"$ifNull": bson.A{"$$e.currency", "$currency"}}},
+			}},
+		}}}}}
+		if _, err := coll.UpdateMany(ctx, filter, pipeline); err != nil {
+			return err
+		}
+	}
+
+	withoutSources := bson.M{
+		"$or": bson.A{
+			bson.M{"sources": bson.M{"$exists": false}},
+			bson.M{"sources": nil},
+			bson.M{"sources": bson.M{"$size": 0}},
+		},
+		"price_source": bson.M{"$nin": bson.A{nil, ""}},
+	}
+	pipeline := bson.A{
+		bson.M{"$set": bson.M{"sources": bson.A{bson.M{
+			"source":        "$price_source",
+			"price":         "$price",
+			"currency":      "$currency",
+			"updated":       "$updated",
+			"price_updates": bson.M{"$ifNull": bson.A{"$price_updates", 0}},
+			"discontinued":  nil,
+		}}}},
+	}
+	_, err := coll.UpdateMany(ctx, withoutSources, pipeline)
+	return err
+}
+
+// convertPricesToDecimal - prices saved as double are converted into Decimal128,
+// so ordering and conversion of prices use exact values
+func convertPricesToDecimal(ctx context.Context, db *mongo.Database, cfg *configs.Config) error {
+	coll := db.Collection(cfg.MongoDB.Collection)
+
+	isDouble := bson.M{"$type": "double"}
+	if _, err := coll.UpdateMany(ctx, bson.M{"price": isDouble},
+		bson.A{bson.M{"$set": bson.M{"price": bson.M{"$toDecimal": "$price"}}}}); err != nil {
+		return err
+	}
+
+	for _, field := range []string{"sources", "history"} {
+		pipeline := bson.A{bson.M{"$set": bson.M{field: bson.M{"$map": bson.M{
+			"input": "$" + field,
+			"as":    "e",
+			"in": bson.M{"$mergeObjects": bson.A{"$$e", bson.M{"price": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$type": "$$e.price"}, "double"}},
+				bson.M{"$toDecimal": "$$e.price"},
+				"$$e.price",
+			}}}}},
+		}}}}}
+		if _, err := coll.UpdateMany(ctx, bson.M{field + ".price": isDouble}, pipeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
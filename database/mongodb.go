@@ -2,28 +2,202 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/metrics"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"io/ioutil"
 	"time"
 )
 
-func NewMongoDBCollection(cfg *configs.Config) (*mongo.Collection, error) {
-	serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
+const (
+	// the longest waiting time between pings on start
+	maxConnectBackoff = 30 * time.Second
+	// timeout of connecting if it isn't set in config
+	defaultTimeout = 10 * time.Second
+)
+
+// NewMongoDBCollection - connect to MongoDB and return collection of products from config,
+// client of the collection must be disconnected by caller
+func NewMongoDBCollection(cfg *configs.Config, log *logrus.Entry) (*mongo.Collection, error) {
+	client, err := NewMongoDBClient(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(cfg.MongoDB.Database).Collection(cfg.MongoDB.Collection), nil
+}
 
-	clientOptions := options.Client().
-		ApplyURI(cfg.MongoDB.URI).
-		SetServerAPIOptions(serverAPIOptions)
+// NewMongoDBClient - connect to MongoDB with credentials, TLS and pool settings from config
+// and ping primary until it answers or attempts are over
+// return connected client, it must be disconnected by caller
+func NewMongoDBClient(cfg *configs.Config, log *logrus.Entry) (*mongo.Client, error) {
+	clientOptions, err := mongoDBOptions(&cfg.MongoDB)
+	if err != nil {
+		return nil, err
+	}
 
-	// timeout in seconds
-	timeout := time.Duration(cfg.MongoDB.Timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout(&cfg.MongoDB))
 	defer cancel()
 
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
+		return nil, fmt.Errorf("database: connect: %v", err)
+	}
+
+	if err := ping(client, &cfg.MongoDB, log); err != nil {
+		_ = client.Disconnect(context.Background())
 		return nil, err
 	}
+	return client, nil
+}
 
-	return client.Database(cfg.MongoDB.Database).Collection(cfg.MongoDB.Collection), nil
+// mongoDBOptions - return options of client from config
+func mongoDBOptions(cfg *configs.MongoDBConfig) (*options.ClientOptions, error) {
+	opts := options.Client().
+		ApplyURI(cfg.URI).
+		SetServerAPIOptions(options.ServerAPI(options.ServerAPIVersion1)).
+		SetConnectTimeout(timeout(cfg)).
+		SetRetryWrites(cfg.RetryWrites).
+		SetMonitor(mongoDBMonitor())
+
+	// credentials from config have priority over credentials from URI
+	if cfg.User != "" {
+		opts.SetAuth(options.Credential{
+			Username:      cfg.User,
+			Password:      cfg.Password,
+			PasswordSet:   cfg.Password != "",
+			AuthSource:    cfg.AuthSource,
+			AuthMechanism: cfg.AuthMechanism,
+		})
+	} else if cfg.AuthMechanism != "" {
+		// MONGODB-X509 takes user from client certificate
+		opts.SetAuth(options.Credential{
+			AuthSource:    cfg.AuthSource,
+			AuthMechanism: cfg.AuthMechanism,
+		})
+	}
+
+	if cfg.ReplicaSet != "" {
+		opts.SetReplicaSet(cfg.ReplicaSet)
+	}
+	if cfg.MinPoolSize > 0 {
+		opts.SetMinPoolSize(cfg.MinPoolSize)
+	}
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		opts.SetMaxConnIdleTime(time.Duration(cfg.MaxConnIdleTime) * time.Second)
+	}
+
+	if cfg.TLS || cfg.TLSCAFile != "" || cfg.TLSCertificateKeyFile != "" {
+		tlsConfig, err := mongoDBTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("database: %v", err)
+	}
+	return opts, nil
+}
+
+// mongoDBMonitor - return monitor which records every command as span and its latency in metrics
+func mongoDBMonitor() *event.CommandMonitor {
+	tracing := otelmongo.NewMonitor()
+
+	return &event.CommandMonitor{
+		Started: tracing.Started,
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			tracing.Succeeded(ctx, e)
+			metrics.MongoDBCommands.WithLabelValues(e.CommandName, "succeeded").Observe(time.Duration(e.DurationNanos).Seconds())
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			tracing.Failed(ctx, e)
+			metrics.MongoDBCommands.WithLabelValues(e.CommandName, "failed").Observe(time.Duration(e.DurationNanos).Seconds())
+		},
+	}
+}
+
+// mongoDBTLSConfig - return TLS config with certificate authorities and client certificate from files
+func mongoDBTLSConfig(cfg *configs.MongoDBConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.TLSInsecure,
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("database: TLS CA file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("database: TLS CA file %s has no certificates", cfg.TLSCAFile)
+		}
+	}
+
+	if cfg.TLSCertificateKeyFile != "" {
+		// certificate and private key are in the same file like in tlsCertificateKeyFile of URI
+		pem, err := ioutil.ReadFile(cfg.TLSCertificateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("database: TLS certificate file: %v", err)
+		}
+		cert, err := tls.X509KeyPair(pem, pem)
+		if err != nil {
+			return nil, fmt.Errorf("database: TLS certificate file: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// ping - ping primary until it answers, waiting time between attempts is doubled
+func ping(client *mongo.Client, cfg *configs.MongoDBConfig, log *logrus.Entry) error {
+	attempts := cfg.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := time.Duration(cfg.ConnectBackoff) * time.Second
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout(cfg))
+		err = client.Ping(ctx, readpref.Primary())
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		log.WithError(err).WithFields(logrus.Fields{
+			"attempt":  attempt,
+			"attempts": attempts,
+			"backoff":  backoff.String(),
+		}).Warn("database: ping failed")
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+	return fmt.Errorf("database: MongoDB is not available after %d attempts: %v", attempts, err)
+}
+
+// timeout - return timeout of connecting and pings from config
+func timeout(cfg *configs.MongoDBConfig) time.Duration {
+	if cfg.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(cfg.Timeout) * time.Second
 }
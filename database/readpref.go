@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
+)
+
+// the smallest max staleness in seconds allowed by MongoDB
+const minMaxStaleness = 90
+
+// read concerns which can be used outside of transactions
+var readConcerns = map[string]bool{
+	readconcern.Local().GetLevel():        true,
+	readconcern.Available().GetLevel():    true,
+	readconcern.Majority().GetLevel():     true,
+	readconcern.Linearizable().GetLevel(): true,
+}
+
+// NewMongoDBReadCollection - take collection and return its copy with read preference and read concern
+// from config for browsing of products, the original collection stays on primary for writes
+// and reads which must see the latest changes
+func NewMongoDBReadCollection(coll *mongo.Collection, cfg *configs.Config) (*mongo.Collection, error) {
+	mode, err := readpref.ModeFromString(cfg.MongoDB.ReadPreference)
+	if err != nil {
+		return nil, fmt.Errorf("database: %v", err)
+	}
+
+	var prefOpts []readpref.Option
+	if cfg.MongoDB.MaxStaleness > 0 {
+		if cfg.MongoDB.MaxStaleness < minMaxStaleness {
+			return nil, fmt.Errorf("database: max staleness must be at least %d seconds", minMaxStaleness)
+		}
+		prefOpts = append(prefOpts, readpref.WithMaxStaleness(time.Duration(cfg.MongoDB.MaxStaleness)*time.Second))
+	}
+	pref, err := readpref.New(mode, prefOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("database: read preference %q: %v", cfg.MongoDB.ReadPreference, err)
+	}
+
+	opts := options.Collection().SetReadPreference(pref)
+	if level := cfg.MongoDB.ReadConcern; level != "" {
+		if !readConcerns[level] {
+			return nil, fmt.Errorf("database: unknown read concern %q", level)
+		}
+		opts.SetReadConcern(readconcern.New(readconcern.Level(level)))
+	}
+
+	return coll.Clone(opts)
+}
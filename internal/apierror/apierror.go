@@ -0,0 +1,179 @@
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	bolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"io/fs"
+	"net"
+	"time"
+)
+
+// Domain - domain of ErrorInfo of errors of the service
+const Domain = "products.grpc-server"
+
+// reasons of ErrorInfo, clients can rely on them unlike messages of errors
+const (
+	// CSV file can't be downloaded
+	ReasonFeedUnreachable = "FEED_UNREACHABLE"
+	// CSV file has invalid line
+	ReasonFeedParseError = "FEED_PARSE_ERROR"
+	// database failed, its error is logged and not shown to clients
+	ReasonStorageUnavailable = "STORAGE_UNAVAILABLE"
+)
+
+// StorageRetryAfter - time after which call failed because of storage can be retried
+const StorageRetryAfter = 5 * time.Second
+
+// InvalidField - return InvalidArgument error with BadRequest violation of field of request,
+// message of error is description of violation
+func InvalidField(field, format string, args ...interface{}) error {
+	description := fmt.Sprintf(format, args...)
+	return withDetails(status.New(codes.InvalidArgument, description), &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}},
+	})
+}
+
+// Reason - return error with ErrorInfo of reason and metadata,
+// RetryInfo is added if retryAfter is positive
+func Reason(code codes.Code, reason, message string, metadata map[string]string, retryAfter time.Duration) error {
+	details := []protoiface.MessageV1{&errdetails.ErrorInfo{Reason: reason, Domain: Domain, Metadata: metadata}}
+	if retryAfter > 0 {
+		details = append(details, retryInfo(retryAfter))
+	}
+	return withDetails(status.New(code, message), details...)
+}
+
+// Retry - return error with RetryInfo, so client knows when the call can be retried
+func Retry(code codes.Code, message string, retryAfter time.Duration) error {
+	return withDetails(status.New(code, message), retryInfo(retryAfter))
+}
+
+// Storage - return Unavailable error of failed storage, it doesn't contain error of storage
+func Storage() error {
+	return Reason(codes.Unavailable, ReasonStorageUnavailable, "storage is unavailable", nil, StorageRetryAfter)
+}
+
+// Internal - return Internal error, it doesn't contain the error, which is a bug of the server
+func Internal() error {
+	return status.Error(codes.Internal, "internal error")
+}
+
+// FromError - return status errors as is, errors of context as Canceled or DeadlineExceeded,
+// errors of database drivers and network as failures of storage and other errors as Internal,
+// err is kept as cause of the last two for the log of the call
+func FromError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if IsContextError(err) {
+		return ContextError(err)
+	}
+	if IsStorageError(err) {
+		return WithCause(Storage(), err)
+	}
+	return WithCause(Internal(), err)
+}
+
+// causeError - status error with error which caused it, clients get only the status
+type causeError struct {
+	st    *status.Status
+	cause error
+}
+
+func (e *causeError) Error() string {
+	return e.st.Err().Error()
+}
+
+// GRPCStatus - status sent to client
+func (e *causeError) GRPCStatus() *status.Status {
+	return e.st
+}
+
+func (e *causeError) Unwrap() error {
+	return e.cause
+}
+
+// WithCause - take status error and error which caused it
+// return error with the same status which keeps the cause for logs
+func WithCause(err, cause error) error {
+	if cause == nil {
+		return err
+	}
+	return &causeError{st: status.Convert(err), cause: cause}
+}
+
+// Cause - return error which caused status error or nil if it is unknown
+func Cause(err error) error {
+	var ce *causeError
+	if errors.As(err, &ce) {
+		return ce.cause
+	}
+	return nil
+}
+
+// IsStorageError - return true if err is error of database driver or network, maybe wrapped by storage,
+// such calls can be retried later unlike failures caused by bugs
+func IsStorageError(err error) bool {
+	var (
+		netErr       net.Error
+		pathErr      *fs.PathError
+		serverErr    mongo.ServerError
+		selectionErr topology.ServerSelectionError
+		pqErr        *pq.Error
+	)
+	switch {
+	case errors.As(err, &netErr), errors.As(err, &pathErr):
+		return true
+	// MongoDB
+	case mongo.IsNetworkError(err), mongo.IsTimeout(err), errors.As(err, &serverErr),
+		errors.As(err, &selectionErr), errors.Is(err, mongo.ErrClientDisconnected):
+		return true
+	// PostgreSQL
+	case errors.As(err, &pqErr), errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, sql.ErrTxDone):
+		return true
+	// bolt
+	case errors.Is(err, bolt.ErrTimeout), errors.Is(err, bolt.ErrDatabaseNotOpen),
+		errors.Is(err, bolt.ErrDatabaseReadOnly), errors.Is(err, bolt.ErrTxClosed):
+		return true
+	}
+	return false
+}
+
+// IsContextError - return true if err is cancellation or expiration of context, maybe wrapped by storage
+func IsContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// ContextError - return Canceled or DeadlineExceeded error of wrapped error of context
+func ContextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, context.DeadlineExceeded.Error())
+	}
+	return status.Error(codes.Canceled, context.Canceled.Error())
+}
+
+func retryInfo(retryAfter time.Duration) *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}
+}
+
+// withDetails - return error of status with details, status without them if they can't be marshalled
+func withDetails(st *status.Status, details ...protoiface.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
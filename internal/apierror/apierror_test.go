@@ -0,0 +1,47 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+func TestFromError(t *testing.T) {
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantCode  codes.Code
+		wantCause bool
+	}{
+		{name: "status", err: status.Error(codes.NotFound, "product not found"), wantCode: codes.NotFound},
+		{name: "cancelled", err: fmt.Errorf("repos: Get: %w", context.Canceled), wantCode: codes.Canceled},
+		{name: "deadline", err: fmt.Errorf("repos: Get: %w", context.DeadlineExceeded), wantCode: codes.DeadlineExceeded},
+		{name: "network", err: fmt.Errorf("repos: Get: %w", netErr), wantCode: codes.Unavailable, wantCause: true},
+		{name: "postgres", err: fmt.Errorf("repos: List: %w", &pq.Error{Code: "57P01"}), wantCode: codes.Unavailable, wantCause: true},
+		{name: "bolt", err: fmt.Errorf("bolt: open products.db: %w", bolt.ErrTimeout), wantCode: codes.Unavailable, wantCause: true},
+		{name: "bug", err: fmt.Errorf("repos: Get: %w", errors.New("decoding product")), wantCode: codes.Internal, wantCause: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FromError(tt.err)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCause {
+				assert.Equal(t, tt.err, Cause(err))
+				// clients get only the status without the cause
+				assert.NotContains(t, status.Convert(err).Message(), tt.err.Error())
+			} else {
+				assert.Nil(t, Cause(err))
+			}
+		})
+	}
+}
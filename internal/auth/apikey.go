@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// APIKeyHeader - metadata key with API key of caller
+const APIKeyHeader = "x-api-key"
+
+// apiKey - API key from file with principal which uses it
+type apiKey struct {
+	Key   string   `json:"key"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// apiKeyAuthenticator - find principal by static API key
+type apiKeyAuthenticator struct {
+	// principals by SHA-256 of their keys, so keys are not compared byte by byte
+	principals map[[sha256.Size]byte]*Principal
+}
+
+// NewAPIKeyAuthenticator - take JSON file with list of keys, names and roles of their principals
+// return authenticator by key from x-api-key metadata
+func NewAPIKeyAuthenticator(path string) (Authenticator, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: API keys file: %v", err)
+	}
+	var keys []apiKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("auth: API keys file %s: %v", path, err)
+	}
+
+	a := &apiKeyAuthenticator{principals: make(map[[sha256.Size]byte]*Principal, len(keys))}
+	for _, k := range keys {
+		if k.Key == "" || k.Name == "" {
+			return nil, fmt.Errorf("auth: API keys file %s: key and name are required", path)
+		}
+		a.principals[sha256.Sum256([]byte(k.Key))] = &Principal{Name: k.Name, Roles: k.Roles}
+	}
+	return a, nil
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	key := metadataValue(ctx, APIKeyHeader)
+	if key == "" {
+		return nil, NoCredentialsError
+	}
+	p, ok := a.principals[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, errors.New("unknown API key")
+	}
+	return p, nil
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// NoCredentialsError - call has no credentials of the authenticator, so the next one is tried
+var NoCredentialsError = errors.New("no credentials")
+
+// Authenticator - find who calls by credentials of the call
+// return NoCredentialsError if call has no credentials of this kind
+// or other error if credentials are invalid
+type Authenticator interface {
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+// NewAuthenticators - return authenticators of methods from config in the same order
+// return nil if authentication is disabled
+func NewAuthenticators(cfg *configs.AuthConfig) ([]Authenticator, error) {
+	var result []Authenticator
+	for _, method := range cfg.Methods {
+		switch strings.TrimSpace(method) {
+		case "api_key":
+			a, err := NewAPIKeyAuthenticator(cfg.APIKeysFile)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, a)
+		case "jwt":
+			a, err := NewJWTAuthenticator(cfg.JWKSFile, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTRolesClaim)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, a)
+		case "mtls":
+			result = append(result, NewMTLSAuthenticator())
+		case "":
+		default:
+			return nil, fmt.Errorf("auth: unknown method %q", method)
+		}
+	}
+	return result, nil
+}
+
+// metadataValue - return the first value of key from metadata of incoming call
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
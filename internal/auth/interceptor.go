@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// Policy - roles which can call methods of protected services by full names of methods,
+// methods of protected services which are not in Roles can't be called by anyone,
+// methods of other services, like health checks, are public
+type Policy struct {
+	Services []string
+	Roles    map[string][]string
+	// methods which can be called without credentials
+	Anonymous map[string]bool
+}
+
+// protected - return true if method belongs to protected service
+func (p *Policy) protected(fullMethod string) bool {
+	for _, service := range p.Services {
+		if strings.HasPrefix(fullMethod, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize - find who calls method with authenticators and check that it can call the method
+// return ctx with principal or Unauthenticated or PermissionDenied error
+func authorize(ctx context.Context, authenticators []Authenticator, policy *Policy, fullMethod string) (context.Context, error) {
+	if !policy.protected(fullMethod) {
+		return ctx, nil
+	}
+
+	var principal *Principal
+	for _, a := range authenticators {
+		p, err := a.Authenticate(ctx)
+		if errors.Is(err, NoCredentialsError) {
+			continue
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "auth: %v", err)
+		}
+		principal = p
+		break
+	}
+
+	if principal == nil {
+		if !policy.Anonymous[fullMethod] {
+			return nil, status.Error(codes.Unauthenticated, "auth: credentials are required")
+		}
+		return NewContext(ctx, &Principal{Name: Anonymous}), nil
+	}
+
+	roles, ok := policy.Roles[fullMethod]
+	if !ok || !principal.HasAnyRole(roles) {
+		return nil, status.Errorf(codes.PermissionDenied, "auth: %s can't call %s", principal.Name, fullMethod)
+	}
+	return NewContext(ctx, principal), nil
+}
+
+// UnaryServerInterceptor - return interceptor which puts principal of call into ctx
+// or rejects call if principal isn't found or can't call the method
+func UnaryServerInterceptor(authenticators []Authenticator, policy *Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authorize(ctx, authenticators, policy, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor - the same as UnaryServerInterceptor for streaming calls
+func StreamServerInterceptor(authenticators []Authenticator, policy *Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), authenticators, policy, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream - stream of call with ctx which carries principal
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
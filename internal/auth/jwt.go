@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// signing algorithms of accepted tokens, tokens without signature are never accepted
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// jwk - public key from JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	// RSA key
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC key
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwtAuthenticator - find principal by signed token,
+// name of principal is subject of token and roles are in roles claim
+type jwtAuthenticator struct {
+	keys       map[string]crypto.PublicKey
+	issuer     string
+	audience   string
+	rolesClaim string
+	parser     *jwt.Parser
+}
+
+// NewJWTAuthenticator - take JSON Web Key Set file with public keys of tokens,
+// issuer and audience of tokens, they aren't checked if empty, and claim with roles
+// return authenticator by Bearer token from authorization metadata
+func NewJWTAuthenticator(jwksFile, issuer, audience, rolesClaim string) (Authenticator, error) {
+	data, err := ioutil.ReadFile(jwksFile)
+	if err != nil {
+		return nil, fmt.Errorf("auth: JWKS file: %v", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("auth: JWKS file %s: %v", jwksFile, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("auth: JWKS file %s: key %q: %v", jwksFile, k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("auth: JWKS file %s has no keys", jwksFile)
+	}
+
+	return &jwtAuthenticator{
+		keys:       keys,
+		issuer:     issuer,
+		audience:   audience,
+		rolesClaim: rolesClaim,
+		parser:     jwt.NewParser(jwt.WithValidMethods(jwtMethods)),
+	}, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	header := metadataValue(ctx, "authorization")
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return nil, NoCredentialsError
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(header[len(prefix):], claims, a.key); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("invalid token: exp is required")
+	}
+	if a.issuer != "" && !claims.VerifyIssuer(a.issuer, true) {
+		return nil, errors.New("invalid token: unexpected issuer")
+	}
+	if a.audience != "" && !claims.VerifyAudience(a.audience, true) {
+		return nil, errors.New("invalid token: unexpected audience")
+	}
+
+	name, _ := claims["sub"].(string)
+	if name == "" {
+		return nil, errors.New("invalid token: sub is required")
+	}
+	return &Principal{Name: name, Roles: stringsClaim(claims[a.rolesClaim])}, nil
+}
+
+// key - return public key by kid from header of token,
+// token without kid is checked by the only key of the set
+func (a *jwtAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// stringsClaim - return claim as list of strings, claim can be list or string separated by spaces
+func stringsClaim(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// publicKey - return RSA or EC public key
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unknown curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unknown key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt - decode unsigned big-endian integer in base64url without padding
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty number")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// mtlsAuthenticator - find principal by client certificate verified by the server,
+// name of principal is common name of certificate and roles are its organizational units
+type mtlsAuthenticator struct{}
+
+// NewMTLSAuthenticator - return authenticator by client certificate,
+// the server must serve TLS with certificate authorities of clients
+func NewMTLSAuthenticator() Authenticator {
+	return mtlsAuthenticator{}
+}
+
+func (mtlsAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, NoCredentialsError
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil, NoCredentialsError
+	}
+	// certificates which were sent but not verified are rejected by handshake,
+	// so only verified chains are here
+	if len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, errors.New("client certificate is not verified")
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return nil, errors.New("client certificate has no common name")
+	}
+	return &Principal{Name: cert.Subject.CommonName, Roles: cert.Subject.OrganizationalUnit}, nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import "context"
+
+// Anonymous - name of principal when caller is unknown
+const Anonymous = "anonymous"
+
+// Principal - who calls the service and roles which it has
+type Principal struct {
+	Name  string
+	Roles []string
+}
+
+// HasAnyRole - return true if principal has at least one of roles
+func (p *Principal) HasAnyRole(roles []string) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext - return copy of ctx which carries principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext - return principal from ctx or anonymous principal if ctx doesn't carry it
+func FromContext(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalKey{}).(*Principal); ok && p != nil {
+		return p
+	}
+	return &Principal{Name: Anonymous}
+}
@@ -0,0 +1,82 @@
+package csvserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// time for reading of headers of request, files are served without other timeouts,
+// because they can be big
+const readHeaderTimeout = 10 * time.Second
+
+// Server - HTTP server of CSV files from folder, files are served by their names, e.g. /products.csv
+type Server struct {
+	server *http.Server
+	lis    net.Listener
+}
+
+// New - take folder with CSV files and address of server
+// return server which is listening on the address, it serves requests after Run
+func New(folder, address string) (*Server, error) {
+	info, err := os.Stat(folder)
+	if err != nil {
+		return nil, fmt.Errorf("csv server: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("csv server: %s is not a folder", folder)
+	}
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("csv server: %v", err)
+	}
+	return &Server{
+		server: &http.Server{Handler: handler(folder), ReadHeaderTimeout: readHeaderTimeout},
+		lis:    lis,
+	}, nil
+}
+
+// Run - serve requests until server is shut down, return nil after Shutdown
+func (s *Server) Run() error {
+	if err := s.server.Serve(s.lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("csv server: %v", err)
+	}
+	return nil
+}
+
+// Shutdown - stop accepting connections and wait for running downloads until ctx is done,
+// connections are closed then
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		_ = s.server.Close()
+		return fmt.Errorf("csv server: shutdown: %v", err)
+	}
+	return nil
+}
+
+// handler - serve only CSV files from the folder without listing of the folder
+func handler(folder string) http.Handler {
+	files := http.FileServer(http.Dir(folder))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method is not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		if !strings.EqualFold(filepath.Ext(name), ".csv") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		files.ServeHTTP(w, r)
+	})
+}
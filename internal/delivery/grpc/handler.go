@@ -2,30 +2,54 @@ package grpc_handler
 
 import (
 	"context"
+	"errors"
 	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
 	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/mapping"
 	"github.com/ArturChopikian/grpc-server/internal/models"
 	"github.com/ArturChopikian/grpc-server/internal/usecase"
-	"log"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
 )
 
+// maximum number of ids and names in BatchGetProducts
+const maxBatchSize = 1000
+
 // ProductsHandlerInterface - represent productsHandler logic
 type ProductsHandlerInterface interface {
 	Fetch(ctx context.Context, req *pb.FetchRequest) (*pb.FetchResponse, error)
 	List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error)
+	StreamProducts(req *pb.ListRequest, stream pb.ProductsService_StreamProductsServer) error
+	GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error)
+	CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.Product, error)
+	UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.Product, error)
+	DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error)
+	RestoreProduct(ctx context.Context, req *pb.RestoreProductRequest) (*pb.Product, error)
+	BatchGetProducts(ctx context.Context, req *pb.BatchGetProductsRequest) (*pb.BatchGetProductsResponse, error)
+	GetPriceHistory(ctx context.Context, req *pb.GetPriceHistoryRequest) (*pb.GetPriceHistoryResponse, error)
+	ListExchangeRates(ctx context.Context, req *pb.ListExchangeRatesRequest) (*pb.ExchangeRates, error)
+	SetExchangeRates(ctx context.Context, req *pb.SetExchangeRatesRequest) (*pb.ExchangeRates, error)
 }
 
 // productsHandler - implement handlers for ProductsService
 type productsHandler struct {
 	productsUC usecase.ProductsUCInterface
+	ratesUC    usecase.RatesUCInterface
 	cfg        *configs.Config
+	log        *logrus.Entry
 	pb.UnimplementedProductsServiceServer
 }
 
 // NewProductsHandler - return pointer of productsHandler
-func NewProductsHandler(useCase *usecase.UseCases, cfg *configs.Config) *productsHandler {
+func NewProductsHandler(useCase *usecase.UseCases, cfg *configs.Config, log *logrus.Entry) *productsHandler {
 	return &productsHandler{
 		productsUC: useCase.ProductsUC,
+		ratesUC:    useCase.RatesUC,
+		log:        log,
 	}
 }
 
@@ -34,30 +58,367 @@ func NewProductsHandler(useCase *usecase.UseCases, cfg *configs.Config) *product
 // return - pb.FetchResponse with message "work" or error
 func (s *productsHandler) Fetch(ctx context.Context, req *pb.FetchRequest) (*pb.FetchResponse, error) {
 
-	err := s.productsUC.Fetch(ctx, req.GetUrl())
+	currency, err := parseCurrency("currency", req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &models.FetchOptions{
+		URL:          req.GetUrl(),
+		Source:       req.GetSourceId(),
+		Currency:     currency,
+		FullSnapshot: req.GetFullSnapshot(),
+	}
+
+	result, err := s.productsUC.Fetch(ctx, opts)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
 
 	return &pb.FetchResponse{
-		Message: "Work",
+		Message:      "Work",
+		Created:      result.Created,
+		Updated:      result.Updated,
+		Restored:     result.Restored,
+		Discontinued: result.Discontinued,
 	}, nil
 }
 
 // List - implement endless scroll
 // take pb.ListRequest with paging and ordering params
-// return list of products and number of the next page or error
+// return list of products, number and token of the next page or error
 func (s *productsHandler) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
 
-	products, err := s.productsUC.List(ctx, req.GetOrderBy(), req.GetPageSize(), req.GetPageNumber())
+	opts, err := listOptions(req)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
+	field, desc, _ := opts.Order()
+
+	products, err := s.productsUC.List(ctx, opts)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	var nextPageToken string
+	if len(products) > 0 {
+		nextPageToken = models.NewPageToken(products[len(products)-1], field, desc).Encode()
+	}
 
 	return &pb.ListResponse{
-		Products:       models.ProductsToGrpc(products),
+		Products:       mapping.ProductsToGrpc(products),
 		NextPageNumber: req.GetPageNumber() + 1,
+		NextPageToken:  nextPageToken,
+	}, nil
+}
+
+// StreamProducts - take pb.ListRequest like List and send all products of the list one by one,
+// page_size is size of batches read from database
+func (s *productsHandler) StreamProducts(req *pb.ListRequest, stream pb.ProductsService_StreamProductsServer) error {
+
+	opts, err := listOptions(req)
+	if err != nil {
+		return err
+	}
+
+	err = s.productsUC.Stream(stream.Context(), opts, func(p *models.Product) error {
+		return stream.Send(mapping.ProductToGrpc(p))
+	})
+	if err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+// listOptions - take pb.ListRequest and return options of list
+// or InvalidArgument error if ordering, page token or price mode are invalid
+func listOptions(req *pb.ListRequest) (*models.ListOptions, error) {
+
+	currency, err := parseCurrency("currency", req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &models.ListOptions{
+		OrderBy:             req.GetOrderBy(),
+		PageSize:            req.GetPageSize(),
+		PageNumber:          req.GetPageNumber(),
+		IncludeDiscontinued: req.GetIncludeDiscontinued(),
+		PriceMode:           models.PriceMode(req.GetPriceMode()),
+		Source:              req.GetSource(),
+		Currency:            currency,
+	}
+	if opts.PriceMode == models.PriceModeSource && opts.Source == "" {
+		return nil, apierror.InvalidField("source", "source is required for PRICE_MODE_SOURCE")
+	}
+
+	field, desc, err := opts.Order()
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if req.GetPageToken() != "" {
+		if opts.PageToken, err = models.ParsePageToken(req.GetPageToken()); err != nil {
+			return nil, statusError(err)
+		}
+		if opts.PageToken.Field != field || opts.PageToken.Desc != desc {
+			return nil, apierror.InvalidField("page_token", "order_by differs from order of page_token")
+		}
+	}
+	return opts, nil
+}
+
+// GetProduct - take pb.GetProductRequest with id or name of product
+// return product with history of its price or NotFound error
+func (s *productsHandler) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	product, err := s.getProduct(ctx, req.GetId(), req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.ProductToGrpc(product), nil
+}
+
+// GetPriceHistory - take pb.GetPriceHistoryRequest with id or name of product and source
+// return changes of price of this source or all changes if source is empty
+func (s *productsHandler) GetPriceHistory(ctx context.Context, req *pb.GetPriceHistoryRequest) (*pb.GetPriceHistoryResponse, error) {
+	product, err := s.getProduct(ctx, req.GetId(), req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetPriceHistoryResponse{
+		Changes: mapping.PriceChangesToGrpc(s.productsUC.PriceHistory(product, req.GetSource())),
+	}, nil
+}
+
+// getProduct - take id or name of product, id is used if it is not empty
+// return product or gRPC status error
+func (s *productsHandler) getProduct(ctx context.Context, hexId, name string) (*models.Product, error) {
+	var (
+		product *models.Product
+		err     error
+	)
+
+	switch {
+	case hexId != "":
+		id, idErr := parseId("id", hexId)
+		if idErr != nil {
+			return nil, idErr
+		}
+		product, err = s.productsUC.GetById(ctx, id)
+	case name != "":
+		product, err = s.productsUC.GetByName(ctx, name)
+	default:
+		return nil, apierror.InvalidField("id", "id or name is required")
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return product, nil
+}
+
+// CreateProduct - take pb.CreateProductRequest with name and price of new product
+// return created product or AlreadyExists error
+func (s *productsHandler) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.Product, error) {
+	p := req.GetProduct()
+	if err := validateName(p.GetName()); err != nil {
+		return nil, err
+	}
+	price, currency, err := parsePrice(p.GetPrice())
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.productsUC.Create(ctx, p.GetName(), price, currency)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return mapping.ProductToGrpc(product), nil
+}
+
+// UpdateProduct - take pb.UpdateProductRequest with product and update mask
+// update only fields from mask: "name", "price", or all of them if mask is empty
+// return updated product
+func (s *productsHandler) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.Product, error) {
+	p := req.GetProduct()
+
+	id, err := parseId("product.id", p.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = []string{"name", "price"}
+	}
+
+	var (
+		name     *string
+		price    *models.Decimal
+		currency string
+	)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			if err := validateName(p.GetName()); err != nil {
+				return nil, err
+			}
+			n := p.GetName()
+			name = &n
+		case "price":
+			pr, cur, err := parsePrice(p.GetPrice())
+			if err != nil {
+				return nil, err
+			}
+			price, currency = &pr, cur
+		default:
+			return nil, apierror.InvalidField("update_mask", "field %q can't be updated", path)
+		}
+	}
+
+	product, err := s.productsUC.Update(ctx, id, name, price, currency, req.GetSource())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return mapping.ProductToGrpc(product), nil
+}
+
+// DeleteProduct - take pb.DeleteProductRequest with id and mark product as discontinued
+func (s *productsHandler) DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error) {
+	id, err := parseId("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.productsUC.Delete(ctx, id); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.DeleteProductResponse{}, nil
+}
+
+// RestoreProduct - take pb.RestoreProductRequest with id and make discontinued product active
+// return restored product
+func (s *productsHandler) RestoreProduct(ctx context.Context, req *pb.RestoreProductRequest) (*pb.Product, error) {
+	id, err := parseId("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.productsUC.Restore(ctx, id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return mapping.ProductToGrpc(product), nil
+}
+
+// BatchGetProducts - take pb.BatchGetProductsRequest with ids and names, their number is checked by ValidateRequest
+// return found products and ids and names which products not found
+func (s *productsHandler) BatchGetProducts(ctx context.Context, req *pb.BatchGetProductsRequest) (*pb.BatchGetProductsResponse, error) {
+	ids := make([]primitive.ObjectID, 0, len(req.GetIds()))
+	for _, hex := range req.GetIds() {
+		id, err := parseId("ids", hex)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	products, err := s.productsUC.BatchGet(ctx, ids, req.GetNames())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// find what was requested but not returned
+	found := make(map[string]bool)
+	for _, p := range products {
+		found[p.Id.Hex()] = true
+		found[p.Name] = true
+	}
+
+	var notFound []string
+	for _, key := range append(req.GetIds(), req.GetNames()...) {
+		if !found[key] {
+			notFound = append(notFound, key)
+		}
+	}
+
+	return &pb.BatchGetProductsResponse{
+		Products: mapping.ProductsToGrpc(products),
+		NotFound: notFound,
 	}, nil
 }
+
+// parseId - take field of request and hex id of product from it
+// return ObjectID or InvalidArgument error
+func parseId(field, hex string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return primitive.NilObjectID, apierror.InvalidField(field, "invalid id %q", hex)
+	}
+	return id, nil
+}
+
+// validateName - return InvalidArgument error if name is empty
+func validateName(name string) error {
+	if name == "" {
+		return apierror.InvalidField("product.name", "name is required")
+	}
+	return nil
+}
+
+// parsePrice - take pb.Money, empty currency code means default currency
+// return amount and currency or InvalidArgument error if price is missing, invalid or negative
+func parsePrice(m *pb.Money) (models.Decimal, string, error) {
+	// nil isn't zero price, it is price which client forgot
+	if m == nil {
+		return models.Decimal{}, "", apierror.InvalidField("product.price", "price is required")
+	}
+	price, code, err := mapping.MoneyFromGrpc(m)
+	if err != nil {
+		return models.Decimal{}, "", apierror.InvalidField("product.price", "invalid price: %v", err)
+	}
+	if price.Sign() < 0 {
+		return models.Decimal{}, "", apierror.InvalidField("product.price", "price can't be negative")
+	}
+	currency, err := parseCurrency("product.price.currency_code", code)
+	if err != nil {
+		return models.Decimal{}, "", err
+	}
+	return price, currency, nil
+}
+
+// parseCurrency - take field of request and currency code from it, empty code means default currency
+// return code in upper case or InvalidArgument error if it isn't ISO 4217 code
+func parseCurrency(field, code string) (string, error) {
+	code = strings.ToUpper(code)
+	if code != "" && !models.ValidCurrency(code) {
+		return "", apierror.InvalidField(field, "invalid currency %q", code)
+	}
+	return code, nil
+}
+
+// statusError - convert error from use cases into gRPC status error,
+// clients get STORAGE_UNAVAILABLE without messages of failures of storage,
+// they are logged once with the call by logging interceptor
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, models.NotFoundProductError):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ProductExistsError):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, models.UnknownRateError):
+		return apierror.InvalidField("currency", err.Error())
+	case errors.Is(err, models.InvalidOrderError):
+		return apierror.InvalidField("order_by", err.Error())
+	case errors.Is(err, models.InvalidPageTokenError):
+		return apierror.InvalidField("page_token", err.Error())
+	case errors.Is(err, models.ReadOnlyRatesError):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return apierror.FromError(err)
+}
@@ -0,0 +1,153 @@
+package grpc_handler
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/memory"
+	"github.com/ArturChopikian/grpc-server/internal/usecase"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products.csv":
+			_, _ = w.Write([]byte("name,price,currency\napple,10.50\npear,3,eur\n"))
+		case "/snapshot.csv":
+			_, _ = w.Write([]byte("apple,11\n"))
+		case "/invalid.csv":
+			_, _ = w.Write([]byte("apple,ten\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		// requests sent before the checked one
+		before    []*pb.FetchRequest
+		req       *pb.FetchRequest
+		want      *pb.FetchResponse
+		wantCode  codes.Code
+		wantField string
+		// price of apple after fetch
+		wantApple string
+	}{
+		{
+			name:      "new products",
+			req:       &pb.FetchRequest{Url: server.URL + "/products.csv"},
+			want:      &pb.FetchResponse{Message: "Work", Created: 2},
+			wantApple: "10.5 USD",
+		},
+		{
+			name:      "currency of request in lower case",
+			req:       &pb.FetchRequest{Url: server.URL + "/products.csv", Currency: "gbp"},
+			want:      &pb.FetchResponse{Message: "Work", Created: 2},
+			wantApple: "10.5 GBP",
+		},
+		{
+			name:      "full snapshot",
+			before:    []*pb.FetchRequest{{Url: server.URL + "/products.csv", SourceId: "feed"}},
+			req:       &pb.FetchRequest{Url: server.URL + "/snapshot.csv", SourceId: "feed", FullSnapshot: true},
+			want:      &pb.FetchResponse{Message: "Work", Updated: 1, Discontinued: 1},
+			wantApple: "11 USD",
+		},
+		{
+			name:      "invalid currency",
+			req:       &pb.FetchRequest{Url: server.URL + "/products.csv", Currency: "dollars"},
+			wantCode:  codes.InvalidArgument,
+			wantField: "currency",
+		},
+		{
+			name:      "invalid url",
+			req:       &pb.FetchRequest{Url: "://products.csv"},
+			wantCode:  codes.InvalidArgument,
+			wantField: "url",
+		},
+		{
+			name:     "invalid file",
+			req:      &pb.FetchRequest{Url: server.URL + "/invalid.csv"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "missing file",
+			req:      &pb.FetchRequest{Url: server.URL + "/missing.csv"},
+			wantCode: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			handler, repos := newTestHandler(t)
+
+			for _, req := range tt.before {
+				_, err := handler.Fetch(ctx, req)
+				require.NoError(t, err)
+			}
+
+			resp, err := handler.Fetch(ctx, tt.req)
+			if tt.wantCode != codes.OK {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantCode, status.Code(err), "error: %v", err)
+				if tt.wantField != "" {
+					assert.Equal(t, []string{tt.wantField}, fields(err))
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.GetMessage(), resp.GetMessage())
+			assert.Equal(t, tt.want.GetCreated(), resp.GetCreated())
+			assert.Equal(t, tt.want.GetUpdated(), resp.GetUpdated())
+			assert.Equal(t, tt.want.GetRestored(), resp.GetRestored())
+			assert.Equal(t, tt.want.GetDiscontinued(), resp.GetDiscontinued())
+
+			apple, err := repos.Products.Get(ctx, "apple")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantApple, apple.Price.String()+" "+apple.Currency)
+		})
+	}
+}
+
+// newTestHandler - return handler with use cases on empty memory storage
+func newTestHandler(t *testing.T) (*productsHandler, *repository.Repository) {
+	t.Helper()
+
+	cfg, err := configs.NewConfig()
+	require.NoError(t, err)
+	cfg.Storage.Driver = "memory"
+	cfg.Currency.Default = "USD"
+
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	entry := logrus.NewEntry(log)
+
+	repos, err := repository.Open(cfg, entry)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repos.Close() })
+	return NewProductsHandler(usecase.NewUseCases(repos, cfg, entry), cfg, entry), repos
+}
+
+// fields - return fields of BadRequest details of gRPC error
+func fields(err error) []string {
+	var result []string
+	for _, detail := range status.Convert(err).Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				result = append(result, v.GetField())
+			}
+		}
+	}
+	return result
+}
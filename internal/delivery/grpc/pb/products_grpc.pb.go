@@ -18,10 +18,37 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ProductsServiceClient interface {
-	// Fetch
+	// Fetch - request external CSV-file with list of products by external url.
+	// CSV-file have view NAME,PRICE.
+	// Last price of each product save in the database.
+	// Also saves count of changing of product's price and time of last changing price.
 	Fetch(ctx context.Context, in *FetchRequest, opts ...grpc.CallOption) (*FetchResponse, error)
-	// List
+	// List - get page by page list of products with their prices, count of changing price and time of last update price.
+	// Provided all options for sorting for implementing it is like infinite scroll.
 	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// StreamProducts - stream all products of the list from page_token or from the beginning,
+	// page_size is size of batches read from database, page_number is ignored.
+	StreamProducts(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (ProductsService_StreamProductsClient, error)
+	// GetProduct - get one product by id or name together with history of its price.
+	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// CreateProduct - create new product manually, name of product must be unique.
+	CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// UpdateProduct - change fields of product which listed in update_mask.
+	// Manual change of price saved in the history of price with the name of editor.
+	UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// DeleteProduct - mark product as discontinued (soft delete) by id.
+	DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*DeleteProductResponse, error)
+	// RestoreProduct - make discontinued product active again.
+	RestoreProduct(ctx context.Context, in *RestoreProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// BatchGetProducts - get many products by ids and names in one call.
+	BatchGetProducts(ctx context.Context, in *BatchGetProductsRequest, opts ...grpc.CallOption) (*BatchGetProductsResponse, error)
+	// GetPriceHistory - get history of product's price of all or one source.
+	GetPriceHistory(ctx context.Context, in *GetPriceHistoryRequest, opts ...grpc.CallOption) (*GetPriceHistoryResponse, error)
+	// ListExchangeRates - get exchange rates which are used for converting prices.
+	ListExchangeRates(ctx context.Context, in *ListExchangeRatesRequest, opts ...grpc.CallOption) (*ExchangeRates, error)
+	// SetExchangeRates - add or replace exchange rates,
+	// fails if rates are loaded from the local file.
+	SetExchangeRates(ctx context.Context, in *SetExchangeRatesRequest, opts ...grpc.CallOption) (*ExchangeRates, error)
 }
 
 type productsServiceClient struct {
@@ -50,14 +77,154 @@ func (c *productsServiceClient) List(ctx context.Context, in *ListRequest, opts
 	return out, nil
 }
 
+func (c *productsServiceClient) StreamProducts(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (ProductsService_StreamProductsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ProductsService_ServiceDesc.Streams[0], "/products.ProductsService/StreamProducts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &productsServiceStreamProductsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ProductsService_StreamProductsClient interface {
+	Recv() (*Product, error)
+	grpc.ClientStream
+}
+
+type productsServiceStreamProductsClient struct {
+	grpc.ClientStream
+}
+
+func (x *productsServiceStreamProductsClient) Recv() (*Product, error) {
+	m := new(Product)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *productsServiceClient) GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/GetProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/CreateProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/UpdateProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*DeleteProductResponse, error) {
+	out := new(DeleteProductResponse)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/DeleteProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) RestoreProduct(ctx context.Context, in *RestoreProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/RestoreProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) BatchGetProducts(ctx context.Context, in *BatchGetProductsRequest, opts ...grpc.CallOption) (*BatchGetProductsResponse, error) {
+	out := new(BatchGetProductsResponse)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/BatchGetProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) GetPriceHistory(ctx context.Context, in *GetPriceHistoryRequest, opts ...grpc.CallOption) (*GetPriceHistoryResponse, error) {
+	out := new(GetPriceHistoryResponse)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/GetPriceHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) ListExchangeRates(ctx context.Context, in *ListExchangeRatesRequest, opts ...grpc.CallOption) (*ExchangeRates, error) {
+	out := new(ExchangeRates)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/ListExchangeRates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsServiceClient) SetExchangeRates(ctx context.Context, in *SetExchangeRatesRequest, opts ...grpc.CallOption) (*ExchangeRates, error) {
+	out := new(ExchangeRates)
+	err := c.cc.Invoke(ctx, "/products.ProductsService/SetExchangeRates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProductsServiceServer is the server API for ProductsService service.
 // All implementations must embed UnimplementedProductsServiceServer
 // for forward compatibility
 type ProductsServiceServer interface {
-	// Fetch
+	// Fetch - request external CSV-file with list of products by external url.
+	// CSV-file have view NAME,PRICE.
+	// Last price of each product save in the database.
+	// Also saves count of changing of product's price and time of last changing price.
 	Fetch(context.Context, *FetchRequest) (*FetchResponse, error)
-	// List
+	// List - get page by page list of products with their prices, count of changing price and time of last update price.
+	// Provided all options for sorting for implementing it is like infinite scroll.
 	List(context.Context, *ListRequest) (*ListResponse, error)
+	// StreamProducts - stream all products of the list from page_token or from the beginning,
+	// page_size is size of batches read from database, page_number is ignored.
+	StreamProducts(*ListRequest, ProductsService_StreamProductsServer) error
+	// GetProduct - get one product by id or name together with history of its price.
+	GetProduct(context.Context, *GetProductRequest) (*Product, error)
+	// CreateProduct - create new product manually, name of product must be unique.
+	CreateProduct(context.Context, *CreateProductRequest) (*Product, error)
+	// UpdateProduct - change fields of product which listed in update_mask.
+	// Manual change of price saved in the history of price with the name of editor.
+	UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error)
+	// DeleteProduct - mark product as discontinued (soft delete) by id.
+	DeleteProduct(context.Context, *DeleteProductRequest) (*DeleteProductResponse, error)
+	// RestoreProduct - make discontinued product active again.
+	RestoreProduct(context.Context, *RestoreProductRequest) (*Product, error)
+	// BatchGetProducts - get many products by ids and names in one call.
+	BatchGetProducts(context.Context, *BatchGetProductsRequest) (*BatchGetProductsResponse, error)
+	// GetPriceHistory - get history of product's price of all or one source.
+	GetPriceHistory(context.Context, *GetPriceHistoryRequest) (*GetPriceHistoryResponse, error)
+	// ListExchangeRates - get exchange rates which are used for converting prices.
+	ListExchangeRates(context.Context, *ListExchangeRatesRequest) (*ExchangeRates, error)
+	// SetExchangeRates - add or replace exchange rates,
+	// fails if rates are loaded from the local file.
+	SetExchangeRates(context.Context, *SetExchangeRatesRequest) (*ExchangeRates, error)
 	mustEmbedUnimplementedProductsServiceServer()
 }
 
@@ -71,6 +238,36 @@ func (UnimplementedProductsServiceServer) Fetch(context.Context, *FetchRequest)
 func (UnimplementedProductsServiceServer) List(context.Context, *ListRequest) (*ListResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
 }
+func (UnimplementedProductsServiceServer) StreamProducts(*ListRequest, ProductsService_StreamProductsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamProducts not implemented")
+}
+func (UnimplementedProductsServiceServer) GetProduct(context.Context, *GetProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProduct not implemented")
+}
+func (UnimplementedProductsServiceServer) CreateProduct(context.Context, *CreateProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateProduct not implemented")
+}
+func (UnimplementedProductsServiceServer) UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProduct not implemented")
+}
+func (UnimplementedProductsServiceServer) DeleteProduct(context.Context, *DeleteProductRequest) (*DeleteProductResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProduct not implemented")
+}
+func (UnimplementedProductsServiceServer) RestoreProduct(context.Context, *RestoreProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreProduct not implemented")
+}
+func (UnimplementedProductsServiceServer) BatchGetProducts(context.Context, *BatchGetProductsRequest) (*BatchGetProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetProducts not implemented")
+}
+func (UnimplementedProductsServiceServer) GetPriceHistory(context.Context, *GetPriceHistoryRequest) (*GetPriceHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPriceHistory not implemented")
+}
+func (UnimplementedProductsServiceServer) ListExchangeRates(context.Context, *ListExchangeRatesRequest) (*ExchangeRates, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListExchangeRates not implemented")
+}
+func (UnimplementedProductsServiceServer) SetExchangeRates(context.Context, *SetExchangeRatesRequest) (*ExchangeRates, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetExchangeRates not implemented")
+}
 func (UnimplementedProductsServiceServer) mustEmbedUnimplementedProductsServiceServer() {}
 
 // UnsafeProductsServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +317,189 @@ func _ProductsService_List_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProductsService_StreamProducts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProductsServiceServer).StreamProducts(m, &productsServiceStreamProductsServer{stream})
+}
+
+type ProductsService_StreamProductsServer interface {
+	Send(*Product) error
+	grpc.ServerStream
+}
+
+type productsServiceStreamProductsServer struct {
+	grpc.ServerStream
+}
+
+func (x *productsServiceStreamProductsServer) Send(m *Product) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ProductsService_GetProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).GetProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/GetProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).GetProduct(ctx, req.(*GetProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_CreateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).CreateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/CreateProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).CreateProduct(ctx, req.(*CreateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_UpdateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).UpdateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/UpdateProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).UpdateProduct(ctx, req.(*UpdateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_DeleteProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).DeleteProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/DeleteProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).DeleteProduct(ctx, req.(*DeleteProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_RestoreProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).RestoreProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/RestoreProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).RestoreProduct(ctx, req.(*RestoreProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_BatchGetProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).BatchGetProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/BatchGetProducts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).BatchGetProducts(ctx, req.(*BatchGetProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_GetPriceHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPriceHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).GetPriceHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/GetPriceHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).GetPriceHistory(ctx, req.(*GetPriceHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_ListExchangeRates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListExchangeRatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).ListExchangeRates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/ListExchangeRates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).ListExchangeRates(ctx, req.(*ListExchangeRatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductsService_SetExchangeRates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetExchangeRatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServiceServer).SetExchangeRates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/products.ProductsService/SetExchangeRates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServiceServer).SetExchangeRates(ctx, req.(*SetExchangeRatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProductsService_ServiceDesc is the grpc.ServiceDesc for ProductsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,7 +515,49 @@ var ProductsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "List",
 			Handler:    _ProductsService_List_Handler,
 		},
+		{
+			MethodName: "GetProduct",
+			Handler:    _ProductsService_GetProduct_Handler,
+		},
+		{
+			MethodName: "CreateProduct",
+			Handler:    _ProductsService_CreateProduct_Handler,
+		},
+		{
+			MethodName: "UpdateProduct",
+			Handler:    _ProductsService_UpdateProduct_Handler,
+		},
+		{
+			MethodName: "DeleteProduct",
+			Handler:    _ProductsService_DeleteProduct_Handler,
+		},
+		{
+			MethodName: "RestoreProduct",
+			Handler:    _ProductsService_RestoreProduct_Handler,
+		},
+		{
+			MethodName: "BatchGetProducts",
+			Handler:    _ProductsService_BatchGetProducts_Handler,
+		},
+		{
+			MethodName: "GetPriceHistory",
+			Handler:    _ProductsService_GetPriceHistory_Handler,
+		},
+		{
+			MethodName: "ListExchangeRates",
+			Handler:    _ProductsService_ListExchangeRates_Handler,
+		},
+		{
+			MethodName: "SetExchangeRates",
+			Handler:    _ProductsService_SetExchangeRates_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProducts",
+			Handler:       _ProductsService_StreamProducts_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pb/products.proto",
 }
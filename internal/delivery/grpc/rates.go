@@ -0,0 +1,54 @@
+package grpc_handler
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+)
+
+// ListExchangeRates - return all exchange rates
+func (s *productsHandler) ListExchangeRates(ctx context.Context, req *pb.ListExchangeRatesRequest) (*pb.ExchangeRates, error) {
+	rates, err := s.ratesUC.List(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return ratesToGrpc(rates), nil
+}
+
+// SetExchangeRates - take pb.SetExchangeRatesRequest with rates
+// add them or replace all rates if replace is true
+// return all rates after change
+func (s *productsHandler) SetExchangeRates(ctx context.Context, req *pb.SetExchangeRatesRequest) (*pb.ExchangeRates, error) {
+	rates := make(models.ExchangeRates, len(req.GetRates()))
+	for currency, rate := range req.GetRates() {
+		if !models.ValidCurrency(currency) {
+			return nil, apierror.InvalidField("rates", "invalid currency %q", currency)
+		}
+		value, err := models.ParseDecimal(rate)
+		if err != nil {
+			return nil, apierror.InvalidField("rates", "rate of %s: %v", currency, err)
+		}
+		if value.Sign() <= 0 {
+			return nil, apierror.InvalidField("rates", "rate of %s must be positive", currency)
+		}
+		rates[currency] = value
+	}
+
+	result, err := s.ratesUC.Set(ctx, rates, req.GetReplace())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return ratesToGrpc(result), nil
+}
+
+// ratesToGrpc - convert decimal rates into pb.ExchangeRates with exact decimal strings
+func ratesToGrpc(rates models.ExchangeRates) *pb.ExchangeRates {
+	result := make(map[string]string, len(rates))
+	for currency, rate := range rates {
+		result[currency] = rate.String()
+	}
+	return &pb.ExchangeRates{Rates: result}
+}
@@ -0,0 +1,98 @@
+package grpc_handler
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"testing"
+)
+
+func TestSetExchangeRates(t *testing.T) {
+	tests := []struct {
+		name string
+		// rates set before the checked request
+		before   map[string]string
+		req      *pb.SetExchangeRatesRequest
+		want     map[string]string
+		wantCode codes.Code
+	}{
+		{
+			name: "exact decimals",
+			req:  &pb.SetExchangeRatesRequest{Rates: map[string]string{"USD": "1", "EUR": "1.08", "JPY": "0.006666667"}},
+			want: map[string]string{"USD": "1", "EUR": "1.08", "JPY": "0.006666667"},
+		},
+		{
+			name: "normalized decimals",
+			req:  &pb.SetExchangeRatesRequest{Rates: map[string]string{"EUR": "1.0800", "GBP": "1.25e0"}},
+			want: map[string]string{"EUR": "1.08", "GBP": "1.25"},
+		},
+		{
+			name:   "add rates",
+			before: map[string]string{"USD": "1"},
+			req:    &pb.SetExchangeRatesRequest{Rates: map[string]string{"EUR": "1.08"}},
+			want:   map[string]string{"USD": "1", "EUR": "1.08"},
+		},
+		{
+			name:   "replace rates",
+			before: map[string]string{"USD": "1"},
+			req:    &pb.SetExchangeRatesRequest{Rates: map[string]string{"EUR": "1.08"}, Replace: true},
+			want:   map[string]string{"EUR": "1.08"},
+		},
+		{
+			name:     "more than 9 fractional digits",
+			req:      &pb.SetExchangeRatesRequest{Rates: map[string]string{"EUR": "1.0800000001"}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "not a number",
+			req:      &pb.SetExchangeRatesRequest{Rates: map[string]string{"EUR": "one"}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "zero rate",
+			req:      &pb.SetExchangeRatesRequest{Rates: map[string]string{"EUR": "0"}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "invalid currency",
+			req:      &pb.SetExchangeRatesRequest{Rates: map[string]string{"euro": "1.08"}},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			handler, _ := newTestHandler(t)
+
+			if tt.before != nil {
+				_, err := handler.SetExchangeRates(ctx, &pb.SetExchangeRatesRequest{Rates: tt.before})
+				require.NoError(t, err)
+			}
+
+			// rates pass through the wire format like in real calls
+			req := &pb.SetExchangeRatesRequest{}
+			data, err := proto.Marshal(tt.req)
+			require.NoError(t, err)
+			require.NoError(t, proto.Unmarshal(data, req))
+
+			resp, err := handler.SetExchangeRates(ctx, req)
+			if tt.wantCode != codes.OK {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantCode, status.Code(err), "error: %v", err)
+				assert.Equal(t, []string{"rates"}, fields(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, resp.GetRates())
+
+			listed, err := handler.ListExchangeRates(ctx, &pb.ListExchangeRatesRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, listed.GetRates())
+		})
+	}
+}
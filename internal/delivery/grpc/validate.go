@@ -0,0 +1,51 @@
+package grpc_handler
+
+import (
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"net/url"
+)
+
+// ValidateRequest - check fields of request which don't need use cases,
+// return InvalidArgument error with violation of field if request is invalid, requests of other services are valid
+func ValidateRequest(req interface{}) error {
+	switch r := req.(type) {
+	case *pb.FetchRequest:
+		return validateURL(r.GetUrl())
+	case *pb.ListRequest:
+		if r.GetPageSize() < 0 {
+			return apierror.InvalidField("page_size", "page_size can't be negative")
+		}
+		if r.GetPageNumber() < 0 {
+			return apierror.InvalidField("page_number", "page_number can't be negative")
+		}
+	case *pb.BatchGetProductsRequest:
+		if len(r.GetIds())+len(r.GetNames()) > maxBatchSize {
+			return apierror.InvalidField("ids", "no more than %d ids and names in one batch", maxBatchSize)
+		}
+	case *pb.CreateProductRequest:
+		if r.GetProduct() == nil {
+			return apierror.InvalidField("product", "product is required")
+		}
+	case *pb.UpdateProductRequest:
+		if r.GetProduct() == nil {
+			return apierror.InvalidField("product", "product is required")
+		}
+	}
+	return nil
+}
+
+// validateURL - return InvalidArgument error if url of CSV file is not absolute HTTP or HTTPS url
+func validateURL(rawURL string) error {
+	if rawURL == "" {
+		return apierror.InvalidField("url", "url is required")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return apierror.InvalidField("url", "url: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apierror.InvalidField("url", "url %q must be absolute http or https url", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package gateway
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/internal/auth"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/logging"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// OpenAPIPath - path of OpenAPI document of the gateway
+const OpenAPIPath = "/v1/openapi.json"
+
+// headers of HTTP request which are passed to calls as metadata
+var forwardedHeaders = []string{"authorization", auth.APIKeyHeader, logging.RequestIDHeader, "traceparent", "tracestate", "baggage"}
+
+// messages are written with names of fields from products.proto, fields with default values are written too
+var marshalOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// route - HTTP endpoint of method of ProductsService
+type route struct {
+	httpMethod string
+	path       string
+	rpc        string
+	summary    string
+	// request is read from JSON body, otherwise from query parameters
+	body       bool
+	newRequest func() proto.Message
+	call       func(ctx context.Context, srv pb.ProductsServiceServer, req proto.Message) (proto.Message, error)
+}
+
+// routes - endpoints of the gateway
+var routes = []route{
+	{
+		httpMethod: http.MethodPost,
+		path:       "/v1/products:fetch",
+		rpc:        "Fetch",
+		summary:    "Download CSV file of products and save their prices",
+		body:       true,
+		newRequest: func() proto.Message { return &pb.FetchRequest{} },
+		call: func(ctx context.Context, srv pb.ProductsServiceServer, req proto.Message) (proto.Message, error) {
+			return srv.Fetch(ctx, req.(*pb.FetchRequest))
+		},
+	},
+	{
+		httpMethod: http.MethodGet,
+		path:       "/v1/products",
+		rpc:        "List",
+		summary:    "Get page of products with their prices",
+		newRequest: func() proto.Message { return &pb.ListRequest{} },
+		call: func(ctx context.Context, srv pb.ProductsServiceServer, req proto.Message) (proto.Message, error) {
+			return srv.List(ctx, req.(*pb.ListRequest))
+		},
+	},
+}
+
+// Gateway - HTTP/JSON API which translates requests into calls of ProductsService,
+// calls pass the same interceptors as calls of gRPC clients
+type Gateway struct {
+	products    pb.ProductsServiceServer
+	interceptor grpc.UnaryServerInterceptor
+	maxBodySize int64
+	log         *logrus.Entry
+}
+
+// New - take handlers of ProductsService, chain of interceptors of the server and maximum size of request body,
+// 0 means no limit
+func New(products pb.ProductsServiceServer, interceptor grpc.UnaryServerInterceptor, maxBodySize int, log *logrus.Entry) *Gateway {
+	return &Gateway{
+		products:    products,
+		interceptor: interceptor,
+		maxBodySize: int64(maxBodySize),
+		log:         log,
+	}
+}
+
+// Handler - return HTTP handler with endpoints of routes and OpenAPI document
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		mux.Handle(rt.path, g.serve(rt))
+	}
+	mux.HandleFunc(OpenAPIPath, g.serveOpenAPI)
+	return mux
+}
+
+// serve - return handler which reads request of route, calls its method through interceptors
+// and writes response or error as JSON
+func (g *Gateway) serve(rt route) http.HandlerFunc {
+	fullMethod := "/" + pb.ProductsService_ServiceDesc.ServiceName + "/" + rt.rpc
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != rt.httpMethod {
+			w.Header().Set("Allow", rt.httpMethod)
+			g.writeStatus(w, http.StatusMethodNotAllowed, status.New(codes.Unimplemented, "method is not allowed"))
+			return
+		}
+
+		req := rt.newRequest()
+		if err := g.decode(w, r, req, rt.body); err != nil {
+			g.writeError(w, status.Errorf(codes.InvalidArgument, "gateway: %v", err))
+			return
+		}
+
+		stream := &transportStream{method: fullMethod}
+		ctx := grpc.NewContextWithServerTransportStream(incomingContext(r), stream)
+		info := &grpc.UnaryServerInfo{Server: g.products, FullMethod: fullMethod}
+		resp, err := g.interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return rt.call(ctx, g.products, req.(proto.Message))
+		})
+
+		stream.writeHeaders(w.Header())
+		if err != nil {
+			g.writeError(w, err)
+			return
+		}
+		g.writeMessage(w, http.StatusOK, resp.(proto.Message))
+	}
+}
+
+// decode - read request from JSON body or from query parameters
+func (g *Gateway) decode(w http.ResponseWriter, r *http.Request, req proto.Message, body bool) error {
+	if !body {
+		return populateQuery(req, r.URL.Query())
+	}
+
+	reader := r.Body
+	if g.maxBodySize > 0 {
+		reader = http.MaxBytesReader(w, r.Body, g.maxBodySize)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	return protojson.Unmarshal(data, req)
+}
+
+// incomingContext - return ctx of request with metadata from forwarded headers
+// and peer with address and TLS state of client, so callers are authenticated like gRPC clients
+func incomingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, header := range forwardedHeaders {
+		if values := r.Header.Values(header); len(values) > 0 {
+			md.Append(header, values...)
+		}
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	p := &peer.Peer{}
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		p.Addr = addr
+	}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{
+			State:          *r.TLS,
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+// writeError - write status of error with HTTP status code of its gRPC code,
+// time after which call can be retried is written to Retry-After header too
+func (g *Gateway) writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	if delay, ok := retryDelay(st); ok {
+		w.Header().Set("Retry-After", delay)
+	}
+	g.writeStatus(w, httpStatus(st.Code()), st)
+}
+
+func (g *Gateway) writeStatus(w http.ResponseWriter, code int, st *status.Status) {
+	g.writeMessage(w, code, st.Proto())
+}
+
+func (g *Gateway) writeMessage(w http.ResponseWriter, code int, msg proto.Message) {
+	data, err := marshalOptions.Marshal(msg)
+	if err != nil {
+		g.log.WithError(err).Error("gateway: response is not written")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+// transportStream - collect header and trailer metadata which interceptors and handlers set
+// with grpc.SetHeader and grpc.SetTrailer, they are written as HTTP headers of response
+type transportStream struct {
+	method string
+
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *transportStream) Method() string {
+	return s.method
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *transportStream) SetTrailer(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+// writeHeaders - add header and trailer metadata to headers of response
+func (s *transportStream) writeHeaders(h http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, md := range []metadata.MD{s.header, s.trailer} {
+		for key, values := range md {
+			for _, v := range values {
+				h.Add(key, v)
+			}
+		}
+	}
+}
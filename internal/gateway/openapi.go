@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"strings"
+)
+
+// schema - JSON object of OpenAPI document
+type schema map[string]interface{}
+
+// schemas of well-known types which are written by protojson as strings
+var wellKnownSchemas = map[protoreflect.FullName]schema{
+	"google.protobuf.Timestamp": {"type": "string", "format": "date-time"},
+	"google.protobuf.FieldMask": {"type": "string"},
+	"google.protobuf.Any": {
+		"type":                 "object",
+		"properties":           schema{"@type": schema{"type": "string"}},
+		"additionalProperties": true,
+	},
+}
+
+// serveOpenAPI - write OpenAPI document of routes
+func (g *Gateway) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	data, err := json.MarshalIndent(openAPI(), "", "  ")
+	if err != nil {
+		g.writeError(w, status.Errorf(codes.Internal, "gateway: OpenAPI document: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// openAPI - return OpenAPI 3 document of routes, schemas of requests and responses
+// are generated from descriptors of messages of products.proto
+func openAPI() schema {
+	service := pb.File_pb_products_proto.Services().ByName("ProductsService")
+	components := schema{
+		"Status": schema{
+			"type": "object",
+			"properties": schema{
+				"code":    schema{"type": "integer", "format": "int32"},
+				"message": schema{"type": "string"},
+				"details": schema{"type": "array", "items": wellKnownSchemas["google.protobuf.Any"]},
+			},
+		},
+	}
+
+	paths := schema{}
+	for _, rt := range routes {
+		method := service.Methods().ByName(protoreflect.Name(rt.rpc))
+		operation := schema{
+			"operationId": rt.rpc,
+			"summary":     rt.summary,
+			"tags":        []string{string(service.Name())},
+			"responses": schema{
+				"200": jsonContent("OK", messageRef(method.Output(), components)),
+				"default": jsonContent("Error, code is gRPC status code",
+					schema{"$ref": "#/components/schemas/Status"}),
+			},
+		}
+		if rt.body {
+			body := jsonContent("", messageRef(method.Input(), components))
+			body["required"] = true
+			operation["requestBody"] = body
+		} else {
+			operation["parameters"] = queryParameters(method.Input(), components)
+		}
+
+		item, ok := paths[rt.path].(schema)
+		if !ok {
+			item = schema{}
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.httpMethod)] = operation
+	}
+
+	return schema{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":   "ProductsService HTTP/JSON gateway",
+			"version": "v1",
+		},
+		"paths":      paths,
+		"components": schema{"schemas": components},
+	}
+}
+
+// jsonContent - return response or request body with JSON of schema
+func jsonContent(description string, s schema) schema {
+	return schema{
+		"description": description,
+		"content":     schema{"application/json": schema{"schema": s}},
+	}
+}
+
+// queryParameters - return query parameters of fields of request
+func queryParameters(md protoreflect.MessageDescriptor, components schema) []schema {
+	var parameters []schema
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		var s schema
+		if fd.IsMap() {
+			// map entries are passed like key:value
+			s = schema{"type": "array", "items": schema{"type": "string", "pattern": "^[^:]+:.*$"}}
+		} else {
+			s = fieldSchema(fd, components)
+		}
+		parameter := schema{
+			"name":   string(fd.Name()),
+			"in":     "query",
+			"schema": s,
+		}
+		if fd.IsList() || fd.IsMap() {
+			parameter["explode"] = true
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+// messageRef - return reference to schema of message and add it to components with messages of its fields
+func messageRef(md protoreflect.MessageDescriptor, components schema) schema {
+	if s, ok := wellKnownSchemas[md.FullName()]; ok {
+		return s
+	}
+
+	name := string(md.FullName())
+	ref := schema{"$ref": "#/components/schemas/" + name}
+	if _, ok := components[name]; ok {
+		return ref
+	}
+
+	properties := schema{}
+	s := schema{"type": "object", "properties": properties}
+	// added before fields, so recursive messages refer to it
+	components[name] = s
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		properties[string(fd.Name())] = fieldSchema(fd, components)
+	}
+	return ref
+}
+
+// fieldSchema - return schema of field like protojson writes it
+func fieldSchema(fd protoreflect.FieldDescriptor, components schema) schema {
+	switch {
+	case fd.IsMap():
+		return schema{"type": "object", "additionalProperties": kindSchema(fd.MapValue(), components)}
+	case fd.IsList():
+		return schema{"type": "array", "items": kindSchema(fd, components)}
+	}
+	return kindSchema(fd, components)
+}
+
+// kindSchema - return schema of single value of field, 64-bit integers are strings in JSON
+func kindSchema(fd protoreflect.FieldDescriptor, components schema) schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return schema{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return schema{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return schema{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return schema{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return schema{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return schema{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return schema{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return schema{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return schema{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageRef(fd.Message(), components)
+	}
+	return schema{"type": "string"}
+}
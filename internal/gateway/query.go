@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// populateQuery - set fields of request from query parameters with names of fields from products.proto
+// or their JSON names, repeated fields take all values of parameter and map fields take values like key:value,
+// for example ?page_size=20&order_by=price:-1&include_discontinued=true
+func populateQuery(req proto.Message, query url.Values) error {
+	m := req.ProtoReflect()
+	fields := m.Descriptor().Fields()
+
+	for name, values := range query {
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return fmt.Errorf("unknown parameter %q", name)
+		}
+
+		switch {
+		case fd.IsMap():
+			entries := m.Mutable(fd).Map()
+			for _, v := range values {
+				i := strings.Index(v, ":")
+				if i < 0 {
+					return fmt.Errorf("parameter %s: value %q isn't key:value", name, v)
+				}
+				key, err := parseScalar(fd.MapKey(), v[:i])
+				if err != nil {
+					return fmt.Errorf("parameter %s: %v", name, err)
+				}
+				value, err := parseScalar(fd.MapValue(), v[i+1:])
+				if err != nil {
+					return fmt.Errorf("parameter %s: %v", name, err)
+				}
+				entries.Set(key.MapKey(), value)
+			}
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			for _, v := range values {
+				value, err := parseScalar(fd, v)
+				if err != nil {
+					return fmt.Errorf("parameter %s: %v", name, err)
+				}
+				list.Append(value)
+			}
+		default:
+			if len(values) > 1 {
+				return fmt.Errorf("parameter %s is repeated", name)
+			}
+			value, err := parseScalar(fd, values[0])
+			if err != nil {
+				return fmt.Errorf("parameter %s: %v", name, err)
+			}
+			m.Set(fd, value)
+		}
+	}
+	return nil
+}
+
+// parseScalar - parse value of field of scalar type, enum value is its name or number
+func parseScalar(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || fd.Enum().Values().ByNumber(protoreflect.EnumNumber(v)) == nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown value %q of %s", s, fd.Enum().Name())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("type %s can't be set from query", fd.Kind())
+}
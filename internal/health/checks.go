@@ -0,0 +1,23 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// TCPCheck - return check which connects to the address, it is used for servers without health API
+func TCPCheck(name, address string, critical bool) Check {
+	return Check{
+		Name:     name,
+		Critical: critical,
+		Fn: func(ctx context.Context) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", address)
+			if err != nil {
+				return fmt.Errorf("%s is not reachable: %v", address, err)
+			}
+			return conn.Close()
+		},
+	}
+}
@@ -0,0 +1,219 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// interval and timeout of checks if they aren't set in config
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 2 * time.Second
+)
+
+// Check - dependency of the server, Fn returns error if dependency doesn't work
+// server isn't ready while critical dependency doesn't work, other dependencies are only reported
+type Check struct {
+	Name     string
+	Critical bool
+	Fn       func(ctx context.Context) error
+}
+
+// status - result of the last check of dependency
+type status struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Checker - check dependencies periodically and report their statuses
+// by gRPC health service and HTTP endpoints:
+// overall status is reported for services passed to NewChecker and for "",
+// status of each dependency is reported for its name
+type Checker struct {
+	server   *health.Server
+	services []string
+	checks   []Check
+	interval time.Duration
+	timeout  time.Duration
+	log      *logrus.Entry
+
+	mu       sync.RWMutex
+	statuses map[string]status
+	ready    bool
+	shutdown bool
+}
+
+// NewChecker - take names of gRPC services and checks of dependencies
+// return checker where all services are NOT_SERVING until the first check
+func NewChecker(cfg *configs.Config, log *logrus.Entry, services []string, checks ...Check) *Checker {
+	c := &Checker{
+		server:   health.NewServer(),
+		services: append([]string{""}, services...),
+		checks:   checks,
+		interval: time.Duration(cfg.Health.Interval) * time.Second,
+		timeout:  time.Duration(cfg.Health.Timeout) * time.Second,
+		log:      log,
+		statuses: make(map[string]status),
+	}
+	if c.interval <= 0 {
+		c.interval = defaultInterval
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultTimeout
+	}
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return c
+}
+
+// Server - return gRPC health service
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// Run - check dependencies immediately and then every interval until ctx is canceled
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown - report all services as NOT_SERVING, so load balancers stop sending calls,
+// statuses aren't changed by checks after it
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.shutdown = true
+	c.ready = false
+	c.server.Shutdown()
+}
+
+// check - run all checks in parallel and update statuses
+func (c *Checker) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	statuses := make(map[string]status, len(c.checks))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			s := status{Status: healthpb.HealthCheckResponse_SERVING.String(), Critical: check.Critical}
+			if err := check.Fn(ctx); err != nil {
+				s.Status = healthpb.HealthCheckResponse_NOT_SERVING.String()
+				s.Error = err.Error()
+			}
+
+			mu.Lock()
+			statuses[check.Name] = s
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, check := range c.checks {
+		if s := statuses[check.Name]; s.Error != "" && check.Critical {
+			ready = false
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.shutdown {
+		return
+	}
+	for name, s := range statuses {
+		if old, ok := c.statuses[name]; !ok || old.Error != s.Error {
+			if s.Error != "" {
+				c.log.WithField("check", name).Warnf("health: %s", s.Error)
+			} else if ok {
+				c.log.WithField("check", name).Info("health: available again")
+			}
+		}
+		c.server.SetServingStatus(name, servingStatus(s.Error == ""))
+	}
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, servingStatus(ready))
+	}
+	c.statuses = statuses
+	c.ready = ready
+}
+
+// Handler - return HTTP handler with endpoints:
+// /healthz - liveness, 200 while process is able to answer
+// /readyz - readiness, 200 if critical dependencies work and server isn't shutting down, otherwise 503,
+// both return statuses of dependencies as JSON
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		c.write(w, true)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		ready := c.ready
+		c.mu.RUnlock()
+		c.write(w, ready)
+	})
+	return mux
+}
+
+// write - write statuses of dependencies with 200 if ok is true or 503
+func (c *Checker) write(w http.ResponseWriter, ok bool) {
+	c.mu.RLock()
+	body := struct {
+		Status       string            `json:"status"`
+		ShuttingDown bool              `json:"shutting_down,omitempty"`
+		Dependencies map[string]status `json:"dependencies"`
+	}{
+		Status:       servingStatus(c.ready).String(),
+		ShuttingDown: c.shutdown,
+		Dependencies: c.statuses,
+	}
+	data, err := json.Marshal(body)
+	c.mu.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write(data)
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// NewContext - return copy of ctx which carries id of request
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID - return id of request from ctx or empty string if ctx doesn't carry it
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHook - add request id and trace id from ctx of entry to its fields,
+// so all lines of one call can be found by them
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(e *logrus.Entry) error {
+	if e.Context == nil {
+		return nil
+	}
+	if id := RequestID(e.Context); id != "" {
+		e.Data["request_id"] = id
+	}
+	if span := trace.SpanContextFromContext(e.Context); span.HasTraceID() {
+		e.Data["trace_id"] = span.TraceID().String()
+	}
+	return nil
+}
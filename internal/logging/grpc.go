@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// RequestIDHeader - metadata key of request id, it is taken from caller or generated
+// and returned to caller in header of response
+const RequestIDHeader = "x-request-id"
+
+// UnaryServerInterceptor - return interceptor which puts request id into ctx of call
+// and logs every finished call with its method, status code and duration
+func UnaryServerInterceptor(log *logrus.Entry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = NewContext(ctx, requestID(ctx))
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, RequestID(ctx)))
+
+		started := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, log, info.FullMethod, started, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor - the same as UnaryServerInterceptor for streaming calls
+func StreamServerInterceptor(log *logrus.Entry) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := NewContext(ss.Context(), requestID(ss.Context()))
+		_ = ss.SetHeader(metadata.Pairs(RequestIDHeader, RequestID(ctx)))
+
+		started := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, log, info.FullMethod, started, err)
+		return err
+	}
+}
+
+// serverStream - stream of call with ctx which carries request id
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestID - return request id from metadata of caller or new random id
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" {
+			return ids[0]
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// logCall - log finished call, it is the only log of error of the call:
+// failures of the server are logged as errors with their cause, mistakes of callers as warnings,
+// successful, cancelled calls and missing or existing products as info
+func logCall(ctx context.Context, log *logrus.Entry, method string, started time.Time, err error) {
+	code := status.Code(err)
+	entry := log.WithContext(ctx).WithFields(logrus.Fields{
+		"method":      method,
+		"code":        code.String(),
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	if cause := apierror.Cause(err); cause != nil {
+		entry = entry.WithField("cause", cause.Error())
+	}
+
+	switch code {
+	case codes.OK, codes.Canceled, codes.NotFound, codes.AlreadyExists:
+		entry.Info("call finished")
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		entry.Error("call failed")
+	default:
+		entry.Warn("call rejected")
+	}
+}
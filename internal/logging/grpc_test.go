@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantLevel logrus.Level
+		wantMsg   string
+		wantCause string
+	}{
+		{name: "ok", wantLevel: logrus.InfoLevel, wantMsg: "call finished"},
+		{name: "not found", err: status.Error(codes.NotFound, "product not found"), wantLevel: logrus.InfoLevel, wantMsg: "call finished"},
+		{name: "cancelled", err: status.Error(codes.Canceled, "context canceled"), wantLevel: logrus.InfoLevel, wantMsg: "call finished"},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "invalid request"), wantLevel: logrus.WarnLevel, wantMsg: "call rejected"},
+		{name: "permission denied", err: status.Error(codes.PermissionDenied, "forbidden"), wantLevel: logrus.WarnLevel, wantMsg: "call rejected"},
+		{
+			name:      "internal with cause",
+			err:       apierror.FromError(errors.New("decode product: bad document")),
+			wantLevel: logrus.ErrorLevel,
+			wantMsg:   "call failed",
+			wantCause: "decode product: bad document",
+		},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "storage unavailable"), wantLevel: logrus.ErrorLevel, wantMsg: "call failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := test.NewNullLogger()
+			interceptor := UnaryServerInterceptor(logrus.NewEntry(log))
+			info := &grpc.UnaryServerInfo{FullMethod: "/products.ProductService/List"}
+
+			_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				assert.NotEmpty(t, RequestID(ctx))
+				return nil, tt.err
+			})
+			assert.Equal(t, status.Code(tt.err), status.Code(err))
+
+			// the call is logged exactly once
+			require.Len(t, hook.AllEntries(), 1)
+			entry := hook.LastEntry()
+			assert.Equal(t, tt.wantLevel, entry.Level)
+			assert.Equal(t, tt.wantMsg, entry.Message)
+			assert.Equal(t, info.FullMethod, entry.Data["method"])
+			assert.Equal(t, status.Code(tt.err).String(), entry.Data["code"])
+			if tt.wantCause != "" {
+				assert.Equal(t, tt.wantCause, entry.Data["cause"])
+			} else {
+				assert.NotContains(t, entry.Data, "cause")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// New - return logger with format, level and outputs from config: stdout, stderr and rotated file,
+// every line has prefix from config as "app" field and request id of the call if it is logged with its ctx
+// logs of standard library logger, which is used by dependencies, are written to the same logger
+// return function which closes file output, it must be called on shutdown
+func New(cfg *configs.LogConfig) (*logrus.Entry, func() error, error) {
+	logger := logrus.New()
+
+	formatter, err := newFormatter(cfg.Format)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.SetFormatter(formatter)
+
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, fmt.Errorf("logging: %v", err)
+	}
+	logger.SetLevel(level)
+
+	var (
+		writers []io.Writer
+		closeFn = func() error { return nil }
+	)
+	for _, output := range cfg.Outputs {
+		switch strings.TrimSpace(output) {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		case "file":
+			// file is rotated when it grows to max size, old files are removed by their number and age
+			file := &lumberjack.Logger{
+				Filename:   cfg.File,
+				MaxSize:    cfg.MaxSize,
+				MaxBackups: cfg.MaxBackups,
+				MaxAge:     cfg.MaxAge,
+				Compress:   cfg.Compress,
+			}
+			writers = append(writers, file)
+			closeFn = file.Close
+		case "":
+		default:
+			return nil, nil, fmt.Errorf("logging: unknown output %q", output)
+		}
+	}
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+	logger.SetOutput(io.MultiWriter(writers...))
+
+	logger.AddHook(contextHook{})
+
+	entry := logrus.NewEntry(logger)
+	if cfg.Prefix != "" {
+		entry = entry.WithField("app", cfg.Prefix)
+	}
+
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(entry.WriterLevel(logrus.InfoLevel))
+
+	return entry, closeFn, nil
+}
+
+// Startup - return logger of failures which happen before config is loaded and New is called,
+// lines are written to stderr with format and prefix of LOG_FORMAT and LOG_PREFIX env variables,
+// so they look like lines of the logger from config
+func Startup() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	formatter, err := newFormatter(os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		formatter, _ = newFormatter("")
+	}
+	logger.SetFormatter(formatter)
+
+	prefix, ok := os.LookupEnv("LOG_PREFIX")
+	if !ok {
+		prefix = "server"
+	}
+	entry := logrus.NewEntry(logger)
+	if prefix != "" {
+		entry = entry.WithField("app", prefix)
+	}
+	return entry
+}
+
+// newFormatter - return formatter of lines by name: logfmt (default) or json
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "json":
+		return &logrus.JSONFormatter{}, nil
+	case "", "logfmt":
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}, nil
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", format)
+	}
+}
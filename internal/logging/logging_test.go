@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestStartup(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantFormat logrus.Formatter
+		wantApp    interface{}
+	}{
+		{name: "defaults", wantFormat: &logrus.TextFormatter{}, wantApp: "server"},
+		{name: "json", env: map[string]string{"LOG_FORMAT": "json", "LOG_PREFIX": "products"}, wantFormat: &logrus.JSONFormatter{}, wantApp: "products"},
+		{name: "unknown format", env: map[string]string{"LOG_FORMAT": "xml"}, wantFormat: &logrus.TextFormatter{}, wantApp: "server"},
+		{name: "without prefix", env: map[string]string{"LOG_PREFIX": ""}, wantFormat: &logrus.TextFormatter{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"LOG_FORMAT", "LOG_PREFIX"} {
+				t.Setenv(key, tt.env[key])
+				if _, ok := tt.env[key]; !ok {
+					os.Unsetenv(key)
+				}
+			}
+
+			entry := Startup()
+			assert.IsType(t, tt.wantFormat, entry.Logger.Formatter)
+			assert.Equal(t, os.Stderr, entry.Logger.Out)
+			assert.Equal(t, tt.wantApp, entry.Data["app"])
+		})
+	}
+}
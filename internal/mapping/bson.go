@@ -0,0 +1,28 @@
+package mapping
+
+import (
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ProductToBSON - convert product into document of products collection
+// times are saved with milliseconds precision, prices as Decimal128
+func ProductToBSON(p *models.Product) (bson.Raw, error) {
+	data, err := bson.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("mapping: encoding product %s: %v", p.Id.Hex(), err)
+	}
+	return data, nil
+}
+
+// ProductFromBSON - convert document of products collection into product
+// documents saved by older versions are accepted: prices as double,
+// without sources, currency or revision
+func ProductFromBSON(doc bson.Raw) (*models.Product, error) {
+	product := &models.Product{}
+	if err := bson.Unmarshal(doc, product); err != nil {
+		return nil, fmt.Errorf("mapping: decoding product: %v", err)
+	}
+	return product, nil
+}
@@ -0,0 +1,222 @@
+package mapping
+
+import (
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// ProductToGrpc - convert product into pb.Product with all its fields
+func ProductToGrpc(p *models.Product) *pb.Product {
+	return &pb.Product{
+		Id:           p.Id.Hex(),
+		Name:         p.Name,
+		Price:        MoneyToGrpc(p.Price, p.Currency),
+		Updated:      TimeToGrpc(p.Updated),
+		PriceUpdates: p.PriceUpdates,
+		History:      PriceChangesToGrpc(p.History),
+		Discontinued: OptionalTimeToGrpc(p.Discontinued),
+		Sources:      SourcePricesToGrpc(p.Sources),
+		PriceSource:  p.PriceSource,
+		Revision:     p.Revision,
+	}
+}
+
+// ProductFromGrpc - convert pb.Product into product
+// return error if id, price or timestamps are invalid
+func ProductFromGrpc(p *pb.Product) (*models.Product, error) {
+	result := &models.Product{
+		Name:         p.GetName(),
+		PriceSource:  p.GetPriceSource(),
+		PriceUpdates: p.GetPriceUpdates(),
+		Revision:     p.GetRevision(),
+	}
+
+	var err error
+	if p.GetId() != "" {
+		if result.Id, err = primitive.ObjectIDFromHex(p.GetId()); err != nil {
+			return nil, fmt.Errorf("mapping: product id: %v", err)
+		}
+	}
+	if result.Price, result.Currency, err = MoneyFromGrpc(p.GetPrice()); err != nil {
+		return nil, fmt.Errorf("mapping: product price: %v", err)
+	}
+	if result.Updated, err = TimeFromGrpc(p.GetUpdated()); err != nil {
+		return nil, fmt.Errorf("mapping: product updated: %v", err)
+	}
+	if result.Discontinued, err = OptionalTimeFromGrpc(p.GetDiscontinued()); err != nil {
+		return nil, fmt.Errorf("mapping: product discontinued: %v", err)
+	}
+	if result.Sources, err = SourcePricesFromGrpc(p.GetSources()); err != nil {
+		return nil, err
+	}
+	if result.History, err = PriceChangesFromGrpc(p.GetHistory()); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ProductsToGrpc - convert list of products into list of pb.Product
+func ProductsToGrpc(p []*models.Product) []*pb.Product {
+	var result []*pb.Product
+
+	for _, product := range p {
+		result = append(result, ProductToGrpc(product))
+	}
+
+	return result
+}
+
+// SourcePricesToGrpc - convert prices of sources into list of pb.SourcePrice
+func SourcePricesToGrpc(sources []*models.SourcePrice) []*pb.SourcePrice {
+	var result []*pb.SourcePrice
+
+	for _, sp := range sources {
+		result = append(result, &pb.SourcePrice{
+			Source:       sp.Source,
+			Price:        MoneyToGrpc(sp.Price, sp.Currency),
+			Updated:      TimeToGrpc(sp.Updated),
+			PriceUpdates: sp.PriceUpdates,
+			Discontinued: OptionalTimeToGrpc(sp.Discontinued),
+		})
+	}
+
+	return result
+}
+
+// SourcePricesFromGrpc - convert list of pb.SourcePrice into prices of sources
+func SourcePricesFromGrpc(sources []*pb.SourcePrice) ([]*models.SourcePrice, error) {
+	var result []*models.SourcePrice
+
+	for _, sp := range sources {
+		price, currency, err := MoneyFromGrpc(sp.GetPrice())
+		if err != nil {
+			return nil, fmt.Errorf("mapping: price of source %q: %v", sp.GetSource(), err)
+		}
+		updated, err := TimeFromGrpc(sp.GetUpdated())
+		if err != nil {
+			return nil, fmt.Errorf("mapping: updated of source %q: %v", sp.GetSource(), err)
+		}
+		discontinued, err := OptionalTimeFromGrpc(sp.GetDiscontinued())
+		if err != nil {
+			return nil, fmt.Errorf("mapping: discontinued of source %q: %v", sp.GetSource(), err)
+		}
+
+		result = append(result, &models.SourcePrice{
+			Source:       sp.GetSource(),
+			Price:        price,
+			Currency:     currency,
+			Updated:      updated,
+			PriceUpdates: sp.GetPriceUpdates(),
+			Discontinued: discontinued,
+		})
+	}
+
+	return result, nil
+}
+
+// PriceChangesToGrpc - convert history of price into list of pb.PriceChange
+func PriceChangesToGrpc(changes []*models.PriceChange) []*pb.PriceChange {
+	var result []*pb.PriceChange
+
+	for _, c := range changes {
+		result = append(result, &pb.PriceChange{
+			Price:     MoneyToGrpc(c.Price, c.Currency),
+			Changed:   TimeToGrpc(c.Changed),
+			Kind:      c.Kind,
+			Principal: c.Principal,
+			Source:    c.Source,
+		})
+	}
+
+	return result
+}
+
+// PriceChangesFromGrpc - convert list of pb.PriceChange into history of price
+func PriceChangesFromGrpc(changes []*pb.PriceChange) ([]*models.PriceChange, error) {
+	var result []*models.PriceChange
+
+	for i, c := range changes {
+		price, currency, err := MoneyFromGrpc(c.GetPrice())
+		if err != nil {
+			return nil, fmt.Errorf("mapping: price of change %d: %v", i, err)
+		}
+		changed, err := TimeFromGrpc(c.GetChanged())
+		if err != nil {
+			return nil, fmt.Errorf("mapping: time of change %d: %v", i, err)
+		}
+
+		result = append(result, &models.PriceChange{
+			Price:     price,
+			Currency:  currency,
+			Source:    c.GetSource(),
+			Changed:   changed,
+			Kind:      c.GetKind(),
+			Principal: c.GetPrincipal(),
+		})
+	}
+
+	return result, nil
+}
+
+// MoneyToGrpc - take amount and its currency, return pb.Money
+func MoneyToGrpc(amount models.Decimal, currency string) *pb.Money {
+	return &pb.Money{
+		CurrencyCode: currency,
+		Units:        amount.Units,
+		Nanos:        amount.Nanos,
+	}
+}
+
+// MoneyFromGrpc - take pb.Money, return its amount and currency
+// nil is zero amount without currency, return error if units and nanos are invalid
+func MoneyFromGrpc(m *pb.Money) (models.Decimal, string, error) {
+	amount, err := models.NewDecimal(m.GetUnits(), m.GetNanos())
+	if err != nil {
+		return models.Decimal{}, "", err
+	}
+	return amount, m.GetCurrencyCode(), nil
+}
+
+// TimeToGrpc - convert time into timestamp with Unix seconds, zero time is nil
+func TimeToGrpc(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// TimeFromGrpc - convert timestamp into time in UTC, nil is zero time
+func TimeFromGrpc(t *timestamppb.Timestamp) (time.Time, error) {
+	if t == nil {
+		return time.Time{}, nil
+	}
+	if err := t.CheckValid(); err != nil {
+		return time.Time{}, err
+	}
+	return t.AsTime(), nil
+}
+
+// OptionalTimeToGrpc - convert optional time like Product.Discontinued, nil is nil
+func OptionalTimeToGrpc(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// OptionalTimeFromGrpc - convert timestamp into optional time, nil is nil
+func OptionalTimeFromGrpc(t *timestamppb.Timestamp) (*time.Time, error) {
+	if t == nil {
+		return nil, nil
+	}
+	result, err := TimeFromGrpc(t)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
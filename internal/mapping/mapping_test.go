@@ -0,0 +1,485 @@
+package mapping
+
+import (
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestTimeToGrpc(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name    string
+		time    time.Time
+		seconds int64
+		nanos   int32
+		isNil   bool
+	}{
+		{name: "zero time", time: time.Time{}, isNil: true},
+		{name: "unix epoch", time: time.Unix(0, 0), seconds: 0},
+		// regression: seconds of minute were sent instead of Unix seconds
+		{name: "seconds since epoch", time: time.Date(2021, 3, 4, 15, 16, 17, 0, time.UTC), seconds: 1614870977},
+		{name: "nanos", time: time.Date(2021, 3, 4, 15, 16, 17, 123456789, time.UTC), seconds: 1614870977, nanos: 123456789},
+		{name: "other location", time: time.Date(2021, 3, 4, 18, 16, 17, 0, moscow), seconds: 1614870977},
+		{name: "before epoch", time: time.Date(1969, 12, 31, 23, 59, 59, 500000000, time.UTC), seconds: -1, nanos: 500000000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TimeToGrpc(tt.time)
+			if tt.isNil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, tt.seconds, got.GetSeconds())
+			assert.Equal(t, tt.nanos, got.GetNanos())
+
+			back, err := TimeFromGrpc(got)
+			require.NoError(t, err)
+			assert.True(t, tt.time.Equal(back), "expected %v, got %v", tt.time, back)
+			assert.Equal(t, time.UTC, back.Location())
+		})
+	}
+}
+
+func TestTimeFromGrpc(t *testing.T) {
+	tests := []struct {
+		name    string
+		ts      *timestamppb.Timestamp
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "nil", ts: nil, want: time.Time{}},
+		{name: "valid", ts: &timestamppb.Timestamp{Seconds: 1614870977, Nanos: 1}, want: time.Date(2021, 3, 4, 15, 16, 17, 1, time.UTC)},
+		{name: "negative nanos", ts: &timestamppb.Timestamp{Seconds: 1, Nanos: -1}, wantErr: true},
+		{name: "nanos out of range", ts: &timestamppb.Timestamp{Nanos: 1000000000}, wantErr: true},
+		{name: "after year 9999", ts: &timestamppb.Timestamp{Seconds: 253402300800}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TimeFromGrpc(tt.ts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOptionalTime(t *testing.T) {
+	got, err := OptionalTimeFromGrpc(OptionalTimeToGrpc(nil))
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	discontinued := time.Date(2021, 3, 4, 15, 16, 17, 5, time.UTC)
+	got, err = OptionalTimeFromGrpc(OptionalTimeToGrpc(&discontinued))
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, discontinued, *got)
+
+	_, err = OptionalTimeFromGrpc(&timestamppb.Timestamp{Nanos: -1})
+	assert.Error(t, err)
+}
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		currency string
+		units    int64
+		nanos    int32
+	}{
+		{name: "zero", amount: "0", currency: "USD"},
+		{name: "integer", amount: "42", currency: "USD", units: 42},
+		{name: "fractional", amount: "19.90", currency: "EUR", units: 19, nanos: 900000000},
+		{name: "smallest fraction", amount: "0.000000001", currency: "USD", nanos: 1},
+		{name: "largest fraction", amount: "0.999999999", currency: "USD", nanos: 999999999},
+		{name: "negative", amount: "-5", currency: "USD", units: -5},
+		{name: "negative fractional", amount: "-1.5", currency: "USD", units: -1, nanos: -500000000},
+		{name: "negative fraction only", amount: "-0.25", currency: "GBP", nanos: -250000000},
+		{name: "without currency", amount: "7.07", units: 7, nanos: 70000000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := models.ParseDecimal(tt.amount)
+			require.NoError(t, err)
+
+			m := MoneyToGrpc(amount, tt.currency)
+			assert.Equal(t, tt.units, m.GetUnits())
+			assert.Equal(t, tt.nanos, m.GetNanos())
+			assert.Equal(t, tt.currency, m.GetCurrencyCode())
+
+			back, currency, err := MoneyFromGrpc(m)
+			require.NoError(t, err)
+			assert.True(t, amount.Equal(back), "expected %s, got %s", amount, back)
+			assert.Equal(t, tt.currency, currency)
+		})
+	}
+}
+
+func TestMoneyFromGrpc(t *testing.T) {
+	tests := []struct {
+		name     string
+		money    *pb.Money
+		want     models.Decimal
+		currency string
+		wantErr  bool
+	}{
+		{name: "nil", money: nil},
+		{name: "valid", money: &pb.Money{CurrencyCode: "USD", Units: 3, Nanos: 10}, want: models.Decimal{Units: 3, Nanos: 10}, currency: "USD"},
+		{name: "positive units and negative nanos", money: &pb.Money{Units: 1, Nanos: -1}, wantErr: true},
+		{name: "negative units and positive nanos", money: &pb.Money{Units: -1, Nanos: 1}, wantErr: true},
+		{name: "nanos out of range", money: &pb.Money{Nanos: 1000000000}, wantErr: true},
+		{name: "negative nanos out of range", money: &pb.Money{Nanos: -1000000000}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, currency, err := MoneyFromGrpc(tt.money)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.currency, currency)
+		})
+	}
+}
+
+func TestProductRoundTrip(t *testing.T) {
+	updated := time.Date(2021, 3, 4, 15, 16, 17, 123456789, time.UTC)
+	discontinued := updated.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		product *models.Product
+	}{
+		{
+			name:    "empty",
+			product: &models.Product{},
+		},
+		{
+			name: "new product",
+			product: &models.Product{
+				Id:          primitive.NewObjectID(),
+				Name:        "apple",
+				Price:       models.Decimal{Units: 10, Nanos: 500000000},
+				Currency:    "USD",
+				PriceSource: models.ManualSource,
+				Updated:     updated,
+				Revision:    1,
+			},
+		},
+		{
+			name: "discontinued product with sources and history",
+			product: &models.Product{
+				Id:           primitive.NewObjectID(),
+				Name:         "pear",
+				Price:        models.Decimal{Units: -1, Nanos: -1},
+				Currency:     "EUR",
+				PriceSource:  "feed",
+				Updated:      updated,
+				PriceUpdates: 2,
+				Revision:     5,
+				Discontinued: &discontinued,
+				Sources: []*models.SourcePrice{
+					{Source: "feed", Price: models.Decimal{Units: -1, Nanos: -1}, Currency: "EUR", Updated: updated, PriceUpdates: 2, Discontinued: &discontinued},
+					{Source: models.ManualSource, Price: models.Decimal{Nanos: 1}, Currency: "USD"},
+				},
+				History: []*models.PriceChange{
+					{Price: models.Decimal{Units: 3}, Currency: "EUR", Source: "feed", Changed: updated.Add(-time.Hour), Kind: "create", Principal: "fetch"},
+					{Price: models.Decimal{Units: -1, Nanos: -1}, Currency: "EUR", Source: "feed", Changed: updated, Kind: "update", Principal: "alice"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProductFromGrpc(ProductToGrpc(tt.product))
+			require.NoError(t, err)
+			assert.Equal(t, tt.product, got)
+		})
+	}
+}
+
+func TestProductFromGrpcErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		product *pb.Product
+	}{
+		{name: "invalid id", product: &pb.Product{Id: "apple"}},
+		{name: "invalid price", product: &pb.Product{Price: &pb.Money{Units: 1, Nanos: -1}}},
+		{name: "invalid updated", product: &pb.Product{Updated: &timestamppb.Timestamp{Nanos: -1}}},
+		{name: "invalid discontinued", product: &pb.Product{Discontinued: &timestamppb.Timestamp{Nanos: -1}}},
+		{name: "invalid price of source", product: &pb.Product{Sources: []*pb.SourcePrice{{Source: "feed", Price: &pb.Money{Nanos: 1000000000}}}}},
+		{name: "invalid time of change", product: &pb.Product{History: []*pb.PriceChange{{Changed: &timestamppb.Timestamp{Nanos: -1}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ProductFromGrpc(tt.product)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestProductBSONRoundTrip(t *testing.T) {
+	updated := time.Date(2021, 3, 4, 15, 16, 17, 123000000, time.UTC)
+	discontinued := updated.Add(time.Hour)
+
+	product := &models.Product{
+		Id:           primitive.NewObjectID(),
+		Name:         "pear",
+		Price:        models.Decimal{Units: -12, Nanos: -340000000},
+		Currency:     "EUR",
+		PriceSource:  "feed",
+		Updated:      updated,
+		PriceUpdates: 1,
+		Revision:     2,
+		Discontinued: &discontinued,
+		Sources: []*models.SourcePrice{
+			{Source: "feed", Price: models.Decimal{Units: -12, Nanos: -340000000}, Currency: "EUR", Updated: updated, PriceUpdates: 1},
+		},
+		History: []*models.PriceChange{
+			{Price: models.Decimal{Nanos: 1}, Currency: "EUR", Source: "feed", Changed: updated, Kind: "create", Principal: "fetch"},
+		},
+	}
+
+	doc, err := ProductToBSON(product)
+	require.NoError(t, err)
+	got, err := ProductFromBSON(doc)
+	require.NoError(t, err)
+	assert.Equal(t, product, got)
+}
+
+// quickConfig - number of generated values of each property
+var quickConfig = &quick.Config{MaxCount: 500}
+
+// randomProduct - generated product with sources and history
+type randomProduct struct {
+	product *models.Product
+}
+
+func (randomProduct) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(randomProduct{product: generateProduct(r, size, false)})
+}
+
+// bsonProduct - generated product which times have milliseconds precision and prices of sources are stamped by jobs
+type bsonProduct struct {
+	product *models.Product
+}
+
+func (bsonProduct) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(bsonProduct{product: generateProduct(r, size, true)})
+}
+
+func generateProduct(r *rand.Rand, size int, stored bool) *models.Product {
+	p := &models.Product{
+		Id:           generateId(r),
+		Name:         generateString(r, size),
+		Price:        generateDecimal(r),
+		Currency:     generateString(r, 3),
+		PriceSource:  generateString(r, size),
+		Updated:      generateTime(r, stored),
+		PriceUpdates: r.Uint32(),
+		Revision:     r.Uint64(),
+		Discontinued: generateOptionalTime(r, stored),
+	}
+	if stored {
+		// BSON has no unsigned integers
+		p.Revision = uint64(r.Int63())
+	}
+	for i := r.Intn(size/10 + 1); i > 0; i-- {
+		sp := &models.SourcePrice{
+			Source:       generateString(r, size),
+			Price:        generateDecimal(r),
+			Currency:     generateString(r, 3),
+			Updated:      generateTime(r, stored),
+			PriceUpdates: r.Uint32(),
+			Discontinued: generateOptionalTime(r, stored),
+		}
+		if stored && r.Intn(2) == 0 {
+			sp.Seen = generateId(r)
+		}
+		p.Sources = append(p.Sources, sp)
+	}
+	for i := r.Intn(size/10 + 1); i > 0; i-- {
+		p.History = append(p.History, &models.PriceChange{
+			Price:     generateDecimal(r),
+			Currency:  generateString(r, 3),
+			Source:    generateString(r, size),
+			Changed:   generateTime(r, stored),
+			Kind:      generateString(r, 8),
+			Principal: generateString(r, size),
+		})
+	}
+	return p
+}
+
+func generateId(r *rand.Rand) primitive.ObjectID {
+	var id primitive.ObjectID
+	r.Read(id[:])
+	return id
+}
+
+// generateString - return valid UTF-8 string up to n runes, proto strings must be valid UTF-8
+func generateString(r *rand.Rand, n int) string {
+	runes := make([]rune, r.Intn(n+1))
+	for i := range runes {
+		runes[i] = rune(r.Intn(0xD000)) // below surrogates
+	}
+	return string(runes)
+}
+
+// generateDecimal - return decimal with units and nanos of the same sign, small values and fractions are frequent
+func generateDecimal(r *rand.Rand) models.Decimal {
+	var units int64
+	switch r.Intn(3) {
+	case 0:
+		units = r.Int63n(1000)
+	case 1:
+		units = r.Int63()
+	}
+	nanos := r.Int31n(models.NanosPerUnit)
+	if r.Intn(2) == 0 {
+		units, nanos = -units, -nanos
+	}
+	return models.Decimal{Units: units, Nanos: nanos}
+}
+
+// generateTime - return time in UTC between years 1000 and 9000 with nanoseconds or milliseconds
+func generateTime(r *rand.Rand, millis bool) time.Time {
+	if r.Intn(10) == 0 {
+		return time.Time{}
+	}
+	min := time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	max := time.Date(9000, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	t := time.Unix(min+r.Int63n(max-min), r.Int63n(int64(time.Second))).UTC()
+	if millis {
+		t = t.Truncate(time.Millisecond)
+	}
+	return t
+}
+
+func generateOptionalTime(r *rand.Rand, millis bool) *time.Time {
+	if r.Intn(2) == 0 {
+		return nil
+	}
+	t := generateTime(r, millis)
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func TestProductProtoProperty(t *testing.T) {
+	roundTrip := func(p randomProduct) bool {
+		got, err := ProductFromGrpc(ProductToGrpc(p.product))
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		return assert.Equal(t, p.product, got)
+	}
+	if err := quick.Check(roundTrip, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductBSONProperty(t *testing.T) {
+	roundTrip := func(p bsonProduct) bool {
+		doc, err := ProductToBSON(p.product)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		got, err := ProductFromBSON(doc)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		return assert.Equal(t, p.product, got)
+	}
+	if err := quick.Check(roundTrip, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMoneyProperty(t *testing.T) {
+	roundTrip := func(units int64, nanos int32, currency string) bool {
+		amount, err := models.NewDecimal(units, nanos)
+		if err != nil {
+			// only valid amounts are accepted back
+			_, _, err := MoneyFromGrpc(&pb.Money{Units: units, Nanos: nanos})
+			return err != nil
+		}
+		m := MoneyToGrpc(amount, currency)
+		got, gotCurrency, err := MoneyFromGrpc(m)
+		return err == nil && got == amount && gotCurrency == currency
+	}
+	if err := quick.Check(roundTrip, quickConfig); err != nil {
+		t.Error(err)
+	}
+
+	// generated valid amounts go through Money and through their decimal string
+	decimals := func(r randomDecimal) bool {
+		got, _, err := MoneyFromGrpc(MoneyToGrpc(r.value, "USD"))
+		if err != nil || got != r.value {
+			return false
+		}
+		parsed, err := models.ParseDecimal(r.value.String())
+		return err == nil && parsed == r.value
+	}
+	if err := quick.Check(decimals, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+// randomDecimal - generated valid decimal
+type randomDecimal struct {
+	value models.Decimal
+}
+
+func (randomDecimal) Generate(r *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(randomDecimal{value: generateDecimal(r)})
+}
+
+func TestTimestampProperty(t *testing.T) {
+	roundTrip := func(seconds int64, nanos uint32) bool {
+		// valid range of Timestamp: years 1-9999
+		const minSeconds, maxSeconds = -62135596800, 253402300799
+		seconds = minSeconds + int64(uint64(seconds)%uint64(maxSeconds-minSeconds+1))
+		in := time.Unix(seconds, int64(nanos%models.NanosPerUnit)).UTC()
+
+		ts := TimeToGrpc(in)
+		if ts.GetSeconds() != in.Unix() || ts.GetNanos() != int32(in.Nanosecond()) {
+			return false
+		}
+		got, err := TimeFromGrpc(ts)
+		if err != nil || !got.Equal(in) {
+			return false
+		}
+
+		optional, err := OptionalTimeFromGrpc(OptionalTimeToGrpc(&in))
+		return err == nil && optional != nil && optional.Equal(in)
+	}
+	if err := quick.Check(roundTrip, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
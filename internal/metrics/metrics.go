@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+// namespace of all metrics of the server
+const namespace = "products"
+
+// results of rows of fetched file
+const (
+	RowCreated   = "created"
+	RowUpdated   = "updated"
+	RowRestored  = "restored"
+	RowUnchanged = "unchanged"
+	RowRejected  = "rejected"
+)
+
+// metrics of Fetch pipeline
+var (
+	// FetchRows - rows of fetched files by result, rate of all results is rows per second,
+	// restored row with new price is counted as restored and as updated
+	FetchRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "rows_total",
+		Help:      "Rows of fetched CSV files by result: created, updated, restored, unchanged or rejected.",
+	}, []string{"result"})
+
+	// FetchDiscontinued - products discontinued by fetches of full snapshots
+	FetchDiscontinued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "discontinued_total",
+		Help:      "Products discontinued because they are missing in full snapshots.",
+	})
+
+	// FetchStageQueue - rows sent to stage of pipeline and not accepted by it yet,
+	// growing queue of stage means that the stage is the slowest one
+	FetchStageQueue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "stage_queue_depth",
+		Help:      "Rows waiting to be accepted by stage of fetch pipeline: check, create or update.",
+	}, []string{"stage"})
+
+	// FetchDownloadBytes - bytes of downloaded files
+	FetchDownloadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "download_bytes_total",
+		Help:      "Bytes of CSV files downloaded by fetches.",
+	})
+
+	// FetchDownloadDuration - time from request of file until it is read to the end
+	FetchDownloadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "download_duration_seconds",
+		Help:      "Time of downloading and reading CSV file.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	})
+
+	// FetchDuration - time of whole fetch by status of its job
+	FetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "duration_seconds",
+		Help:      "Time of fetches by status: succeeded, failed or interrupted.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"status"})
+
+	// FetchesRunning - fetches which are running now
+	FetchesRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "running",
+		Help:      "Fetches running now.",
+	})
+
+	// FetchesQueued - fetches which wait for free slot because max fetches are running
+	FetchesQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "queued",
+		Help:      "Fetches waiting until one of running fetches ends.",
+	})
+
+	// FetchesRejected - fetches rejected because queue of fetches is full
+	FetchesRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "fetch",
+		Name:      "rejected_total",
+		Help:      "Fetches rejected with RESOURCE_EXHAUSTED because queue of fetches is full.",
+	})
+)
+
+// metrics of limits of calls
+var (
+	// RateLimited - calls rejected because their callers exceeded rate limit of method
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimit",
+		Name:      "rejected_total",
+		Help:      "Calls rejected with RESOURCE_EXHAUSTED by rate limits by method.",
+	}, []string{"method"})
+)
+
+// metrics of storage
+var (
+	// RepositoryDuration - latency of operations of repositories
+	RepositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of repository operations by driver, repository and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"driver", "repository", "operation"})
+
+	// RepositoryErrors - failed operations of repositories, not found products aren't errors
+	RepositoryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "errors_total",
+		Help:      "Failed repository operations by driver, repository and operation.",
+	}, []string{"driver", "repository", "operation"})
+
+	// MongoDBCommands - latency of commands sent to MongoDB by result: succeeded or failed
+	MongoDBCommands = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mongodb",
+		Name:      "command_duration_seconds",
+		Help:      "Latency of MongoDB commands by command and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		FetchRows,
+		FetchDiscontinued,
+		FetchStageQueue,
+		FetchDownloadBytes,
+		FetchDownloadDuration,
+		FetchDuration,
+		FetchesRunning,
+		FetchesQueued,
+		FetchesRejected,
+		RateLimited,
+		RepositoryDuration,
+		RepositoryErrors,
+		MongoDBCommands,
+	)
+}
+
+// Handler - return handler of /metrics with all registered metrics,
+// gRPC metrics are registered by server
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
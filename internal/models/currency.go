@@ -0,0 +1,41 @@
+package models
+
+import "math/big"
+
+// ExchangeRates - value of one unit of currency (ISO 4217 code) in base currency,
+// rate of base currency is 1
+type ExchangeRates map[string]Decimal
+
+// Convert - take amount in currency from and return it in currency to,
+// conversion is exact and the result is rounded to 9 fractional digits
+// return false if there is no rate for one of currencies
+func (r ExchangeRates) Convert(amount Decimal, from, to string) (Decimal, bool) {
+	if from == to {
+		return amount, true
+	}
+	rateFrom, ok := r[from]
+	if !ok {
+		return Decimal{}, false
+	}
+	rateTo, ok := r[to]
+	if !ok || rateTo.Sign() == 0 {
+		return Decimal{}, false
+	}
+
+	result := new(big.Rat).Mul(amount.Rat(), rateFrom.Rat())
+	result.Quo(result, rateTo.Rat())
+	return DecimalFromRat(result)
+}
+
+// ValidCurrency - check that code looks like ISO 4217 code: three upper case letters
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
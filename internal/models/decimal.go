@@ -0,0 +1,247 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NanosPerUnit - number of nanos in one unit of Decimal
+const NanosPerUnit = 1000000000
+
+// DecimalDigits - number of fractional digits of Decimal, values calculated by databases are rounded to it
+const DecimalDigits = 9
+
+// Decimal - exact decimal number with up to 9 fractional digits: Units + Nanos * 10^-9
+// Units and Nanos always have the same sign, |Nanos| < NanosPerUnit
+// it is saved in MongoDB as Decimal128 and in JSON as string
+type Decimal struct {
+	Units int64
+	Nanos int32
+}
+
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// ParseDecimal - take decimal string like "19.90" or "-1.5e3"
+// return error if it isn't a number or it has more than 9 fractional digits
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	d, exact, ok := decimalFromRat(r)
+	if !ok {
+		return Decimal{}, fmt.Errorf("decimal %q is out of range", s)
+	}
+	if !exact {
+		return Decimal{}, fmt.Errorf("decimal %q has more than 9 fractional digits", s)
+	}
+	return d, nil
+}
+
+// NewDecimal - take units and nanos, return error if they have different signs or nanos are out of range
+func NewDecimal(units int64, nanos int32) (Decimal, error) {
+	if nanos <= -NanosPerUnit || nanos >= NanosPerUnit {
+		return Decimal{}, fmt.Errorf("nanos %d are out of range", nanos)
+	}
+	if (units > 0 && nanos < 0) || (units < 0 && nanos > 0) {
+		return Decimal{}, fmt.Errorf("units %d and nanos %d have different signs", units, nanos)
+	}
+	return Decimal{Units: units, Nanos: nanos}, nil
+}
+
+// DecimalFromRat - take rational number and round it to 9 fractional digits (half away from zero)
+// return false if it is out of range
+func DecimalFromRat(r *big.Rat) (Decimal, bool) {
+	d, _, ok := decimalFromRat(r)
+	return d, ok
+}
+
+// DecimalFromFloat - take float and return decimal with its shortest representation,
+// e.g. 1.08 is converted to exactly 1.08
+func DecimalFromFloat(f float64) (Decimal, bool) {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	if !ok {
+		return Decimal{}, false
+	}
+	return DecimalFromRat(r)
+}
+
+// ParseRoundedDecimal - take decimal string and round it to 9 fractional digits,
+// it is used for values calculated by databases, e.g. converted prices
+func ParseRoundedDecimal(s string) (Decimal, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	d, ok := DecimalFromRat(r)
+	if !ok {
+		return Decimal{}, fmt.Errorf("decimal %q is out of range", s)
+	}
+	return d, nil
+}
+
+func decimalFromRat(r *big.Rat) (d Decimal, exact bool, ok bool) {
+	n := new(big.Rat).Mul(r, new(big.Rat).SetInt64(NanosPerUnit))
+
+	nanos, rem := new(big.Int).QuoRem(n.Num(), n.Denom(), new(big.Int))
+	exact = rem.Sign() == 0
+	if !exact {
+		rem.Abs(rem).Lsh(rem, 1)
+		if rem.Cmp(n.Denom()) >= 0 {
+			nanos.Add(nanos, big.NewInt(int64(n.Sign())))
+		}
+	}
+
+	units, rest := new(big.Int).QuoRem(nanos, big.NewInt(NanosPerUnit), new(big.Int))
+	if !units.IsInt64() {
+		return Decimal{}, false, false
+	}
+	return Decimal{Units: units.Int64(), Nanos: int32(rest.Int64())}, exact, true
+}
+
+// Rat - return exact value of decimal as rational number
+func (d Decimal) Rat() *big.Rat {
+	nanos := new(big.Int).Mul(big.NewInt(d.Units), big.NewInt(NanosPerUnit))
+	nanos.Add(nanos, big.NewInt(int64(d.Nanos)))
+	return new(big.Rat).SetFrac(nanos, big.NewInt(NanosPerUnit))
+}
+
+// Float64 - return the nearest float of decimal
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// Sign - return -1, 0 or 1
+func (d Decimal) Sign() int {
+	switch {
+	case d.Units < 0 || d.Nanos < 0:
+		return -1
+	case d.Units > 0 || d.Nanos > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cmp - return -1, 0 or 1 if d is less, equal or greater than o
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d.Units < o.Units:
+		return -1
+	case d.Units > o.Units:
+		return 1
+	case d.Nanos < o.Nanos:
+		return -1
+	case d.Nanos > o.Nanos:
+		return 1
+	}
+	return 0
+}
+
+// Equal - check that decimals are exactly equal
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// String - return decimal without trailing zeros, e.g. "19.9"
+func (d Decimal) String() string {
+	units, nanos := d.Units, int64(d.Nanos)
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+		nanos = -nanos
+	}
+
+	// -Units can overflow for the minimal int64, so unsigned absolute value is used
+	abs := uint64(units)
+	if units < 0 {
+		abs = uint64(-(units + 1)) + 1
+	}
+
+	if nanos == 0 {
+		return fmt.Sprintf("%s%d", sign, abs)
+	}
+	return fmt.Sprintf("%s%d.%s", sign, abs, strings.TrimRight(fmt.Sprintf("%09d", nanos), "0"))
+}
+
+// Decimal128 - return decimal as MongoDB Decimal128
+func (d Decimal) Decimal128() (primitive.Decimal128, error) {
+	d128, err := primitive.ParseDecimal128(d.String())
+	if err != nil {
+		return primitive.Decimal128{}, fmt.Errorf("decimal %s: %v", d, err)
+	}
+	return d128, nil
+}
+
+// MarshalBSONValue - save decimal as Decimal128
+func (d Decimal) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	d128, err := d.Decimal128()
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(d128)
+}
+
+// UnmarshalBSONValue - read decimal from Decimal128, double or integer
+// double is accepted for documents which were saved before prices became decimal
+// values with more than 9 fractional digits (e.g. converted prices) are rounded
+func (d *Decimal) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+
+	var s string
+	switch t {
+	case bsontype.Decimal128:
+		s = raw.Decimal128().String()
+	case bsontype.Double:
+		s = strconv.FormatFloat(raw.Double(), 'g', -1, 64)
+	case bsontype.Int32:
+		s = strconv.FormatInt(int64(raw.Int32()), 10)
+	case bsontype.Int64:
+		s = strconv.FormatInt(raw.Int64(), 10)
+	case bsontype.Null:
+		*d = Decimal{}
+		return nil
+	default:
+		return fmt.Errorf("cannot decode %v into Decimal", t)
+	}
+
+	result, err := ParseRoundedDecimal(s)
+	if err != nil {
+		return fmt.Errorf("cannot decode into Decimal: %v", err)
+	}
+	*d = result
+	return nil
+}
+
+// MarshalJSON - save decimal as string to keep it exact
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON - read decimal from string or number
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	result, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = result
+	return nil
+}
@@ -3,5 +3,11 @@ package models
 import "errors"
 
 var (
-	NotFoundProductError = errors.New("product not found")
+	NotFoundProductError  = errors.New("product not found")
+	ProductExistsError    = errors.New("product already exists")
+	UnknownRateError      = errors.New("no exchange rate for currency")
+	ReadOnlyRatesError    = errors.New("exchange rates are loaded from file and can't be changed")
+	InvalidOrderError     = errors.New("invalid ordering of list")
+	InvalidPageTokenError = errors.New("invalid page token")
+	NotFoundJobError      = errors.New("fetch job not found")
 )
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"time"
+)
+
+// FetchOptions - parameters of one fetch of external CSV file
+// Source is id of the feed, all prices from the file are saved for this source
+// Currency is currency of prices in the file, third column of the file overrides it
+// if FullSnapshot is true the file contains all products of the feed
+// and products which are not in the file are marked as discontinued for this source
+type FetchOptions struct {
+	URL          string
+	Source       string
+	Currency     string
+	FullSnapshot bool
+}
+
+// SourceFromURL - take URL of the feed and return id of its source: host and path of URL,
+// so query parameters (tokens, dates) don't create new sources
+func SourceFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host + u.Path
+}
+
+// FetchResult - counters of changes made by one fetch
+type FetchResult struct {
+	Created      int64 `bson:"created" json:"created"`
+	Updated      int64 `bson:"updated" json:"updated"`
+	Restored     int64 `bson:"restored" json:"restored"`
+	Discontinued int64 `bson:"discontinued" json:"discontinued"`
+}
+
+// statuses of fetch job
+const (
+	FetchJobRunning   = "running"
+	FetchJobSucceeded = "succeeded"
+	FetchJobFailed    = "failed"
+	// fetch was cancelled by shutdown of the server
+	FetchJobInterrupted = "interrupted"
+)
+
+// FetchJob - state of one fetch, it is saved when fetch starts and when it ends
+type FetchJob struct {
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	URL          string             `bson:"url" json:"url"`
+	Source       string             `bson:"source" json:"source"`
+	FullSnapshot bool               `bson:"full_snapshot" json:"full_snapshot"`
+	Principal    string             `bson:"principal" json:"principal"`
+	Status       string             `bson:"status" json:"status"`
+	// error of failed or interrupted fetch
+	Error string `bson:"error" json:"error,omitempty"`
+	// changes made before fetch ended, they are kept even if fetch failed
+	Result   FetchResult `bson:"result" json:"result"`
+	Started  time.Time   `bson:"started" json:"started"`
+	Finished *time.Time  `bson:"finished" json:"finished,omitempty"`
+}
+
+// NewFetchJob - take options of fetch with resolved source and who started it
+// return - pointer for new running job
+func NewFetchJob(opts *FetchOptions, principal string) *FetchJob {
+	return &FetchJob{
+		Id:           primitive.NewObjectID(),
+		URL:          opts.URL,
+		Source:       opts.Source,
+		FullSnapshot: opts.FullSnapshot,
+		Principal:    principal,
+		Status:       FetchJobRunning,
+		Started:      time.Now(),
+	}
+}
+
+// Finish - take status, counters and error of ended fetch and save them in the job
+func (j *FetchJob) Finish(status string, result *FetchResult, err error) {
+	now := time.Now()
+	j.Status = status
+	j.Finished = &now
+	if result != nil {
+		j.Result = *result
+	}
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
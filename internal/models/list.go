@@ -0,0 +1,224 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strconv"
+	"time"
+)
+
+// ListOptions - paging, ordering and filtering for list of products
+// ---
+// OrderBy map represent all fields for ordering where key is name of field
+// and value determines ascending(1)/descending(-1) sort
+// ---
+// PageSize is maximum products per one page
+// ---
+// PageNumber is current page and represented how many pages need to skip
+// ---
+// PageToken is position after the last product of previous page (keyset pagination),
+// if it is set PageNumber is ignored
+// ---
+// discontinued products are skipped if IncludeDiscontinued is false
+// ---
+// PriceMode defines which price of product is returned and used for ordering,
+// for PriceModeSource only products with price from Source are returned
+// ---
+// if Currency is not empty prices are converted into it with Rates
+// and products in currencies without rate are skipped
+type ListOptions struct {
+	OrderBy             map[string]int32
+	PageSize            int32
+	PageNumber          int32
+	PageToken           *PageToken
+	IncludeDiscontinued bool
+	PriceMode           PriceMode
+	Source              string
+	Currency            string
+	Rates               ExchangeRates
+}
+
+// PriceMode - which of the prices from sources is price of product
+type PriceMode int32
+
+const (
+	// PriceModeLatest - the latest changed price of any source
+	PriceModeLatest PriceMode = iota
+	// PriceModeLowest - the lowest price of active sources
+	PriceModeLowest
+	// PriceModeSource - price of the given source
+	PriceModeSource
+)
+
+// OrderFields - fields of product which can be used for ordering of list
+var OrderFields = []string{"name", "price", "currency", "price_source", "updated", "price_updates", "revision"}
+
+// Order - return field and direction of ordering, empty field means ordering by id
+// return InvalidOrderError if there are several fields, unknown field or direction isn't 1 or -1
+// products with equal values of field are always ordered by id in the same direction
+func (o *ListOptions) Order() (field string, desc bool, err error) {
+	if len(o.OrderBy) > 1 {
+		return "", false, fmt.Errorf("%w: only one field can be used", InvalidOrderError)
+	}
+	for key, value := range o.OrderBy {
+		if !isOrderField(key) {
+			return "", false, fmt.Errorf("%w: unknown field %q", InvalidOrderError, key)
+		}
+		switch value {
+		case 1:
+			return key, false, nil
+		case -1:
+			return key, true, nil
+		}
+		return "", false, fmt.Errorf("%w: direction of %q must be 1 or -1", InvalidOrderError, key)
+	}
+	return "", false, nil
+}
+
+func isOrderField(field string) bool {
+	for _, f := range OrderFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderValue - take product and field of ordering
+// return value of the field: string, Decimal, time.Time or int64
+func OrderValue(p *Product, field string) interface{} {
+	switch field {
+	case "name":
+		return p.Name
+	case "price":
+		return p.Price
+	case "currency":
+		return p.Currency
+	case "price_source":
+		return p.PriceSource
+	case "updated":
+		return p.Updated
+	case "price_updates":
+		return int64(p.PriceUpdates)
+	case "revision":
+		return int64(p.Revision)
+	}
+	return nil
+}
+
+// CompareOrderValues - take two values of the same field and return -1, 0 or 1
+func CompareOrderValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case Decimal:
+		return a.Cmp(b.(Decimal))
+	case time.Time:
+		b := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+	case int64:
+		b := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+// PageToken - position after the last product of page: value of field of ordering and id of the product
+// next page contains products which are after this position in the order of list
+type PageToken struct {
+	Field string
+	Desc  bool
+	Value interface{}
+	Id    primitive.ObjectID
+}
+
+// pageToken - JSON form of PageToken, value is saved as string
+type pageToken struct {
+	Field string `json:"f,omitempty"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v,omitempty"`
+	Id    string `json:"id"`
+}
+
+// NewPageToken - take the last product of page and ordering of list
+// return - pointer for PageToken after this product
+func NewPageToken(p *Product, field string, desc bool) *PageToken {
+	return &PageToken{
+		Field: field,
+		Desc:  desc,
+		Value: OrderValue(p, field),
+		Id:    p.Id,
+	}
+}
+
+// Encode - return token as opaque string for clients
+func (t *PageToken) Encode() string {
+	token := pageToken{Field: t.Field, Desc: t.Desc, Id: t.Id.Hex()}
+	switch v := t.Value.(type) {
+	case string:
+		token.Value = v
+	case Decimal:
+		token.Value = v.String()
+	case time.Time:
+		token.Value = v.UTC().Format(time.RFC3339Nano)
+	case int64:
+		token.Value = strconv.FormatInt(v, 10)
+	}
+
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParsePageToken - take string from Encode
+// return PageToken or InvalidPageTokenError
+func ParsePageToken(s string) (*PageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, InvalidPageTokenError
+	}
+	var token pageToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, InvalidPageTokenError
+	}
+
+	result := &PageToken{Field: token.Field, Desc: token.Desc}
+	if result.Id, err = primitive.ObjectIDFromHex(token.Id); err != nil {
+		return nil, InvalidPageTokenError
+	}
+
+	switch token.Field {
+	case "":
+	case "name", "currency", "price_source":
+		result.Value = token.Value
+	case "price":
+		result.Value, err = ParseDecimal(token.Value)
+	case "updated":
+		result.Value, err = time.Parse(time.RFC3339Nano, token.Value)
+	case "price_updates", "revision":
+		result.Value, err = strconv.ParseInt(token.Value, 10, 64)
+	default:
+		return nil, InvalidPageTokenError
+	}
+	if err != nil {
+		return nil, InvalidPageTokenError
+	}
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// types of events which saved in outbox
+const (
+	EventProductCreated      = "product.created"
+	EventProductPriceUpdated = "product.price_updated"
+	EventProductUpdated      = "product.updated"
+	EventProductDiscontinued = "product.discontinued"
+	EventProductRestored     = "product.restored"
+)
+
+// OutboxEvent - product change which written in the same transaction as the change itself
+// and later published by outbox relay
+// Seq is revision of the product after change, it is used for ordering events per product
+type OutboxEvent struct {
+	Id          primitive.ObjectID `bson:"_id" json:"id"`
+	ProductId   primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Seq         uint64             `bson:"seq" json:"seq"`
+	Type        string             `bson:"type" json:"type"`
+	Payload     *Product           `bson:"payload" json:"payload"`
+	Created     time.Time          `bson:"created" json:"created"`
+	PublishedAt *time.Time         `bson:"published_at" json:"-"`
+}
+
+// NewOutboxEvent - take event type and product snapshot after change
+// return - pointer for new unpublished event
+func NewOutboxEvent(eventType string, product *Product) *OutboxEvent {
+	return &OutboxEvent{
+		Id:        primitive.NewObjectID(),
+		ProductId: product.Id,
+		Seq:       product.Revision,
+		Type:      eventType,
+		Payload:   product,
+		Created:   time.Now(),
+	}
+}
@@ -1,43 +1,197 @@
 package models
 
 import (
-	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"google.golang.org/protobuf/types/known/timestamppb"
 	"time"
 )
 
+// Product - price, updated and price_updates are taken from the latest change of price
+// of any source (PriceSource), prices of each source are in Sources
+// price_updates counts only changes of prices inside each source
 type Product struct {
-	Id           primitive.ObjectID `bson:"_id"`
-	Name         string             `bson:"name"`
-	Price        float64            `bson:"price"`
-	Updated      time.Time          `bson:"updated"`
-	PriceUpdates uint32             `bson:"price_updates"`
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Price        Decimal            `bson:"price" json:"price"`
+	Currency     string             `bson:"currency" json:"currency"`
+	PriceSource  string             `bson:"price_source" json:"price_source"`
+	Updated      time.Time          `bson:"updated" json:"updated"`
+	PriceUpdates uint32             `bson:"price_updates" json:"price_updates"`
+	Sources      []*SourcePrice     `bson:"sources" json:"sources"`
+	// Revision - increased by 1 with each change of the product
+	Revision uint64         `bson:"revision" json:"revision"`
+	History  []*PriceChange `bson:"history" json:"history,omitempty"`
+	// Discontinued - time when product was deleted or disappeared from full snapshots of all its sources,
+	// nil for active products
+	Discontinued *time.Time `bson:"discontinued" json:"discontinued,omitempty"`
 }
 
-func ProductToGrpc(p *Product) *pb.Product {
-	return &pb.Product{
-		Id:           p.Id.Hex(),
-		Name:         p.Name,
-		Price:        p.Price,
-		Updated:      timeToTimestamp(p.Updated),
-		PriceUpdates: p.PriceUpdates,
+// SourcePrice - take name of source and return its price
+// or nil if product doesn't have price from this source
+func (p *Product) SourcePrice(source string) *SourcePrice {
+	for _, sp := range p.Sources {
+		if sp.Source == source {
+			return sp
+		}
 	}
+	return nil
 }
 
-func ProductsToGrpc(p []*Product) []*pb.Product {
-	var result []*pb.Product
+// SourcePrice - price of the product from one source
+// Discontinued - time when product disappeared from full snapshot of this source
+type SourcePrice struct {
+	Source       string     `bson:"source" json:"source"`
+	Price        Decimal    `bson:"price" json:"price"`
+	Currency     string     `bson:"currency" json:"currency"`
+	Updated      time.Time  `bson:"updated" json:"updated"`
+	PriceUpdates uint32     `bson:"price_updates" json:"price_updates"`
+	Discontinued *time.Time `bson:"discontinued" json:"discontinued,omitempty"`
+	// id of the last fetch job which had the product in full snapshot of the source
+	Seen primitive.ObjectID `bson:"seen,omitempty" json:"-"`
+}
+
+// NewSourcePrice - take the first change of price from the source
+// return - pointer for SourcePrice
+func NewSourcePrice(change *PriceChange) *SourcePrice {
+	return &SourcePrice{
+		Source:   change.Source,
+		Price:    change.Price,
+		Currency: change.Currency,
+		Updated:  change.Changed,
+	}
+}
+
+// kinds of price changes
+const (
+	PriceChangeFetch  = "fetch"
+	PriceChangeManual = "manual"
+)
+
+// ManualSource - source of prices which were set by CreateProduct and UpdateProduct
+const ManualSource = "manual"
+
+// HistoryLimit - number of the latest changes of price kept for product,
+// older changes are dropped, so products with frequently changed prices don't grow without bound
+const HistoryLimit = 1000
+
+// PriceChange - one change of product's price in the source
+// Principal is who changed the price
+type PriceChange struct {
+	Price     Decimal   `bson:"price" json:"price"`
+	Currency  string    `bson:"currency" json:"currency"`
+	Source    string    `bson:"source" json:"source"`
+	Changed   time.Time `bson:"changed" json:"changed"`
+	Kind      string    `bson:"kind" json:"kind"`
+	Principal string    `bson:"principal" json:"principal"`
+}
+
+// NewPriceChange - take new price with its currency, source, kind of change and principal
+// return - pointer for PriceChange with current time
+func NewPriceChange(price Decimal, currency, source, kind, principal string) *PriceChange {
+	return &PriceChange{
+		Price:     price,
+		Currency:  currency,
+		Source:    source,
+		Changed:   time.Now(),
+		Kind:      kind,
+		Principal: principal,
+	}
+}
+
+// ProductUpdate - manual changes of product, nil fields are not changed
+type ProductUpdate struct {
+	Name  *string
+	Price *PriceChange
+}
+
+// ApplyPriceChange - take change of price from the source and apply it like UpdatePrice of repository:
+// price of known source is replaced and price_updates of product and source are increased by 1,
+// the first price from the source adds the source,
+// in both cases price of product becomes the latest price and change is added to the history,
+// the oldest change is dropped if the history is longer than HistoryLimit
+func (p *Product) ApplyPriceChange(change *PriceChange) {
+	if sp := p.SourcePrice(change.Source); sp != nil {
+		sp.Price = change.Price
+		sp.Currency = change.Currency
+		sp.Updated = change.Changed
+		sp.PriceUpdates++
+		p.PriceUpdates++
+	} else {
+		p.Sources = append(p.Sources, NewSourcePrice(change))
+	}
+
+	p.Price = change.Price
+	p.Currency = change.Currency
+	p.PriceSource = change.Source
+	p.Updated = change.Changed
+	p.History = append(p.History, change)
+	if len(p.History) > HistoryLimit {
+		p.History = append([]*PriceChange(nil), p.History[len(p.History)-HistoryLimit:]...)
+	}
+}
+
+// Restore - take source and make product and its price from the source active again,
+// if source is empty only product is restored
+// return false if product doesn't have price from the source
+func (p *Product) Restore(source string) bool {
+	if source != "" {
+		sp := p.SourcePrice(source)
+		if sp == nil {
+			return false
+		}
+		sp.Discontinued = nil
+	}
+	p.Discontinued = nil
+	return true
+}
+
+// DiscontinueSource - take source and time, mark price from the source as discontinued
+// product without active sources becomes discontinued too
+// return true if product became discontinued
+func (p *Product) DiscontinueSource(source string, at time.Time) bool {
+	if sp := p.SourcePrice(source); sp != nil {
+		sp.Discontinued = &at
+	}
+	if p.Discontinued != nil || p.HasActiveSource() {
+		return false
+	}
+	p.Discontinued = &at
+	return true
+}
+
+// HasActiveSource - check that product has price from at least one not discontinued source
+func (p *Product) HasActiveSource() bool {
+	for _, sp := range p.Sources {
+		if sp.Discontinued == nil {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, product := range p {
-		result = append(result, ProductToGrpc(product))
+// Clone - return deep copy of product
+func (p *Product) Clone() *Product {
+	c := *p
+	c.Discontinued = cloneTime(p.Discontinued)
+
+	c.Sources = nil
+	for _, sp := range p.Sources {
+		spc := *sp
+		spc.Discontinued = cloneTime(sp.Discontinued)
+		c.Sources = append(c.Sources, &spc)
 	}
 
-	return result
+	c.History = nil
+	for _, ch := range p.History {
+		chc := *ch
+		c.History = append(c.History, &chc)
+	}
+	return &c
 }
 
-func timeToTimestamp(t time.Time) *timestamppb.Timestamp {
-	return &timestamppb.Timestamp{
-		Seconds: int64(t.Second()),
-		Nanos:   int32(t.Nanosecond()),
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
 	}
+	c := *t
+	return &c
 }
@@ -0,0 +1,197 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+// relay is unhealthy if it didn't finish poll for this number of poll intervals
+// and a minute more, because publishing of one batch can be slow
+const stalePolls = 3
+
+// published events older than retention are deleted once in this interval
+const cleanupInterval = 10 * time.Minute
+
+// Relay - read not published events from outbox and publish them to the sink
+// ---
+// event is marked as published only after sink accepted it,
+// so every event is delivered at least once
+// ---
+// events of one product are published in order of their Seq,
+// if some event failed all next events of this product wait for the next poll
+// ---
+// published events are deleted after retention time, so outbox doesn't grow forever
+type Relay struct {
+	repos     repository.OutboxReposInterface
+	sink      Sink
+	interval  time.Duration
+	batchSize int64
+	retention time.Duration
+	log       *logrus.Entry
+
+	lastCleanup time.Time
+
+	// state of the last poll for health checks
+	mu       sync.Mutex
+	running  bool
+	lastPoll time.Time
+	lastErr  error
+}
+
+// NewRelay - return pointer of Relay or error if poll interval or batch size isn't positive
+// or retention is negative
+func NewRelay(repos repository.OutboxReposInterface, sink Sink, cfg *configs.Config, log *logrus.Entry) (*Relay, error) {
+	if cfg.Outbox.PollInterval <= 0 {
+		return nil, fmt.Errorf("outbox: poll interval must be positive, got %d", cfg.Outbox.PollInterval)
+	}
+	if cfg.Outbox.BatchSize <= 0 {
+		return nil, fmt.Errorf("outbox: batch size must be positive, got %d", cfg.Outbox.BatchSize)
+	}
+	if cfg.Outbox.Retention < 0 {
+		return nil, fmt.Errorf("outbox: retention must not be negative, got %d", cfg.Outbox.Retention)
+	}
+	return &Relay{
+		repos:     repos,
+		sink:      sink,
+		interval:  time.Duration(cfg.Outbox.PollInterval) * time.Second,
+		batchSize: cfg.Outbox.BatchSize,
+		retention: time.Duration(cfg.Outbox.Retention) * time.Hour,
+		log:       log,
+	}, nil
+}
+
+// Run - poll outbox until ctx is canceled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.setRunning(true)
+	defer r.setRunning(false)
+
+	for {
+		err := r.relay(ctx)
+		if err != nil {
+			r.log.WithError(err).Error("outbox: relay")
+		}
+		r.mu.Lock()
+		r.lastPoll, r.lastErr = time.Now(), err
+		r.mu.Unlock()
+
+		r.cleanup(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check - return error if relay isn't running, its last poll failed
+// or it didn't finish poll for several poll intervals
+func (r *Relay) Check(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running {
+		return errors.New("outbox: relay is not running")
+	}
+	if r.lastErr != nil {
+		return fmt.Errorf("outbox: last poll failed: %v", r.lastErr)
+	}
+	if !r.lastPoll.IsZero() && time.Since(r.lastPoll) > stalePolls*r.interval+time.Minute {
+		return fmt.Errorf("outbox: last poll finished at %s", r.lastPoll.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (r *Relay) setRunning(running bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = running
+}
+
+// relay - publish one batch of pending events
+func (r *Relay) relay(ctx context.Context) error {
+	events, err := r.repos.Pending(ctx, r.batchSize)
+	if err != nil {
+		return err
+	}
+
+	// products with failed event, their next events must wait
+	blocked := make(map[primitive.ObjectID]bool)
+
+	for _, e := range orderByProduct(events) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if blocked[e.ProductId] {
+			continue
+		}
+
+		if err := r.sink.Publish(ctx, e); err != nil {
+			r.log.WithError(err).WithField("event_id", e.Id.Hex()).Error("outbox: publish event")
+			blocked[e.ProductId] = true
+			continue
+		}
+
+		// if event wasn't marked it will be published again,
+		// so next events of this product wait to keep the order
+		if err := r.repos.MarkPublished(ctx, e.Id); err != nil {
+			r.log.WithError(err).WithField("event_id", e.Id.Hex()).Error("outbox: mark event")
+			blocked[e.ProductId] = true
+		}
+	}
+	return nil
+}
+
+// cleanup - delete events published before retention time,
+// it is done once in cleanup interval, failed deletion is repeated after the next poll
+func (r *Relay) cleanup(ctx context.Context) {
+	if r.retention == 0 || time.Since(r.lastCleanup) < cleanupInterval || ctx.Err() != nil {
+		return
+	}
+
+	deleted, err := r.repos.DeletePublished(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		r.log.WithError(err).Error("outbox: delete published events")
+		return
+	}
+	r.lastCleanup = time.Now()
+	if deleted > 0 {
+		r.log.WithField("deleted", deleted).Info("outbox: published events deleted")
+	}
+}
+
+// orderByProduct - take events sorted by creation
+// return events where products keep order of their first event
+// and events of each product sorted by Seq
+func orderByProduct(events []*models.OutboxEvent) []*models.OutboxEvent {
+	position := make(map[primitive.ObjectID]int)
+	for _, e := range events {
+		if _, ok := position[e.ProductId]; !ok {
+			position[e.ProductId] = len(position)
+		}
+	}
+
+	result := make([]*models.OutboxEvent, len(events))
+	copy(result, events)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		pi, pj := position[result[i].ProductId], position[result[j].ProductId]
+		if pi != pj {
+			return pi < pj
+		}
+		return result[i].Seq < result[j].Seq
+	})
+	return result
+}
@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestRelayCleanup(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention time.Duration
+		// time since the previous cleanup, zero - there was no cleanup
+		sinceCleanup time.Duration
+		wantKept     int64
+	}{
+		{name: "keep forever", retention: 0, wantKept: 1},
+		{name: "published within retention", retention: time.Hour, wantKept: 1},
+		{name: "published before retention", retention: time.Nanosecond, wantKept: 0},
+		{name: "cleanup interval not passed", retention: time.Nanosecond, sinceCleanup: time.Minute, wantKept: 1},
+		{name: "cleanup interval passed", retention: time.Nanosecond, sinceCleanup: cleanupInterval, wantKept: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			relay, repos := newTestRelay(t)
+			relay.retention = tt.retention
+			if tt.sinceCleanup != 0 {
+				relay.lastCleanup = time.Now().Add(-tt.sinceCleanup)
+			}
+
+			change := models.NewPriceChange(models.Decimal{Units: 1}, "USD", models.ManualSource, models.PriceChangeManual, "test")
+			require.NoError(t, repos.Products.Create(ctx, &models.Product{
+				Id:          primitive.NewObjectID(),
+				Name:        "apple",
+				Price:       change.Price,
+				Currency:    change.Currency,
+				PriceSource: change.Source,
+				Updated:     change.Changed,
+				Sources:     []*models.SourcePrice{models.NewSourcePrice(change)},
+				History:     []*models.PriceChange{change},
+			}))
+
+			require.NoError(t, relay.relay(ctx))
+			pending, err := repos.Outbox.Pending(ctx, 10)
+			require.NoError(t, err)
+			require.Empty(t, pending)
+
+			relay.cleanup(ctx)
+
+			// events left after cleanup
+			kept, err := repos.Outbox.DeletePublished(ctx, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKept, kept)
+		})
+	}
+}
+
+func TestNewRelayRejectsNegativeRetention(t *testing.T) {
+	cfg, err := configs.NewConfig()
+	require.NoError(t, err)
+	cfg.Outbox.Retention = -1
+
+	_, err = NewRelay(nil, nil, cfg, logrus.NewEntry(logrus.New()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retention must not be negative")
+}
+
+// newTestRelay - return relay publishing to discarded log and memory storage of its events
+func newTestRelay(t *testing.T) (*Relay, *repository.Repository) {
+	t.Helper()
+
+	cfg, err := configs.NewConfig()
+	require.NoError(t, err)
+	cfg.Storage.Driver = "memory"
+
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	entry := logrus.NewEntry(log)
+
+	repos, err := repository.Open(cfg, entry)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repos.Close() })
+
+	relay, err := NewRelay(repos.Outbox, NewLogSink(entry), cfg, entry)
+	require.NoError(t, err)
+	return relay, repos
+}
@@ -0,0 +1,177 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink - destination where outbox events are published
+// Publish must return error if the event wasn't accepted, then it will be published again
+type Sink interface {
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+	Close() error
+}
+
+// NewSink - return sink defined in config: log, file or webhook
+// in-process channel sink can be created only with NewChanSink
+func NewSink(cfg *configs.Config, log *logrus.Entry) (Sink, error) {
+	switch cfg.Outbox.Sink {
+	case "log":
+		return NewLogSink(log), nil
+	case "file":
+		return NewFileSink(cfg.Outbox.File)
+	case "webhook":
+		if cfg.Outbox.WebhookURL == "" {
+			return nil, fmt.Errorf("outbox: webhook sink: url is empty")
+		}
+		timeout := time.Duration(cfg.Outbox.Timeout) * time.Second
+		return NewWebhookSink(cfg.Outbox.WebhookURL, timeout), nil
+	default:
+		return nil, fmt.Errorf("outbox: unknown sink %q", cfg.Outbox.Sink)
+	}
+}
+
+// logSink - write events to logger
+type logSink struct {
+	logger *logrus.Entry
+}
+
+// NewLogSink - return sink which writes events to logger
+func NewLogSink(logger *logrus.Entry) Sink {
+	return &logSink{logger: logger}
+}
+
+func (s *logSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.logger.WithContext(ctx).Infof("outbox event: %s", data)
+	return nil
+}
+
+func (s *logSink) Close() error {
+	return nil
+}
+
+// fileSink - append events to file, one JSON per line
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink - take path to file, open or create it
+// return sink which appends events to this file
+func NewFileSink(path string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: file sink: %v", err)
+	}
+	return &fileSink{file: f}, nil
+}
+
+func (s *fileSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	// event is published only when it is on disk
+	return s.file.Sync()
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+// webhookSink - send events with POST request to external URL
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink - take URL and timeout for one request
+// return sink which posts events as JSON
+// receiver can use Idempotency-Key header for drop duplicates
+func NewWebhookSink(url string, timeout time.Duration) Sink {
+	return &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *webhookSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.Id.Hex())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("outbox: webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// ChanSink - send events to channel for consumers in the same process
+type ChanSink struct {
+	events chan *models.OutboxEvent
+}
+
+// NewChanSink - take size of channel buffer and return pointer of ChanSink
+func NewChanSink(size int) *ChanSink {
+	return &ChanSink{events: make(chan *models.OutboxEvent, size)}
+}
+
+// Events - return channel with published events
+func (s *ChanSink) Events() <-chan *models.OutboxEvent {
+	return s.events
+}
+
+// Publish - wait until consumer takes the event or ctx is canceled
+func (s *ChanSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	select {
+	case s.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close - close channel, Publish must not be called after it
+func (s *ChanSink) Close() error {
+	close(s.events)
+	return nil
+}
@@ -0,0 +1,12 @@
+package ratelimit
+
+import (
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
+	"google.golang.org/grpc/codes"
+	"time"
+)
+
+// ExhaustedError - return ResourceExhausted error with RetryInfo, so client knows when the call can be retried
+func ExhaustedError(message string, retryAfter time.Duration) error {
+	return apierror.Retry(codes.ResourceExhausted, message, retryAfter)
+}
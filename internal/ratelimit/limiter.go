@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// minimal time between sweeps of unused buckets
+const sweepInterval = time.Minute
+
+// Limiter - token buckets with the same rate and burst by keys, like callers of method
+type Limiter struct {
+	limit rate.Limit
+	burst int
+	// time after which unused bucket is full again and can be forgotten
+	idle time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	used    time.Time
+}
+
+// NewLimiter - take number of calls per minute and burst, burst is at least one call
+// return limiter or nil if calls per minute isn't positive, nil limiter allows all calls
+func NewLimiter(perMinute, burst int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	idle := time.Duration(burst) * time.Minute / time.Duration(perMinute)
+	if idle < sweepInterval {
+		idle = sweepInterval
+	}
+	return &Limiter{
+		limit:   rate.Limit(float64(perMinute) / 60),
+		burst:   burst,
+		idle:    idle,
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+}
+
+// Allow - take token from bucket of key
+// return zero if call is allowed or time after which the next token is in bucket
+func (l *Limiter) Allow(key string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.used = now
+
+	// rejected call doesn't take token, so it doesn't delay the next calls
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// sweep - forget buckets which are full again, so limiter doesn't grow with number of callers
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if now.Sub(b.used) >= l.idle {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// QueueFullError - operation is rejected because all slots are busy and queue is full
+var QueueFullError = errors.New("queue is full")
+
+// Queue - limit of operations which run at once, other operations wait for free slot in queue of limited size
+type Queue struct {
+	slots chan struct{}
+	size  int
+
+	mu      sync.Mutex
+	waiting int
+}
+
+// NewQueue - take number of operations which run at once and number of operations which can wait
+// return queue or nil if running isn't positive, nil queue doesn't limit operations
+func NewQueue(running, size int) *Queue {
+	if running <= 0 {
+		return nil
+	}
+	if size < 0 {
+		size = 0
+	}
+	return &Queue{
+		slots: make(chan struct{}, running),
+		size:  size,
+	}
+}
+
+// Acquire - take free slot or wait for it until ctx is done
+// return function which frees slot, QueueFullError if there is no place in queue or error of ctx
+func (q *Queue) Acquire(ctx context.Context) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case q.slots <- struct{}{}:
+		return q.release, nil
+	default:
+	}
+
+	q.mu.Lock()
+	if q.waiting >= q.size {
+		q.mu.Unlock()
+		return nil, QueueFullError
+	}
+	q.waiting++
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.waiting--
+		q.mu.Unlock()
+	}()
+
+	select {
+	case q.slots <- struct{}{}:
+		return q.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *Queue) release() {
+	<-q.slots
+}
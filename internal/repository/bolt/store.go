@@ -0,0 +1,300 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/mapping"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/ArturChopikian/grpc-server/internal/repository/docstore"
+	"github.com/sirupsen/logrus"
+	bbolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// buckets of the database file
+var (
+	productsBucket = []byte("products")
+	// name of product -> id
+	namesBucket  = []byte("names")
+	outboxBucket = []byte("outbox")
+	// ids of not published events
+	pendingBucket = []byte("pending")
+	// ISO 4217 code -> rate as decimal string
+	ratesBucket = []byte("rates")
+	jobsBucket  = []byte("jobs")
+)
+
+func init() {
+	repository.Register("bolt", open)
+}
+
+// open - open database file from config and return repositories on it
+func open(cfg *configs.Config, log *logrus.Entry) (*repository.Repository, error) {
+	store, err := NewStore(cfg.Storage.BoltPath)
+	if err != nil {
+		return nil, err
+	}
+	return docstore.NewRepository(store, log), nil
+}
+
+// Store - embedded storage in one bbolt file, documents are saved as BSON like in MongoDB
+type Store struct {
+	db *bbolt.DB
+}
+
+// NewStore - take path of database file, create it with all buckets if it doesn't exist
+func NewStore(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("bolt: open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{productsBucket, namesBucket, outboxBucket, pendingBucket, ratesBucket, jobsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("bolt: create buckets: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// View - run fn inside read-only transaction
+func (s *Store) View(ctx context.Context, fn func(tx docstore.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+// Update - run fn inside read-write transaction, it is rolled back if fn returns error
+func (s *Store) Update(ctx context.Context, fn func(tx docstore.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+// Close - close database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// boltTx - documents inside bbolt transaction
+type boltTx struct {
+	tx *bbolt.Tx
+}
+
+// Product - return product by id or nil if it doesn't exist
+func (t *boltTx) Product(id primitive.ObjectID) (*models.Product, error) {
+	doc := t.tx.Bucket(productsBucket).Get(id[:])
+	if doc == nil {
+		return nil, nil
+	}
+	return mapping.ProductFromBSON(doc)
+}
+
+// ProductByName - return product by name or nil if it doesn't exist
+func (t *boltTx) ProductByName(name string) (*models.Product, error) {
+	id := t.tx.Bucket(namesBucket).Get([]byte(name))
+	if id == nil {
+		return nil, nil
+	}
+	doc := t.tx.Bucket(productsBucket).Get(id)
+	if doc == nil {
+		return nil, fmt.Errorf("bolt: product %x of name %q not found", id, name)
+	}
+	return mapping.ProductFromBSON(doc)
+}
+
+// Products - call fn for each product in order of ids until it returns error
+func (t *boltTx) Products(fn func(p *models.Product) error) error {
+	return t.tx.Bucket(productsBucket).ForEach(func(k, doc []byte) error {
+		product, err := mapping.ProductFromBSON(doc)
+		if err != nil {
+			return err
+		}
+		return fn(product)
+	})
+}
+
+// PutProduct - insert or replace product and update index of names
+// return ProductExistsError if other product has the same name
+func (t *boltTx) PutProduct(p *models.Product) error {
+	names := t.tx.Bucket(namesBucket)
+	if id := names.Get([]byte(p.Name)); id != nil && objectId(id) != p.Id {
+		return models.ProductExistsError
+	}
+
+	old, err := t.Product(p.Id)
+	if err != nil {
+		return err
+	}
+	if old != nil && old.Name != p.Name {
+		if err := names.Delete([]byte(old.Name)); err != nil {
+			return err
+		}
+	}
+
+	doc, err := mapping.ProductToBSON(p)
+	if err != nil {
+		return err
+	}
+	if err := t.tx.Bucket(productsBucket).Put(p.Id[:], doc); err != nil {
+		return err
+	}
+	return names.Put([]byte(p.Name), p.Id[:])
+}
+
+// Event - return outbox event by id or nil if it doesn't exist
+func (t *boltTx) Event(id primitive.ObjectID) (*models.OutboxEvent, error) {
+	doc := t.tx.Bucket(outboxBucket).Get(id[:])
+	if doc == nil {
+		return nil, nil
+	}
+	event := &models.OutboxEvent{}
+	if err := bson.Unmarshal(doc, event); err != nil {
+		return nil, fmt.Errorf("bolt: decoding event %x: %w", id, err)
+	}
+	return event, nil
+}
+
+// PendingEvents - return not published events in order of ids
+func (t *boltTx) PendingEvents(limit int64) ([]*models.OutboxEvent, error) {
+	var result []*models.OutboxEvent
+
+	cur := t.tx.Bucket(pendingBucket).Cursor()
+	for k, _ := cur.First(); k != nil && int64(len(result)) < limit; k, _ = cur.Next() {
+		event, err := t.Event(objectId(k))
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+// PutEvent - insert or replace outbox event and update index of not published events
+func (t *boltTx) PutEvent(e *models.OutboxEvent) error {
+	doc, err := bson.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("bolt: encoding event %s: %w", e.Id.Hex(), err)
+	}
+	if err := t.tx.Bucket(outboxBucket).Put(e.Id[:], doc); err != nil {
+		return err
+	}
+
+	pending := t.tx.Bucket(pendingBucket)
+	if e.PublishedAt != nil {
+		return pending.Delete(e.Id[:])
+	}
+	return pending.Put(e.Id[:], []byte{})
+}
+
+// DeletePublishedEvents - remove events published before the time, return number of removed events
+func (t *boltTx) DeletePublishedEvents(before time.Time) (int64, error) {
+	outbox := t.tx.Bucket(outboxBucket)
+
+	// keys are collected first, because bucket must not be changed while it is iterated
+	var keys [][]byte
+	err := outbox.ForEach(func(k, v []byte) error {
+		event := &models.OutboxEvent{}
+		if err := bson.Unmarshal(v, event); err != nil {
+			return fmt.Errorf("bolt: decoding event %x: %w", k, err)
+		}
+		if event.PublishedAt != nil && event.PublishedAt.Before(before) {
+			keys = append(keys, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, k := range keys {
+		if err := outbox.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(keys)), nil
+}
+
+// Rates - return all exchange rates
+func (t *boltTx) Rates() (models.ExchangeRates, error) {
+	result := models.ExchangeRates{}
+
+	err := t.tx.Bucket(ratesBucket).ForEach(func(k, v []byte) error {
+		rate, err := models.ParseDecimal(string(v))
+		if err != nil {
+			return fmt.Errorf("bolt: rate of %s: %w", k, err)
+		}
+		result[string(k)] = rate
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PutRates - replace all exchange rates
+func (t *boltTx) PutRates(rates models.ExchangeRates) error {
+	if err := t.tx.DeleteBucket(ratesBucket); err != nil {
+		return err
+	}
+	bucket, err := t.tx.CreateBucket(ratesBucket)
+	if err != nil {
+		return err
+	}
+	for currency, rate := range rates {
+		if err := bucket.Put([]byte(currency), []byte(rate.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Job - return fetch job by id or nil if it doesn't exist
+func (t *boltTx) Job(id primitive.ObjectID) (*models.FetchJob, error) {
+	doc := t.tx.Bucket(jobsBucket).Get(id[:])
+	if doc == nil {
+		return nil, nil
+	}
+	job := &models.FetchJob{}
+	if err := bson.Unmarshal(doc, job); err != nil {
+		return nil, fmt.Errorf("bolt: decoding job %x: %w", id, err)
+	}
+	return job, nil
+}
+
+// PutJob - insert or replace fetch job
+func (t *boltTx) PutJob(job *models.FetchJob) error {
+	doc, err := bson.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("bolt: encoding job %s: %w", job.Id.Hex(), err)
+	}
+	return t.tx.Bucket(jobsBucket).Put(job.Id[:], doc)
+}
+
+// objectId - convert key of bucket into id
+func objectId(key []byte) primitive.ObjectID {
+	var id primitive.ObjectID
+	copy(id[:], key)
+	return id
+}
@@ -0,0 +1,28 @@
+package bolt
+
+import (
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/ArturChopikian/grpc-server/internal/repository/conformance"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Test(t, func(t *testing.T) *repository.Repository {
+		cfg, err := configs.NewConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Storage.Driver = "bolt"
+		cfg.Storage.BoltPath = filepath.Join(t.TempDir(), "products.db")
+
+		repos, err := repository.Open(cfg, logrus.NewEntry(logrus.New()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = repos.Close() })
+		return repos
+	})
+}
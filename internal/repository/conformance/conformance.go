@@ -0,0 +1,604 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Run - take repositories on empty storage and check that they behave like repositories on MongoDB:
+// not-found semantics, changes of prices and sources, soft delete, ordering, paging, price modes,
+// outbox events, exchange rates and fetch jobs
+// return error of each failed check, nil if all checks passed
+func Run(ctx context.Context, repos *repository.Repository) []error {
+	s := &suite{ctx: ctx, repos: repos}
+	if err := s.empty(); err != nil {
+		return []error{err}
+	}
+
+	for _, c := range s.checks() {
+		if err := c.fn(); err != nil {
+			s.failures = append(s.failures, fmt.Errorf("conformance: %s: %v", c.name, err))
+		}
+	}
+	return s.failures
+}
+
+// suite - state of one run, products created by checks are used by the next checks
+type suite struct {
+	ctx      context.Context
+	repos    *repository.Repository
+	failures []error
+
+	apple, pear *models.Product
+}
+
+// namedCheck - one check of the suite
+type namedCheck struct {
+	name string
+	fn   func() error
+}
+
+// checks - return checks in order of running, they depend on products created by previous checks
+func (s *suite) checks() []namedCheck {
+	return []namedCheck{
+		{"not found", s.notFound},
+		{"create and get", s.createAndGet},
+		{"update price", s.updatePrice},
+		{"update", s.update},
+		{"get many", s.getMany},
+		{"discontinue and restore", s.discontinueAndRestore},
+		{"discontinue missing", s.discontinueMissing},
+		{"list order and paging", s.listOrder},
+		{"list price modes", s.listPriceModes},
+		{"lowest price of rounded rates", s.lowestRounded},
+		{"outbox", s.outbox},
+		{"rates", s.rates},
+		{"fetch jobs", s.jobs},
+	}
+}
+
+// empty - check that storage has no products, checks expect exact results
+func (s *suite) empty() error {
+	existing, err := s.repos.Products.List(s.ctx, &models.ListOptions{IncludeDiscontinued: true, PageSize: 1})
+	if err != nil {
+		return fmt.Errorf("conformance: List: %v", err)
+	}
+	if len(existing) > 0 {
+		return errors.New("conformance: storage must be empty")
+	}
+	return nil
+}
+
+func (s *suite) notFound() error {
+	if _, err := s.repos.Products.Get(s.ctx, "unknown"); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("Get: expected NotFoundProductError, got %v", err)
+	}
+	if _, err := s.repos.Products.GetById(s.ctx, primitive.NewObjectID()); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("GetById: expected NotFoundProductError, got %v", err)
+	}
+	if err := s.repos.Products.UpdatePrice(s.ctx, primitive.NewObjectID(), change("1", "USD", "feed")); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("UpdatePrice: expected NotFoundProductError, got %v", err)
+	}
+	if err := s.repos.Products.Discontinue(s.ctx, primitive.NewObjectID(), time.Now()); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("Discontinue: expected NotFoundProductError, got %v", err)
+	}
+	if _, err := s.repos.Products.Restore(s.ctx, primitive.NewObjectID(), ""); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("Restore: expected NotFoundProductError, got %v", err)
+	}
+	return nil
+}
+
+func (s *suite) createAndGet() error {
+	var err error
+	if s.apple, err = s.create("apple", change("10.50", "USD", models.ManualSource)); err != nil {
+		return err
+	}
+	if s.pear, err = s.create("pear", change("3", "USD", "feed")); err != nil {
+		return err
+	}
+
+	got, err := s.repos.Products.GetById(s.ctx, s.apple.Id)
+	if err != nil {
+		return err
+	}
+	if err := expectProduct(got, "apple", "10.5", "USD", models.ManualSource, 0, 1); err != nil {
+		return err
+	}
+	if len(got.Sources) != 1 || len(got.History) != 1 {
+		return fmt.Errorf("expected 1 source and 1 change, got %d and %d", len(got.Sources), len(got.History))
+	}
+
+	byName, err := s.repos.Products.Get(s.ctx, "apple")
+	if err != nil {
+		return err
+	}
+	if byName.Id != s.apple.Id {
+		return fmt.Errorf("Get returned product %s instead of %s", byName.Id.Hex(), s.apple.Id.Hex())
+	}
+	return nil
+}
+
+func (s *suite) updatePrice() error {
+	// known source
+	if err := s.repos.Products.UpdatePrice(s.ctx, s.apple.Id, change("11", "USD", models.ManualSource)); err != nil {
+		return err
+	}
+	got, err := s.repos.Products.GetById(s.ctx, s.apple.Id)
+	if err != nil {
+		return err
+	}
+	if err := expectProduct(got, "apple", "11", "USD", models.ManualSource, 1, 2); err != nil {
+		return err
+	}
+	if sp := got.SourcePrice(models.ManualSource); sp == nil || sp.PriceUpdates != 1 || !sp.Price.Equal(decimal("11")) {
+		return fmt.Errorf("unexpected price of source: %+v", sp)
+	}
+
+	// the first price from new source
+	if err := s.repos.Products.UpdatePrice(s.ctx, s.apple.Id, change("9.99", "EUR", "feed")); err != nil {
+		return err
+	}
+	if got, err = s.repos.Products.GetById(s.ctx, s.apple.Id); err != nil {
+		return err
+	}
+	if err := expectProduct(got, "apple", "9.99", "EUR", "feed", 1, 3); err != nil {
+		return err
+	}
+	if len(got.Sources) != 2 || len(got.History) != 3 {
+		return fmt.Errorf("expected 2 sources and 3 changes, got %d and %d", len(got.Sources), len(got.History))
+	}
+	if sp := got.SourcePrice("feed"); sp == nil || sp.PriceUpdates != 0 || sp.Currency != "EUR" {
+		return fmt.Errorf("unexpected price of new source: %+v", sp)
+	}
+	return nil
+}
+
+func (s *suite) update() error {
+	name := "green apple"
+	got, err := s.repos.Products.Update(s.ctx, s.apple.Id, &models.ProductUpdate{Name: &name})
+	if err != nil {
+		return err
+	}
+	if got.Name != name || got.Revision != 4 {
+		return fmt.Errorf("expected name %q and revision 4, got %q and %d", name, got.Name, got.Revision)
+	}
+	if _, err := s.repos.Products.Get(s.ctx, "apple"); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("old name: expected NotFoundProductError, got %v", err)
+	}
+
+	got, err = s.repos.Products.Update(s.ctx, s.apple.Id, &models.ProductUpdate{Price: change("12", "USD", models.ManualSource)})
+	if err != nil {
+		return err
+	}
+	if err := expectProduct(got, name, "12", "USD", models.ManualSource, 2, 5); err != nil {
+		return err
+	}
+
+	if _, err := s.repos.Products.Update(s.ctx, primitive.NewObjectID(), &models.ProductUpdate{Name: &name}); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("unknown id: expected NotFoundProductError, got %v", err)
+	}
+	return nil
+}
+
+func (s *suite) getMany() error {
+	got, err := s.repos.Products.GetMany(s.ctx,
+		[]primitive.ObjectID{s.apple.Id, primitive.NewObjectID()},
+		[]string{"green apple", "pear", "unknown"})
+	if err != nil {
+		return err
+	}
+	if len(got) != 2 {
+		return fmt.Errorf("expected 2 products, got %d", len(got))
+	}
+	for _, p := range got {
+		if len(p.History) != 0 {
+			return fmt.Errorf("product %q is returned with history", p.Name)
+		}
+	}
+
+	if got, err = s.repos.Products.GetMany(s.ctx, nil, nil); err != nil || len(got) != 0 {
+		return fmt.Errorf("empty request: expected no products, got %d, %v", len(got), err)
+	}
+	return nil
+}
+
+func (s *suite) discontinueAndRestore() error {
+	if err := s.repos.Products.Discontinue(s.ctx, s.pear.Id, time.Now()); err != nil {
+		return err
+	}
+
+	active, err := s.list(&models.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(active) != 1 || active[0] != "green apple" {
+		return fmt.Errorf("expected only active products, got %v", active)
+	}
+	all, err := s.list(&models.ListOptions{IncludeDiscontinued: true})
+	if err != nil {
+		return err
+	}
+	if len(all) != 2 {
+		return fmt.Errorf("expected discontinued products too, got %v", all)
+	}
+
+	if _, err := s.repos.Products.Restore(s.ctx, s.pear.Id, "unknown"); !errors.Is(err, models.NotFoundProductError) {
+		return fmt.Errorf("unknown source: expected NotFoundProductError, got %v", err)
+	}
+	restored, err := s.repos.Products.Restore(s.ctx, s.pear.Id, "")
+	if err != nil {
+		return err
+	}
+	if restored.Discontinued != nil {
+		return errors.New("restored product is still discontinued")
+	}
+	return nil
+}
+
+func (s *suite) discontinueMissing() error {
+	// pear has only price from feed, apple has manual price too, fetch of full snapshot saw apple only
+	job := primitive.NewObjectID()
+	if err := s.repos.Products.MarkSeen(s.ctx, "feed", []primitive.ObjectID{s.apple.Id}, job); err != nil {
+		return err
+	}
+	count, err := s.repos.Products.DiscontinueMissing(s.ctx, "feed", job, time.Now())
+	if err != nil {
+		return err
+	}
+	if count != 1 {
+		return fmt.Errorf("expected 1 discontinued product, got %d", count)
+	}
+	pear, err := s.repos.Products.GetById(s.ctx, s.pear.Id)
+	if err != nil {
+		return err
+	}
+	if pear.Discontinued == nil || pear.SourcePrice("feed").Discontinued == nil {
+		return errors.New("pear and its price from feed must be discontinued")
+	}
+	apple, err := s.repos.Products.GetById(s.ctx, s.apple.Id)
+	if err != nil {
+		return err
+	}
+	if apple.Discontinued != nil || apple.SourcePrice("feed").Discontinued != nil {
+		return errors.New("apple seen by fetch must stay active")
+	}
+
+	// the next fetch saw nothing
+	job = primitive.NewObjectID()
+	if count, err = s.repos.Products.DiscontinueMissing(s.ctx, "feed", job, time.Now()); err != nil || count != 0 {
+		return fmt.Errorf("next fetch: expected 0 products, got %d, %v", count, err)
+	}
+	if apple, err = s.repos.Products.GetById(s.ctx, s.apple.Id); err != nil {
+		return err
+	}
+	if apple.Discontinued != nil || apple.SourcePrice("feed").Discontinued == nil {
+		return errors.New("only price of apple from feed must be discontinued")
+	}
+
+	// nothing is left to discontinue
+	if count, err = s.repos.Products.DiscontinueMissing(s.ctx, "feed", job, time.Now()); err != nil || count != 0 {
+		return fmt.Errorf("repeated call: expected 0 products, got %d, %v", count, err)
+	}
+
+	if _, err := s.repos.Products.Restore(s.ctx, s.pear.Id, "feed"); err != nil {
+		return err
+	}
+	if _, err := s.repos.Products.Restore(s.ctx, s.apple.Id, "feed"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *suite) listOrder() error {
+	for _, name := range []string{"cherry", "banana", "date"} {
+		if _, err := s.create(name, change("3", "USD", models.ManualSource)); err != nil {
+			return err
+		}
+	}
+	// prices: green apple 12, pear 3, cherry 3, banana 3, date 3
+
+	byName, err := s.list(&models.ListOptions{OrderBy: map[string]int32{"name": 1}})
+	if err != nil {
+		return err
+	}
+	if err := expectNames(byName, "banana", "cherry", "date", "green apple", "pear"); err != nil {
+		return err
+	}
+	byNameDesc, err := s.list(&models.ListOptions{OrderBy: map[string]int32{"name": -1}})
+	if err != nil {
+		return err
+	}
+	if err := expectNames(byNameDesc, "pear", "green apple", "date", "cherry", "banana"); err != nil {
+		return err
+	}
+
+	page, err := s.list(&models.ListOptions{OrderBy: map[string]int32{"name": 1}, PageSize: 2, PageNumber: 1})
+	if err != nil {
+		return err
+	}
+	if err := expectNames(page, "date", "green apple"); err != nil {
+		return fmt.Errorf("page number: %v", err)
+	}
+
+	// keyset pagination by price with equal prices
+	opts := &models.ListOptions{OrderBy: map[string]int32{"price": -1}, PageSize: 2}
+	var walked []string
+	for i := 0; i < 5; i++ {
+		products, err := s.repos.Products.List(s.ctx, opts)
+		if err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			break
+		}
+		for _, p := range products {
+			walked = append(walked, p.Name)
+		}
+		opts.PageToken = models.NewPageToken(products[len(products)-1], "price", true)
+	}
+	if len(walked) != 5 || walked[0] != "green apple" || !unique(walked) {
+		return fmt.Errorf("page tokens: expected all 5 products once starting with green apple, got %v", walked)
+	}
+
+	if _, err := s.repos.Products.List(s.ctx, &models.ListOptions{OrderBy: map[string]int32{"unknown": 1}}); !errors.Is(err, models.InvalidOrderError) {
+		return fmt.Errorf("unknown field: expected InvalidOrderError, got %v", err)
+	}
+	return nil
+}
+
+func (s *suite) listPriceModes() error {
+	// apple: manual 12 USD, feed 9.99 EUR; rates: 1 EUR = 1.1 USD
+	rates := models.ExchangeRates{"USD": decimal("1"), "EUR": decimal("1.1")}
+
+	source, err := s.repos.Products.List(s.ctx, &models.ListOptions{PriceMode: models.PriceModeSource, Source: "feed"})
+	if err != nil {
+		return err
+	}
+	if len(source) != 2 {
+		return fmt.Errorf("source mode: expected 2 products with price from feed, got %d", len(source))
+	}
+
+	lowest, err := s.repos.Products.List(s.ctx, &models.ListOptions{
+		PriceMode: models.PriceModeLowest, Rates: rates, OrderBy: map[string]int32{"name": 1},
+	})
+	if err != nil {
+		return err
+	}
+	if apple := find(lowest, "green apple"); apple == nil || apple.PriceSource != "feed" || !apple.Price.Equal(decimal("9.99")) {
+		return fmt.Errorf("lowest mode: expected price 9.99 EUR from feed, got %+v", apple)
+	}
+
+	converted, err := s.repos.Products.List(s.ctx, &models.ListOptions{Currency: "EUR", Rates: rates})
+	if err != nil {
+		return err
+	}
+	pear := find(converted, "pear")
+	if pear == nil || pear.Currency != "EUR" || !pear.Price.Equal(decimal("2.727272727")) {
+		return fmt.Errorf("currency: expected 3 USD converted into 2.727272727 EUR, got %+v", pear)
+	}
+
+	skipped, err := s.repos.Products.List(s.ctx, &models.ListOptions{Currency: "GBP", Rates: rates})
+	if err != nil {
+		return err
+	}
+	if len(skipped) != 0 {
+		return fmt.Errorf("currency without rate: expected no products, got %d", len(skipped))
+	}
+	return nil
+}
+
+func (s *suite) lowestRounded() error {
+	// plum: manual 1 USD, feed 0.333333333 EUR; rates: 1 EUR = 3 USD
+	// 1 USD is 1/3 EUR, it is rounded to 0.333333333 EUR, so prices are equal and the first source is chosen
+	rates := models.ExchangeRates{"USD": decimal("1"), "EUR": decimal("3")}
+
+	plum, err := s.create("plum", change("1", "USD", models.ManualSource))
+	if err != nil {
+		return err
+	}
+	if err := s.repos.Products.UpdatePrice(s.ctx, plum.Id, change("0.333333333", "EUR", "feed")); err != nil {
+		return err
+	}
+
+	lowest, err := s.repos.Products.List(s.ctx, &models.ListOptions{PriceMode: models.PriceModeLowest, Currency: "EUR", Rates: rates})
+	if err != nil {
+		return err
+	}
+	got := find(lowest, "plum")
+	if got == nil || got.PriceSource != models.ManualSource || got.Currency != "EUR" || !got.Price.Equal(decimal("0.333333333")) {
+		return fmt.Errorf("expected 1 USD of manual source converted into 0.333333333 EUR, got %+v", got)
+	}
+	return nil
+}
+
+func (s *suite) outbox() error {
+	events, err := s.repos.Outbox.Pending(s.ctx, 1000)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return errors.New("no events in outbox")
+	}
+	if events[0].Type != models.EventProductCreated || events[0].ProductId != s.apple.Id || events[0].Seq != 1 {
+		return fmt.Errorf("the first event must be creation of apple, got %s of %s", events[0].Type, events[0].ProductId.Hex())
+	}
+
+	seq := make(map[primitive.ObjectID]uint64)
+	for _, e := range events {
+		if e.Seq <= seq[e.ProductId] {
+			return fmt.Errorf("events of product %s are not in order of seq", e.ProductId.Hex())
+		}
+		seq[e.ProductId] = e.Seq
+		if e.Payload == nil || e.Payload.Id != e.ProductId {
+			return fmt.Errorf("event %s has invalid payload", e.Id.Hex())
+		}
+	}
+
+	if err := s.repos.Outbox.MarkPublished(s.ctx, events[0].Id); err != nil {
+		return err
+	}
+	rest, err := s.repos.Outbox.Pending(s.ctx, 1000)
+	if err != nil {
+		return err
+	}
+	if len(rest) != len(events)-1 || rest[0].Id != events[1].Id {
+		return errors.New("published event is still pending")
+	}
+
+	// only published events are deleted and only if they were published before the time
+	if deleted, err := s.repos.Outbox.DeletePublished(s.ctx, time.Now().Add(-time.Hour)); err != nil || deleted != 0 {
+		return fmt.Errorf("DeletePublished before publishing: expected 0 events, got %d, %v", deleted, err)
+	}
+	if deleted, err := s.repos.Outbox.DeletePublished(s.ctx, time.Now().Add(time.Minute)); err != nil || deleted != 1 {
+		return fmt.Errorf("DeletePublished: expected 1 published event, got %d, %v", deleted, err)
+	}
+	if rest, err = s.repos.Outbox.Pending(s.ctx, 1000); err != nil {
+		return err
+	}
+	if len(rest) != len(events)-1 {
+		return fmt.Errorf("DeletePublished: expected %d pending events, got %d", len(events)-1, len(rest))
+	}
+	return nil
+}
+
+func (s *suite) rates() error {
+	if err := s.repos.Rates.Set(s.ctx, models.ExchangeRates{"USD": decimal("1"), "EUR": decimal("1.08")}, true); err != nil {
+		return err
+	}
+	if err := s.repos.Rates.Set(s.ctx, models.ExchangeRates{"GBP": decimal("1.25")}, false); err != nil {
+		return err
+	}
+	rates, err := s.repos.Rates.List(s.ctx)
+	if err != nil {
+		return err
+	}
+	if len(rates) != 3 || !rates["EUR"].Equal(decimal("1.08")) {
+		return fmt.Errorf("expected 3 rates with exact EUR 1.08, got %v", rates)
+	}
+
+	if err := s.repos.Rates.Set(s.ctx, models.ExchangeRates{"USD": decimal("1")}, true); err != nil {
+		return err
+	}
+	if rates, err = s.repos.Rates.List(s.ctx); err != nil {
+		return err
+	}
+	if len(rates) != 1 {
+		return fmt.Errorf("replace: expected only USD, got %v", rates)
+	}
+	return nil
+}
+
+func (s *suite) jobs() error {
+	if _, err := s.repos.Jobs.Get(s.ctx, primitive.NewObjectID()); !errors.Is(err, models.NotFoundJobError) {
+		return fmt.Errorf("Get: expected NotFoundJobError, got %v", err)
+	}
+
+	job := models.NewFetchJob(&models.FetchOptions{URL: "http://feed/products.csv", Source: "feed"}, "conformance")
+	if err := s.repos.Jobs.Save(s.ctx, job); err != nil {
+		return err
+	}
+	job.Finish(models.FetchJobInterrupted, &models.FetchResult{Created: 2, Updated: 1}, errors.New("shutdown"))
+	if err := s.repos.Jobs.Save(s.ctx, job); err != nil {
+		return err
+	}
+
+	got, err := s.repos.Jobs.Get(s.ctx, job.Id)
+	if err != nil {
+		return err
+	}
+	if got.Status != models.FetchJobInterrupted || got.Result != job.Result || got.Error != "shutdown" ||
+		got.Source != "feed" || got.Finished == nil {
+		return fmt.Errorf("expected %+v, got %+v", job, got)
+	}
+	return nil
+}
+
+// create - take name and the first change of price, create product like usecase does
+func (s *suite) create(name string, first *models.PriceChange) (*models.Product, error) {
+	product := &models.Product{
+		Id:          primitive.NewObjectID(),
+		Name:        name,
+		Price:       first.Price,
+		Currency:    first.Currency,
+		PriceSource: first.Source,
+		Updated:     first.Changed,
+		Sources:     []*models.SourcePrice{models.NewSourcePrice(first)},
+		History:     []*models.PriceChange{first},
+	}
+	if err := s.repos.Products.Create(s.ctx, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// list - return names of products of the list
+func (s *suite) list(opts *models.ListOptions) ([]string, error) {
+	products, err := s.repos.Products.List(s.ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(products))
+	for _, p := range products {
+		names = append(names, p.Name)
+	}
+	return names, nil
+}
+
+func change(price, currency, source string) *models.PriceChange {
+	return models.NewPriceChange(decimal(price), currency, source, models.PriceChangeManual, "conformance")
+}
+
+func decimal(s string) models.Decimal {
+	d, err := models.ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func expectProduct(p *models.Product, name, price, currency, source string, priceUpdates uint32, revision uint64) error {
+	if p.Name != name || !p.Price.Equal(decimal(price)) || p.Currency != currency || p.PriceSource != source ||
+		p.PriceUpdates != priceUpdates || p.Revision != revision {
+		return fmt.Errorf("expected %s %s %s from %s, %d updates, revision %d, got %s %s %s from %s, %d updates, revision %d",
+			name, price, currency, source, priceUpdates, revision,
+			p.Name, p.Price, p.Currency, p.PriceSource, p.PriceUpdates, p.Revision)
+	}
+	return nil
+}
+
+func expectNames(got []string, expected ...string) error {
+	if len(got) != len(expected) {
+		return fmt.Errorf("expected %v, got %v", expected, got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			return fmt.Errorf("expected %v, got %v", expected, got)
+		}
+	}
+	return nil
+}
+
+func find(products []*models.Product, name string) *models.Product {
+	for _, p := range products {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func unique(names []string) bool {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+	}
+	return true
+}
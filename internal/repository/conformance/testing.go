@@ -0,0 +1,33 @@
+package conformance
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"testing"
+	"time"
+)
+
+// Test - run checks of Run as subtests of t, open must return repositories on empty storage
+// and is called once, later subtests are skipped after failure, because they depend on earlier ones
+func Test(t *testing.T, open func(t *testing.T) *repository.Repository) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	s := &suite{ctx: ctx, repos: open(t)}
+	if err := s.empty(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range s.checks() {
+		c := c
+		if !t.Run(c.name, func(t *testing.T) {
+			if err := c.fn(); err != nil {
+				t.Fatal(err)
+			}
+		}) {
+			t.Fatalf("conformance: %s failed, next checks are skipped", c.name)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package docstore
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// jobsRepos - state of fetch jobs in the store
+type jobsRepos struct {
+	store Store
+	log   *logrus.Entry
+}
+
+// Get - take id and return fetch job or NotFoundJobError
+func (j *jobsRepos) Get(ctx context.Context, id primitive.ObjectID) (*models.FetchJob, error) {
+	var job *models.FetchJob
+
+	err := j.store.View(ctx, func(tx Tx) error {
+		var err error
+		job, err = tx.Job(id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repos: jobs: Get: %w", err)
+	}
+	if job == nil {
+		return nil, models.NotFoundJobError
+	}
+	return job, nil
+}
+
+// Save - take fetch job and insert or replace it
+func (j *jobsRepos) Save(ctx context.Context, job *models.FetchJob) error {
+	err := j.store.Update(ctx, func(tx Tx) error {
+		return tx.PutJob(job)
+	})
+	if err != nil {
+		return fmt.Errorf("repos: jobs: Save: %w", err)
+	}
+	return nil
+}
+
+// newJobsRepos - return new jobsRepos
+func newJobsRepos(store Store, log *logrus.Entry) *jobsRepos {
+	return &jobsRepos{
+		store: store,
+		log:   log,
+	}
+}
@@ -0,0 +1,160 @@
+package docstore
+
+import (
+	"bytes"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"math/big"
+	"sort"
+)
+
+// if page size equal zero, use default value instead of zero
+const defaultPageSize = 100
+
+// selectPage - take all products and options of list
+// filter products, choose their price for the price mode, convert it into currency of the list,
+// order products and return one page of them
+func selectPage(products []*models.Product, listOpts *models.ListOptions) ([]*models.Product, error) {
+	field, desc, err := listOpts.Order()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*models.Product
+	for _, p := range products {
+		if !listOpts.IncludeDiscontinued && p.Discontinued != nil {
+			continue
+		}
+		if listOpts.PriceMode == models.PriceModeSource && p.SourcePrice(listOpts.Source) == nil {
+			continue
+		}
+		if !choosePrice(p, listOpts) {
+			continue
+		}
+		result = append(result, p)
+	}
+
+	// compare - return > 0 if a is after b in the order of list
+	compare := func(a interface{}, aId []byte, b interface{}, bId []byte) int {
+		c := 0
+		if field != "" {
+			c = models.CompareOrderValues(a, b)
+		}
+		if c == 0 {
+			c = bytes.Compare(aId, bId)
+		}
+		if desc {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		return compare(models.OrderValue(a, field), a.Id[:], models.OrderValue(b, field), b.Id[:]) < 0
+	})
+
+	pageSize, pageNumber := int64(listOpts.PageSize), int64(listOpts.PageNumber)
+	limit := pageSize
+	if pageSize == 0 {
+		limit = defaultPageSize
+	}
+
+	var skip int64
+	if token := listOpts.PageToken; token != nil {
+		skip = int64(sort.Search(len(result), func(i int) bool {
+			p := result[i]
+			return compare(models.OrderValue(p, field), p.Id[:], token.Value, token.Id[:]) > 0
+		}))
+	} else if pageNumber != 0 {
+		skip = pageSize * pageNumber
+	}
+
+	if skip >= int64(len(result)) {
+		return nil, nil
+	}
+	result = result[skip:]
+	if int64(len(result)) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// choosePrice - take product and options of list
+// replace price of product with price of the source for the price mode
+// and convert it into currency of the list
+// return false if there is no exchange rate for currency of product
+func choosePrice(p *models.Product, listOpts *models.ListOptions) bool {
+	var src *models.SourcePrice
+
+	switch listOpts.PriceMode {
+	case models.PriceModeLowest:
+		src = lowestPrice(p.Sources, listOpts)
+	case models.PriceModeSource:
+		src = p.SourcePrice(listOpts.Source)
+	}
+
+	if src != nil {
+		p.Price = src.Price
+		p.Currency = src.Currency
+		p.PriceSource = src.Source
+		p.Updated = src.Updated
+		p.PriceUpdates = src.PriceUpdates
+	}
+
+	if listOpts.Currency != "" {
+		converted, ok := listOpts.Rates.Convert(p.Price, p.Currency, listOpts.Currency)
+		if !ok {
+			return false
+		}
+		p.Price = converted
+		p.Currency = listOpts.Currency
+	}
+	return true
+}
+
+// lowestPrice - return active source with the lowest price,
+// prices are compared in currency of the list or in base currency of rates if currency isn't set
+// return the first active source if no price can be compared, nil if there are no active sources
+func lowestPrice(sources []*models.SourcePrice, listOpts *models.ListOptions) *models.SourcePrice {
+	var (
+		first, lowest *models.SourcePrice
+		lowestValue   models.Decimal
+	)
+
+	for _, sp := range sources {
+		if sp.Discontinued != nil {
+			continue
+		}
+		if first == nil {
+			first = sp
+		}
+
+		value, ok := comparedPrice(sp, listOpts)
+		if !ok {
+			continue
+		}
+		if lowest == nil || value.Cmp(lowestValue) < 0 {
+			lowest, lowestValue = sp, value
+		}
+	}
+
+	if lowest == nil {
+		return first
+	}
+	return lowest
+}
+
+// comparedPrice - return price of the source in currency of the list
+// or in base currency of rates, price without rate is compared as is if currency isn't set
+// converted price is rounded to 9 fractional digits like in MongoDB and PostgreSQL,
+// so all drivers choose the same source when converted prices differ only in further digits
+func comparedPrice(sp *models.SourcePrice, listOpts *models.ListOptions) (models.Decimal, bool) {
+	if listOpts.Currency != "" {
+		return listOpts.Rates.Convert(sp.Price, sp.Currency, listOpts.Currency)
+	}
+
+	rate, ok := listOpts.Rates[sp.Currency]
+	if !ok {
+		return sp.Price, true
+	}
+	return models.DecimalFromRat(new(big.Rat).Mul(sp.Price.Rat(), rate.Rat()))
+}
@@ -0,0 +1,72 @@
+package docstore
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// outboxRepos - outbox events in the store
+type outboxRepos struct {
+	store Store
+	log   *logrus.Entry
+}
+
+// Pending - take limit and return the oldest not published events
+func (o *outboxRepos) Pending(ctx context.Context, limit int64) ([]*models.OutboxEvent, error) {
+	var result []*models.OutboxEvent
+
+	err := o.store.View(ctx, func(tx Tx) error {
+		var err error
+		result, err = tx.PendingEvents(limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MarkPublished - take id of the event and save time when it was published
+func (o *outboxRepos) MarkPublished(ctx context.Context, id primitive.ObjectID) error {
+	err := o.store.Update(ctx, func(tx Tx) error {
+		event, err := tx.Event(id)
+		if err != nil || event == nil {
+			return err
+		}
+		now := time.Now()
+		event.PublishedAt = &now
+		return tx.PutEvent(event)
+	})
+	if err != nil {
+		return fmt.Errorf("repos: outbox: MarkPublished: %w", err)
+	}
+	return nil
+}
+
+// DeletePublished - take time and remove events published before it
+// return number of removed events
+func (o *outboxRepos) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+
+	err := o.store.Update(ctx, func(tx Tx) error {
+		var err error
+		deleted, err = tx.DeletePublishedEvents(before)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repos: outbox: DeletePublished: %w", err)
+	}
+	return deleted, nil
+}
+
+// newOutboxRepos - return new outboxRepos
+func newOutboxRepos(store Store, log *logrus.Entry) *outboxRepos {
+	return &outboxRepos{
+		store: store,
+		log:   log,
+	}
+}
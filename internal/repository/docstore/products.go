@@ -0,0 +1,323 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// productsRepos - products in the store
+type productsRepos struct {
+	store Store
+	log   *logrus.Entry
+}
+
+// Get - takes a name and return the product with this name
+// or NotFoundProductError if product not found
+func (p *productsRepos) Get(ctx context.Context, name string) (*models.Product, error) {
+	var product *models.Product
+
+	err := p.store.View(ctx, func(tx Tx) error {
+		var err error
+		product, err = tx.ProductByName(name)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repos: Get: %w", err)
+	}
+	if product == nil {
+		return nil, models.NotFoundProductError
+	}
+	return product, nil
+}
+
+// GetById - takes an id and return the product with this id
+// or NotFoundProductError if product not found
+func (p *productsRepos) GetById(ctx context.Context, id primitive.ObjectID) (*models.Product, error) {
+	var product *models.Product
+
+	err := p.store.View(ctx, func(tx Tx) error {
+		var err error
+		product, err = tx.Product(id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repos: GetById: %w", err)
+	}
+	if product == nil {
+		return nil, models.NotFoundProductError
+	}
+	return product, nil
+}
+
+// GetMany - takes ids and names and return all found products without history,
+// missing products are skipped
+func (p *productsRepos) GetMany(ctx context.Context, ids []primitive.ObjectID, names []string) ([]*models.Product, error) {
+	var result []*models.Product
+
+	err := p.store.View(ctx, func(tx Tx) error {
+		seen := make(map[primitive.ObjectID]bool)
+		add := func(product *models.Product) {
+			if product == nil || seen[product.Id] {
+				return
+			}
+			seen[product.Id] = true
+			product.History = nil
+			result = append(result, product)
+		}
+
+		for _, id := range ids {
+			product, err := tx.Product(id)
+			if err != nil {
+				return err
+			}
+			add(product)
+		}
+		for _, name := range names {
+			product, err := tx.ProductByName(name)
+			if err != nil {
+				return err
+			}
+			add(product)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Create - take the product and insert it together with product.created event
+// return ProductExistsError if product with the same name exists
+func (p *productsRepos) Create(ctx context.Context, product *models.Product) error {
+	product.Revision = 1
+
+	err := p.store.Update(ctx, func(tx Tx) error {
+		if err := tx.PutProduct(product); err != nil {
+			return err
+		}
+		return tx.PutEvent(models.NewOutboxEvent(models.EventProductCreated, product))
+	})
+	if err != nil {
+		if errors.Is(err, models.ProductExistsError) {
+			return err
+		}
+		return fmt.Errorf("repos: Create: %w", err)
+	}
+	return nil
+}
+
+// UpdatePrice - take id and change of price for product from the source,
+// apply it like models.Product.ApplyPriceChange and write product.price_updated event
+func (p *productsRepos) UpdatePrice(ctx context.Context, id primitive.ObjectID, change *models.PriceChange) error {
+	_, err := p.update(ctx, id, models.EventProductPriceUpdated, func(product *models.Product) error {
+		product.ApplyPriceChange(change)
+		product.Revision++
+		return nil
+	})
+	if err != nil {
+		return p.wrap("UpdatePrice", err)
+	}
+	return nil
+}
+
+// Update - take id and manual changes of product
+// change of price is applied like in UpdatePrice
+// return updated product or NotFoundProductError if product not found
+func (p *productsRepos) Update(ctx context.Context, id primitive.ObjectID, upd *models.ProductUpdate) (*models.Product, error) {
+	product, err := p.update(ctx, id, models.EventProductUpdated, func(product *models.Product) error {
+		if upd.Name != nil {
+			product.Name = *upd.Name
+			product.Revision++
+		}
+		if upd.Price != nil {
+			product.ApplyPriceChange(upd.Price)
+			product.Revision++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, p.wrap("Update", err)
+	}
+	return product, nil
+}
+
+// Discontinue - take id and time, mark the product as discontinued (soft delete)
+// and write product.discontinued event
+// return NotFoundProductError if product not found
+func (p *productsRepos) Discontinue(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := p.update(ctx, id, models.EventProductDiscontinued, func(product *models.Product) error {
+		product.Discontinued = &at
+		product.Revision++
+		return nil
+	})
+	if err != nil {
+		return p.wrap("Discontinue", err)
+	}
+	return nil
+}
+
+// Restore - take id and source, make discontinued product and its price from the source active again,
+// if source is empty only product is restored
+// write product.restored event
+// return restored product or NotFoundProductError if product or its source not found
+func (p *productsRepos) Restore(ctx context.Context, id primitive.ObjectID, source string) (*models.Product, error) {
+	product, err := p.update(ctx, id, models.EventProductRestored, func(product *models.Product) error {
+		if !product.Restore(source) {
+			return models.NotFoundProductError
+		}
+		product.Revision++
+		return nil
+	})
+	if err != nil {
+		return nil, p.wrap("Restore", err)
+	}
+	return product, nil
+}
+
+// MarkSeen - take source, ids of products from full snapshot of its feed and id of fetch job
+// stamp prices of the source of the products with the job, it isn't change of products,
+// so revision isn't incremented and events aren't written
+func (p *productsRepos) MarkSeen(ctx context.Context, source string, ids []primitive.ObjectID, job primitive.ObjectID) error {
+	err := p.store.Update(ctx, func(tx Tx) error {
+		for _, id := range ids {
+			product, err := tx.Product(id)
+			if err != nil {
+				return err
+			}
+			if product == nil {
+				continue
+			}
+			if sp := product.SourcePrice(source); sp != nil {
+				sp.Seen = job
+				if err := tx.PutProduct(product); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("repos: MarkSeen: %w", err)
+	}
+	return nil
+}
+
+// DiscontinueMissing - take source, id of fetch job of full snapshot of its feed and time
+// mark active prices from this source which weren't stamped with the job by MarkSeen as discontinued
+// products without active sources become discontinued
+// write product.discontinued or product.updated events in the same transaction
+// return number of discontinued products
+func (p *productsRepos) DiscontinueMissing(ctx context.Context, source string, job primitive.ObjectID, at time.Time) (int64, error) {
+	var count int64
+
+	err := p.store.Update(ctx, func(tx Tx) error {
+		count = 0
+
+		var missing []*models.Product
+		err := tx.Products(func(product *models.Product) error {
+			if sp := product.SourcePrice(source); sp != nil && sp.Discontinued == nil && sp.Seen != job {
+				missing = append(missing, product)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, product := range missing {
+			eventType := models.EventProductUpdated
+			if product.DiscontinueSource(source, at) {
+				eventType = models.EventProductDiscontinued
+				count++
+			}
+			product.Revision++
+
+			if err := tx.PutProduct(product); err != nil {
+				return err
+			}
+			// events of full snapshot are written without history like in MongoDB
+			snapshot := product.Clone()
+			snapshot.History = nil
+			if err := tx.PutEvent(models.NewOutboxEvent(eventType, snapshot)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repos: DiscontinueMissing: %w", err)
+	}
+	return count, nil
+}
+
+// List - take options with ordering, paging, filters and price mode
+// return page of products without history like List of MongoDB repository
+func (p *productsRepos) List(ctx context.Context, listOpts *models.ListOptions) ([]*models.Product, error) {
+	var products []*models.Product
+
+	err := p.store.View(ctx, func(tx Tx) error {
+		return tx.Products(func(product *models.Product) error {
+			product.History = nil
+			products = append(products, product)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return selectPage(products, listOpts)
+}
+
+// update - take id, type of event and fn which changes the product,
+// apply fn and write event with changed product in the same transaction
+// return changed product or NotFoundProductError if product not found
+func (p *productsRepos) update(ctx context.Context, id primitive.ObjectID, eventType string, fn func(product *models.Product) error) (*models.Product, error) {
+	var result *models.Product
+
+	err := p.store.Update(ctx, func(tx Tx) error {
+		product, err := tx.Product(id)
+		if err != nil {
+			return err
+		}
+		if product == nil {
+			return models.NotFoundProductError
+		}
+
+		if err := fn(product); err != nil {
+			return err
+		}
+		if err := tx.PutProduct(product); err != nil {
+			return err
+		}
+
+		result = product
+		return tx.PutEvent(models.NewOutboxEvent(eventType, product))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// wrap - return errors of models as is, so callers can check them, and wrap others
+func (p *productsRepos) wrap(method string, err error) error {
+	if errors.Is(err, models.NotFoundProductError) || errors.Is(err, models.ProductExistsError) {
+		return err
+	}
+	return fmt.Errorf("repos: %s: %w", method, err)
+}
+
+// newProductsRepos - return new productsRepos
+func newProductsRepos(store Store, log *logrus.Entry) *productsRepos {
+	return &productsRepos{
+		store: store,
+		log:   log,
+	}
+}
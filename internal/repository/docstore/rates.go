@@ -0,0 +1,62 @@
+package docstore
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ratesRepos - exchange rates in the store
+type ratesRepos struct {
+	store Store
+	log   *logrus.Entry
+}
+
+// List - return all exchange rates
+func (r *ratesRepos) List(ctx context.Context) (models.ExchangeRates, error) {
+	var result models.ExchangeRates
+
+	err := r.store.View(ctx, func(tx Tx) error {
+		var err error
+		result, err = tx.Rates()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Set - take rates and add or replace them
+// if replace is true, rates which are not in the list are removed
+func (r *ratesRepos) Set(ctx context.Context, rates models.ExchangeRates, replace bool) error {
+	err := r.store.Update(ctx, func(tx Tx) error {
+		result := models.ExchangeRates{}
+		if !replace {
+			old, err := tx.Rates()
+			if err != nil {
+				return err
+			}
+			for currency, rate := range old {
+				result[currency] = rate
+			}
+		}
+		for currency, rate := range rates {
+			result[currency] = rate
+		}
+		return tx.PutRates(result)
+	})
+	if err != nil {
+		return fmt.Errorf("repos: rates: Set: %w", err)
+	}
+	return nil
+}
+
+// newRatesRepos - return new ratesRepos
+func newRatesRepos(store Store, log *logrus.Entry) *ratesRepos {
+	return &ratesRepos{
+		store: store,
+		log:   log,
+	}
+}
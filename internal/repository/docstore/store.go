@@ -0,0 +1,67 @@
+package docstore
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Store - transactional storage of products, outbox events and exchange rates as documents,
+// products are changed and listed in Go with the same semantics as repositories on MongoDB
+// all changes made inside fn of Update are committed together or not at all
+type Store interface {
+	View(ctx context.Context, fn func(tx Tx) error) error
+	Update(ctx context.Context, fn func(tx Tx) error) error
+	Close() error
+}
+
+// Tx - documents inside one transaction
+// returned documents are copies, so they can be changed by caller
+type Tx interface {
+	// Product - return product by id or nil if it doesn't exist
+	Product(id primitive.ObjectID) (*models.Product, error)
+	// ProductByName - return product by name or nil if it doesn't exist
+	ProductByName(name string) (*models.Product, error)
+	// Products - call fn for each product until it returns error
+	Products(fn func(p *models.Product) error) error
+	// PutProduct - insert or replace product
+	// return ProductExistsError if other product has the same name
+	PutProduct(p *models.Product) error
+
+	// Event - return outbox event by id or nil if it doesn't exist
+	Event(id primitive.ObjectID) (*models.OutboxEvent, error)
+	// PendingEvents - return not published events in order of ids
+	PendingEvents(limit int64) ([]*models.OutboxEvent, error)
+	// PutEvent - insert or replace outbox event
+	PutEvent(e *models.OutboxEvent) error
+	// DeletePublishedEvents - remove events published before the time, return number of removed events
+	DeletePublishedEvents(before time.Time) (int64, error)
+
+	// Rates - return all exchange rates
+	Rates() (models.ExchangeRates, error)
+	// PutRates - replace all exchange rates
+	PutRates(rates models.ExchangeRates) error
+
+	// Job - return fetch job by id or nil if it doesn't exist
+	Job(id primitive.ObjectID) (*models.FetchJob, error)
+	// PutJob - insert or replace fetch job
+	PutJob(job *models.FetchJob) error
+}
+
+// NewRepository - return repositories on the store, the store is closed by Repository.Close
+// and checked by Repository.Ping with empty read-only transaction
+func NewRepository(store Store, log *logrus.Entry) *repository.Repository {
+	return &repository.Repository{
+		Products: newProductsRepos(store, log),
+		Outbox:   newOutboxRepos(store, log),
+		Rates:    newRatesRepos(store, log),
+		Jobs:     newJobsRepos(store, log),
+		Closer:   store.Close,
+		Pinger: func(ctx context.Context) error {
+			return store.View(ctx, func(tx Tx) error { return nil })
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+)
+
+// Driver - open storage from config and return repositories which use it and log to log
+type Driver func(cfg *configs.Config, log *logrus.Entry) (*Repository, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register - make storage driver available by name,
+// it is called from init of the driver package
+// panics if driver is nil or it is registered twice
+func Register(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if driver == nil {
+		panic("repository: Register driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("repository: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers - return sorted names of registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open - open storage with the driver from cfg.Storage.Driver
+// operations of its repositories are recorded in metrics and spans
+// rates from local file have priority over rates from storage
+func Open(cfg *configs.Config, log *logrus.Entry) (*Repository, error) {
+	driversMu.RLock()
+	driver, ok := drivers[cfg.Storage.Driver]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("repository: unknown storage driver %q (registered: %v)", cfg.Storage.Driver, Drivers())
+	}
+
+	repos, err := driver(cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("repository: open %s: %w", cfg.Storage.Driver, err)
+	}
+
+	instrument(repos, cfg.Storage.Driver)
+	traceRepos(repos, cfg.Storage.Driver)
+
+	if cfg.Currency.RatesFile != "" {
+		repos.Rates = newFileRatesRepos(cfg.Currency.RatesFile)
+	}
+	return repos, nil
+}
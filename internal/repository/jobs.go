@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// jobsRepos - state of fetch jobs in MongoDB collection
+type jobsRepos struct {
+	conn *mongo.Collection
+	log  *logrus.Entry
+}
+
+// Get - take id and return fetch job or NotFoundJobError
+func (j *jobsRepos) Get(ctx context.Context, id primitive.ObjectID) (*models.FetchJob, error) {
+	job := &models.FetchJob{}
+	if err := j.conn.FindOne(ctx, bson.M{"_id": id}).Decode(job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, models.NotFoundJobError
+		}
+		return nil, fmt.Errorf("repos: jobs: Get: %w", err)
+	}
+	return job, nil
+}
+
+// Save - take fetch job and insert or replace it
+func (j *jobsRepos) Save(ctx context.Context, job *models.FetchJob) error {
+	opts := options.Replace().SetUpsert(true)
+	if _, err := j.conn.ReplaceOne(ctx, bson.M{"_id": job.Id}, job, opts); err != nil {
+		return fmt.Errorf("repos: jobs: Save: %w", err)
+	}
+	return nil
+}
+
+// newJobsRepos - return new jobsRepos
+func newJobsRepos(conn *mongo.Collection, log *logrus.Entry) *jobsRepos {
+	return &jobsRepos{
+		conn: conn,
+		log:  log,
+	}
+}
@@ -0,0 +1,362 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/ArturChopikian/grpc-server/internal/repository/docstore"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+func init() {
+	repository.Register("memory", open)
+}
+
+// open - return repositories on new empty store, data is lost when the server stops
+func open(_ *configs.Config, log *logrus.Entry) (*repository.Repository, error) {
+	return docstore.NewRepository(NewStore(), log), nil
+}
+
+// Store - storage in memory of the process for tests and local development,
+// it is safe for concurrent use: views run in parallel, updates one by one
+type Store struct {
+	mu   sync.RWMutex
+	data *data
+}
+
+// data - documents of the store or changes of one transaction
+type data struct {
+	products map[primitive.ObjectID]*models.Product
+	// name of product -> id, NilObjectID in changes means that name was removed
+	names map[string]primitive.ObjectID
+	// nil event in changes means that it was removed
+	events map[primitive.ObjectID]*models.OutboxEvent
+	// ids of events -> not published
+	pending map[primitive.ObjectID]bool
+	// nil in changes means that rates weren't changed
+	rates models.ExchangeRates
+	jobs  map[primitive.ObjectID]*models.FetchJob
+}
+
+func newData() *data {
+	return &data{
+		products: make(map[primitive.ObjectID]*models.Product),
+		names:    make(map[string]primitive.ObjectID),
+		events:   make(map[primitive.ObjectID]*models.OutboxEvent),
+		pending:  make(map[primitive.ObjectID]bool),
+		jobs:     make(map[primitive.ObjectID]*models.FetchJob),
+	}
+}
+
+// NewStore - return new empty store
+func NewStore() *Store {
+	d := newData()
+	d.rates = models.ExchangeRates{}
+	return &Store{data: d}
+}
+
+// View - run fn inside read-only transaction
+func (s *Store) View(ctx context.Context, fn func(tx docstore.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&memTx{data: s.data})
+}
+
+// Update - run fn inside read-write transaction,
+// changes are kept aside and applied only if fn doesn't return error
+func (s *Store) Update(ctx context.Context, fn func(tx docstore.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memTx{data: s.data, changes: newData()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+// Close - nothing to release
+func (s *Store) Close() error {
+	return nil
+}
+
+// memTx - documents of the store with changes of transaction on top of them,
+// changes is nil for read-only transaction
+type memTx struct {
+	data    *data
+	changes *data
+}
+
+// Product - return copy of product by id or nil if it doesn't exist
+func (t *memTx) Product(id primitive.ObjectID) (*models.Product, error) {
+	if p := t.product(id); p != nil {
+		return p.Clone(), nil
+	}
+	return nil, nil
+}
+
+// ProductByName - return copy of product by name or nil if it doesn't exist
+func (t *memTx) ProductByName(name string) (*models.Product, error) {
+	id, ok := t.data.names[name]
+	if t.changes != nil {
+		if changed, found := t.changes.names[name]; found {
+			id, ok = changed, !changed.IsZero()
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+	return t.Product(id)
+}
+
+// Products - call fn for copy of each product in order of ids until it returns error
+func (t *memTx) Products(fn func(p *models.Product) error) error {
+	ids := make([]primitive.ObjectID, 0, len(t.data.products))
+	for id := range t.data.products {
+		ids = append(ids, id)
+	}
+	if t.changes != nil {
+		for id := range t.changes.products {
+			if _, ok := t.data.products[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sortIds(ids)
+
+	for _, id := range ids {
+		if err := fn(t.product(id).Clone()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutProduct - insert or replace product and update index of names
+// return ProductExistsError if other product has the same name
+func (t *memTx) PutProduct(p *models.Product) error {
+	if other, err := t.ProductByName(p.Name); err != nil {
+		return err
+	} else if other != nil && other.Id != p.Id {
+		return models.ProductExistsError
+	}
+
+	if old := t.product(p.Id); old != nil && old.Name != p.Name {
+		t.changes.names[old.Name] = primitive.NilObjectID
+	}
+	t.changes.products[p.Id] = p.Clone()
+	t.changes.names[p.Name] = p.Id
+	return nil
+}
+
+// Event - return copy of outbox event by id or nil if it doesn't exist
+func (t *memTx) Event(id primitive.ObjectID) (*models.OutboxEvent, error) {
+	e, ok := t.data.events[id]
+	if t.changes != nil {
+		if changed, found := t.changes.events[id]; found {
+			e, ok = changed, true
+		}
+	}
+	if !ok || e == nil {
+		return nil, nil
+	}
+	return cloneEvent(e), nil
+}
+
+// PendingEvents - return copies of not published events in order of ids
+func (t *memTx) PendingEvents(limit int64) ([]*models.OutboxEvent, error) {
+	var ids []primitive.ObjectID
+	for id := range t.data.pending {
+		if t.changes != nil {
+			if pending, found := t.changes.pending[id]; found && !pending {
+				continue
+			}
+		}
+		ids = append(ids, id)
+	}
+	if t.changes != nil {
+		for id, pending := range t.changes.pending {
+			if pending && !t.data.pending[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sortIds(ids)
+
+	var result []*models.OutboxEvent
+	for _, id := range ids {
+		if int64(len(result)) >= limit {
+			break
+		}
+		event, err := t.Event(id)
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+// PutEvent - insert or replace outbox event and update index of not published events
+func (t *memTx) PutEvent(e *models.OutboxEvent) error {
+	t.changes.events[e.Id] = cloneEvent(e)
+	t.changes.pending[e.Id] = e.PublishedAt == nil
+	return nil
+}
+
+// DeletePublishedEvents - remove events published before the time, return number of removed events
+func (t *memTx) DeletePublishedEvents(before time.Time) (int64, error) {
+	ids := make(map[primitive.ObjectID]bool, len(t.data.events))
+	for id := range t.data.events {
+		ids[id] = true
+	}
+	for id := range t.changes.events {
+		ids[id] = true
+	}
+
+	var deleted int64
+	for id := range ids {
+		e, err := t.Event(id)
+		if err != nil {
+			return 0, err
+		}
+		if e != nil && e.PublishedAt != nil && e.PublishedAt.Before(before) {
+			t.changes.events[id] = nil
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Rates - return copy of all exchange rates
+func (t *memTx) Rates() (models.ExchangeRates, error) {
+	rates := t.data.rates
+	if t.changes != nil && t.changes.rates != nil {
+		rates = t.changes.rates
+	}
+	return cloneRates(rates), nil
+}
+
+// PutRates - replace all exchange rates
+func (t *memTx) PutRates(rates models.ExchangeRates) error {
+	t.changes.rates = cloneRates(rates)
+	return nil
+}
+
+// Job - return copy of fetch job by id or nil if it doesn't exist
+func (t *memTx) Job(id primitive.ObjectID) (*models.FetchJob, error) {
+	job, ok := t.data.jobs[id]
+	if t.changes != nil {
+		if changed, found := t.changes.jobs[id]; found {
+			job, ok = changed, true
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+	return cloneJob(job), nil
+}
+
+// PutJob - insert or replace fetch job
+func (t *memTx) PutJob(job *models.FetchJob) error {
+	t.changes.jobs[job.Id] = cloneJob(job)
+	return nil
+}
+
+// product - return product by id from changes or from the store without copying
+func (t *memTx) product(id primitive.ObjectID) *models.Product {
+	if t.changes != nil {
+		if p, ok := t.changes.products[id]; ok {
+			return p
+		}
+	}
+	return t.data.products[id]
+}
+
+// commit - apply changes of transaction to documents of the store
+func (t *memTx) commit() {
+	for id, p := range t.changes.products {
+		t.data.products[id] = p
+	}
+	for name, id := range t.changes.names {
+		if id.IsZero() {
+			delete(t.data.names, name)
+		} else {
+			t.data.names[name] = id
+		}
+	}
+	for id, e := range t.changes.events {
+		if e == nil {
+			delete(t.data.events, id)
+		} else {
+			t.data.events[id] = e
+		}
+	}
+	for id, pending := range t.changes.pending {
+		if pending {
+			t.data.pending[id] = true
+		} else {
+			delete(t.data.pending, id)
+		}
+	}
+	if t.changes.rates != nil {
+		t.data.rates = t.changes.rates
+	}
+	for id, job := range t.changes.jobs {
+		t.data.jobs[id] = job
+	}
+}
+
+func cloneEvent(e *models.OutboxEvent) *models.OutboxEvent {
+	c := *e
+	if e.Payload != nil {
+		c.Payload = e.Payload.Clone()
+	}
+	if e.PublishedAt != nil {
+		at := *e.PublishedAt
+		c.PublishedAt = &at
+	}
+	return &c
+}
+
+func cloneJob(job *models.FetchJob) *models.FetchJob {
+	c := *job
+	if job.Finished != nil {
+		at := *job.Finished
+		c.Finished = &at
+	}
+	return &c
+}
+
+func cloneRates(rates models.ExchangeRates) models.ExchangeRates {
+	c := make(models.ExchangeRates, len(rates))
+	for currency, rate := range rates {
+		c[currency] = rate
+	}
+	return c
+}
+
+// sortIds - sort ids like keys of bucket in bolt store
+func sortIds(ids []primitive.ObjectID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+}
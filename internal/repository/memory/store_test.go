@@ -0,0 +1,256 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/ArturChopikian/grpc-server/internal/repository/conformance"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Test(t, openTest)
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	repos := openTest(t)
+
+	// created in order of ids
+	seed := []struct {
+		name     string
+		price    string
+		currency string
+	}{
+		{"cherry", "5", "USD"},
+		{"apple", "10.50", "USD"},
+		{"banana", "-1", "EUR"},
+		{"date", "10.5", "USD"},
+		{"elderberry", "0.000000001", "GBP"},
+	}
+	for _, s := range seed {
+		createTest(t, repos, s.name, s.price, s.currency)
+	}
+	discontinued := createTest(t, repos, "fig", "1", "USD")
+	require.NoError(t, repos.Products.Discontinue(ctx, discontinued.Id, time.Now()))
+
+	tests := []struct {
+		name    string
+		opts    *models.ListOptions
+		want    []string
+		wantErr error
+	}{
+		{name: "default order by id", opts: &models.ListOptions{}, want: []string{"cherry", "apple", "banana", "date", "elderberry"}},
+		{name: "with discontinued", opts: &models.ListOptions{IncludeDiscontinued: true}, want: []string{"cherry", "apple", "banana", "date", "elderberry", "fig"}},
+		{name: "name ascending", opts: &models.ListOptions{OrderBy: map[string]int32{"name": 1}}, want: []string{"apple", "banana", "cherry", "date", "elderberry"}},
+		{name: "name descending", opts: &models.ListOptions{OrderBy: map[string]int32{"name": -1}}, want: []string{"elderberry", "date", "cherry", "banana", "apple"}},
+		// equal prices are ordered by id
+		{name: "price ascending", opts: &models.ListOptions{OrderBy: map[string]int32{"price": 1}}, want: []string{"banana", "elderberry", "cherry", "apple", "date"}},
+		{name: "price descending", opts: &models.ListOptions{OrderBy: map[string]int32{"price": -1}}, want: []string{"date", "apple", "cherry", "elderberry", "banana"}},
+		{name: "currency ascending", opts: &models.ListOptions{OrderBy: map[string]int32{"currency": 1}}, want: []string{"banana", "elderberry", "cherry", "apple", "date"}},
+		{name: "first page", opts: &models.ListOptions{OrderBy: map[string]int32{"name": 1}, PageSize: 2}, want: []string{"apple", "banana"}},
+		{name: "second page", opts: &models.ListOptions{OrderBy: map[string]int32{"name": 1}, PageSize: 2, PageNumber: 1}, want: []string{"cherry", "date"}},
+		{name: "last page", opts: &models.ListOptions{OrderBy: map[string]int32{"name": 1}, PageSize: 2, PageNumber: 2}, want: []string{"elderberry"}},
+		{name: "page after last", opts: &models.ListOptions{OrderBy: map[string]int32{"name": 1}, PageSize: 2, PageNumber: 3}, want: nil},
+		{name: "unknown field", opts: &models.ListOptions{OrderBy: map[string]int32{"weight": 1}}, wantErr: models.InvalidOrderError},
+		{name: "invalid direction", opts: &models.ListOptions{OrderBy: map[string]int32{"name": 2}}, wantErr: models.InvalidOrderError},
+		{name: "several fields", opts: &models.ListOptions{OrderBy: map[string]int32{"name": 1, "price": 1}}, wantErr: models.InvalidOrderError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := repos.Products.List(ctx, tt.opts)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "expected %v, got %v", tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, names(products))
+		})
+	}
+}
+
+func TestListPageToken(t *testing.T) {
+	ctx := context.Background()
+	repos := openTest(t)
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		createTest(t, repos, name, "1", "USD")
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		desc  bool
+		want  [][]string
+	}{
+		{name: "by id", want: [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{name: "by equal prices", field: "price", want: [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{name: "by name descending", field: "name", desc: true, want: [][]string{{"e", "d"}, {"c", "b"}, {"a"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &models.ListOptions{PageSize: 2}
+			if tt.field != "" {
+				direction := int32(1)
+				if tt.desc {
+					direction = -1
+				}
+				opts.OrderBy = map[string]int32{tt.field: direction}
+			}
+
+			var pages [][]string
+			for {
+				products, err := repos.Products.List(ctx, opts)
+				require.NoError(t, err)
+				if len(products) == 0 {
+					break
+				}
+				pages = append(pages, names(products))
+
+				// token goes through its string form like in requests of clients
+				token, err := models.ParsePageToken(models.NewPageToken(products[len(products)-1], tt.field, tt.desc).Encode())
+				require.NoError(t, err)
+				opts.PageToken = token
+			}
+			assert.Equal(t, tt.want, pages)
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	repos := openTest(t)
+	missing := primitive.NewObjectID()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"Get", func() error { _, err := repos.Products.Get(ctx, "missing"); return err }},
+		{"GetById", func() error { _, err := repos.Products.GetById(ctx, missing); return err }},
+		{"UpdatePrice", func() error { return repos.Products.UpdatePrice(ctx, missing, testChange("1", "USD")) }},
+		{"Update", func() error {
+			name := "other"
+			_, err := repos.Products.Update(ctx, missing, &models.ProductUpdate{Name: &name})
+			return err
+		}},
+		{"Discontinue", func() error { return repos.Products.Discontinue(ctx, missing, time.Now()) }},
+		{"Restore", func() error { _, err := repos.Products.Restore(ctx, missing, ""); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			assert.True(t, errors.Is(err, models.NotFoundProductError), "expected NotFoundProductError, got %v", err)
+		})
+	}
+
+	// missing products are skipped by GetMany
+	products, err := repos.Products.GetMany(ctx, []primitive.ObjectID{missing}, []string{"missing"})
+	require.NoError(t, err)
+	assert.Empty(t, products)
+}
+
+func TestConcurrentUpdatePrice(t *testing.T) {
+	ctx := context.Background()
+	repos := openTest(t)
+	product := createTest(t, repos, "apple", "0", "USD")
+
+	const (
+		writers = 8
+		updates = 50
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*updates)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				price := models.Decimal{Units: int64(i + 1)}
+				change := models.NewPriceChange(price, "USD", "feed", models.PriceChangeFetch, "test")
+				if err := repos.Products.UpdatePrice(ctx, product.Id, change); err != nil {
+					errs <- err
+				}
+				if _, err := repos.Products.List(ctx, &models.ListOptions{}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	got, err := repos.Products.GetById(ctx, product.Id)
+	require.NoError(t, err)
+	// no update is lost
+	assert.Equal(t, uint64(1+writers*updates), got.Revision)
+	assert.Len(t, got.History, 1+writers*updates)
+}
+
+// openTest - return repositories with decorators on new empty store
+func openTest(t *testing.T) *repository.Repository {
+	t.Helper()
+
+	cfg, err := configs.NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Storage.Driver = "memory"
+
+	repos, err := repository.Open(cfg, logrus.NewEntry(logrus.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = repos.Close() })
+	return repos
+}
+
+// createTest - create product with manual price
+func createTest(t *testing.T, repos *repository.Repository, name, price, currency string) *models.Product {
+	t.Helper()
+
+	first := testChange(price, currency)
+	product := &models.Product{
+		Id:          primitive.NewObjectID(),
+		Name:        name,
+		Price:       first.Price,
+		Currency:    first.Currency,
+		PriceSource: first.Source,
+		Updated:     first.Changed,
+		Sources:     []*models.SourcePrice{models.NewSourcePrice(first)},
+		History:     []*models.PriceChange{first},
+	}
+	require.NoError(t, repos.Products.Create(context.Background(), product))
+	return product
+}
+
+func testChange(price, currency string) *models.PriceChange {
+	amount, err := models.ParseDecimal(price)
+	if err != nil {
+		panic(err)
+	}
+	return models.NewPriceChange(amount, currency, models.ManualSource, models.PriceChangeManual, "test")
+}
+
+func names(products []*models.Product) []string {
+	var result []string
+	for _, p := range products {
+		result = append(result, p.Name)
+	}
+	return result
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/ArturChopikian/grpc-server/internal/metrics"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// instrument - wrap repositories of storage opened by driver,
+// so latency and errors of their operations are recorded in metrics
+func instrument(repos *Repository, driver string) {
+	if repos.Products != nil {
+		repos.Products = &productsMetrics{next: repos.Products, driver: driver}
+	}
+	if repos.Outbox != nil {
+		repos.Outbox = &outboxMetrics{next: repos.Outbox, driver: driver}
+	}
+	if repos.Rates != nil {
+		repos.Rates = &ratesMetrics{next: repos.Rates, driver: driver}
+	}
+	if repos.Jobs != nil {
+		repos.Jobs = &jobsMetrics{next: repos.Jobs, driver: driver}
+	}
+}
+
+// observe - record latency of operation started at start and count it as failed
+// if err is not expected result of operation like not found product
+func observe(driver, repos, operation string, start time.Time, err error) {
+	metrics.RepositoryDuration.WithLabelValues(driver, repos, operation).Observe(time.Since(start).Seconds())
+	if err != nil && !expectedError(err) {
+		metrics.RepositoryErrors.WithLabelValues(driver, repos, operation).Inc()
+	}
+}
+
+// expectedError - return true if error is answer of storage, not its failure
+func expectedError(err error) bool {
+	return errors.Is(err, models.NotFoundProductError) ||
+		errors.Is(err, models.ProductExistsError) ||
+		errors.Is(err, models.NotFoundJobError) ||
+		errors.Is(err, models.InvalidOrderError) ||
+		errors.Is(err, models.InvalidPageTokenError) ||
+		errors.Is(err, models.ReadOnlyRatesError)
+}
+
+// productsMetrics - products repository which records metrics of its operations
+type productsMetrics struct {
+	next   ProductsReposInterface
+	driver string
+}
+
+func (r *productsMetrics) Get(ctx context.Context, name string) (p *models.Product, err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "get", start, err) }(time.Now())
+	return r.next.Get(ctx, name)
+}
+
+func (r *productsMetrics) GetById(ctx context.Context, id primitive.ObjectID) (p *models.Product, err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "get_by_id", start, err) }(time.Now())
+	return r.next.GetById(ctx, id)
+}
+
+func (r *productsMetrics) GetMany(ctx context.Context, ids []primitive.ObjectID, names []string) (ps []*models.Product, err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "get_many", start, err) }(time.Now())
+	return r.next.GetMany(ctx, ids, names)
+}
+
+func (r *productsMetrics) Create(ctx context.Context, product *models.Product) (err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "create", start, err) }(time.Now())
+	return r.next.Create(ctx, product)
+}
+
+func (r *productsMetrics) UpdatePrice(ctx context.Context, id primitive.ObjectID, change *models.PriceChange) (err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "update_price", start, err) }(time.Now())
+	return r.next.UpdatePrice(ctx, id, change)
+}
+
+func (r *productsMetrics) Update(ctx context.Context, id primitive.ObjectID, upd *models.ProductUpdate) (p *models.Product, err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "update", start, err) }(time.Now())
+	return r.next.Update(ctx, id, upd)
+}
+
+func (r *productsMetrics) Discontinue(ctx context.Context, id primitive.ObjectID, at time.Time) (err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "discontinue", start, err) }(time.Now())
+	return r.next.Discontinue(ctx, id, at)
+}
+
+func (r *productsMetrics) Restore(ctx context.Context, id primitive.ObjectID, source string) (p *models.Product, err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "restore", start, err) }(time.Now())
+	return r.next.Restore(ctx, id, source)
+}
+
+func (r *productsMetrics) MarkSeen(ctx context.Context, source string, ids []primitive.ObjectID, job primitive.ObjectID) (err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "mark_seen", start, err) }(time.Now())
+	return r.next.MarkSeen(ctx, source, ids, job)
+}
+
+func (r *productsMetrics) DiscontinueMissing(ctx context.Context, source string, job primitive.ObjectID, at time.Time) (n int64, err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "discontinue_missing", start, err) }(time.Now())
+	return r.next.DiscontinueMissing(ctx, source, job, at)
+}
+
+func (r *productsMetrics) List(ctx context.Context, opts *models.ListOptions) (ps []*models.Product, err error) {
+	defer func(start time.Time) { observe(r.driver, "products", "list", start, err) }(time.Now())
+	return r.next.List(ctx, opts)
+}
+
+// outboxMetrics - outbox repository which records metrics of its operations
+type outboxMetrics struct {
+	next   OutboxReposInterface
+	driver string
+}
+
+func (r *outboxMetrics) Pending(ctx context.Context, limit int64) (events []*models.OutboxEvent, err error) {
+	defer func(start time.Time) { observe(r.driver, "outbox", "pending", start, err) }(time.Now())
+	return r.next.Pending(ctx, limit)
+}
+
+func (r *outboxMetrics) MarkPublished(ctx context.Context, id primitive.ObjectID) (err error) {
+	defer func(start time.Time) { observe(r.driver, "outbox", "mark_published", start, err) }(time.Now())
+	return r.next.MarkPublished(ctx, id)
+}
+
+func (r *outboxMetrics) DeletePublished(ctx context.Context, before time.Time) (deleted int64, err error) {
+	defer func(start time.Time) { observe(r.driver, "outbox", "delete_published", start, err) }(time.Now())
+	return r.next.DeletePublished(ctx, before)
+}
+
+// ratesMetrics - rates repository which records metrics of its operations
+type ratesMetrics struct {
+	next   RatesReposInterface
+	driver string
+}
+
+func (r *ratesMetrics) List(ctx context.Context) (rates models.ExchangeRates, err error) {
+	defer func(start time.Time) { observe(r.driver, "rates", "list", start, err) }(time.Now())
+	return r.next.List(ctx)
+}
+
+func (r *ratesMetrics) Set(ctx context.Context, rates models.ExchangeRates, replace bool) (err error) {
+	defer func(start time.Time) { observe(r.driver, "rates", "set", start, err) }(time.Now())
+	return r.next.Set(ctx, rates, replace)
+}
+
+// jobsMetrics - fetch jobs repository which records metrics of its operations
+type jobsMetrics struct {
+	next   FetchJobsReposInterface
+	driver string
+}
+
+func (r *jobsMetrics) Get(ctx context.Context, id primitive.ObjectID) (job *models.FetchJob, err error) {
+	defer func(start time.Time) { observe(r.driver, "jobs", "get", start, err) }(time.Now())
+	return r.next.Get(ctx, id)
+}
+
+func (r *jobsMetrics) Save(ctx context.Context, job *models.FetchJob) (err error) {
+	defer func(start time.Time) { observe(r.driver, "jobs", "save", start, err) }(time.Now())
+	return r.next.Save(ctx, job)
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// outboxRepos - define all methods for communicating with outbox collection
+type outboxRepos struct {
+	conn *mongo.Collection
+	log  *logrus.Entry
+}
+
+// add - take event type and product snapshot and insert new event into outbox
+// must be called with mongo.SessionContext of the transaction which changed the product
+func (o *outboxRepos) add(ctx context.Context, eventType string, product *models.Product) error {
+	_, err := o.conn.InsertOne(ctx, models.NewOutboxEvent(eventType, product))
+	if err != nil {
+		return fmt.Errorf("repos: outbox: add: %w", err)
+	}
+	return nil
+}
+
+// addMany - take event type and product snapshots and insert event for each product into outbox
+// must be called with mongo.SessionContext of the transaction which changed the products
+func (o *outboxRepos) addMany(ctx context.Context, eventType string, products []*models.Product) error {
+	events := make([]interface{}, 0, len(products))
+	for _, p := range products {
+		events = append(events, models.NewOutboxEvent(eventType, p))
+	}
+
+	if _, err := o.conn.InsertMany(ctx, events); err != nil {
+		return fmt.Errorf("repos: outbox: addMany: %w", err)
+	}
+	return nil
+}
+
+// Pending - take limit and return the oldest not published events
+// or error if something went wrong
+func (o *outboxRepos) Pending(ctx context.Context, limit int64) ([]*models.OutboxEvent, error) {
+	filter := bson.M{"published_at": nil}
+	opts := options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetLimit(limit)
+
+	cur, err := o.conn.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*models.OutboxEvent
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MarkPublished - take id of the event and save time when it was published
+func (o *outboxRepos) MarkPublished(ctx context.Context, id primitive.ObjectID) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"published_at": time.Now()}}
+
+	if _, err := o.conn.UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("repos: outbox: MarkPublished: %w", err)
+	}
+	return nil
+}
+
+// DeletePublished - take time and remove events published before it
+// return number of removed events
+func (o *outboxRepos) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	filter := bson.M{"published_at": bson.M{"$lt": before}}
+
+	result, err := o.conn.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("repos: outbox: DeletePublished: %w", err)
+	}
+	return result.DeletedCount, nil
+}
+
+// newOutboxRepos - return new outboxRepos
+func newOutboxRepos(conn *mongo.Collection, log *logrus.Entry) *outboxRepos {
+	return &outboxRepos{
+		conn: conn,
+		log:  log,
+	}
+}
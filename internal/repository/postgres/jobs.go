@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// jobsRepos - state of fetch jobs in fetch_jobs table
+type jobsRepos struct {
+	db  *sql.DB
+	log *logrus.Entry
+}
+
+// Get - take id and return fetch job or NotFoundJobError
+func (j *jobsRepos) Get(ctx context.Context, id primitive.ObjectID) (*models.FetchJob, error) {
+	job := &models.FetchJob{Id: id}
+	var finished sql.NullTime
+
+	err := j.db.QueryRowContext(ctx, `SELECT url, source, full_snapshot, principal, status, error,
+			created, updated, restored, discontinued, started, finished
+		FROM fetch_jobs WHERE id = $1`, id.Hex()).
+		Scan(&job.URL, &job.Source, &job.FullSnapshot, &job.Principal, &job.Status, &job.Error,
+			&job.Result.Created, &job.Result.Updated, &job.Result.Restored, &job.Result.Discontinued,
+			&job.Started, &finished)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.NotFoundJobError
+		}
+		return nil, fmt.Errorf("repos: jobs: Get: %w", err)
+	}
+	if finished.Valid {
+		job.Finished = &finished.Time
+	}
+	return job, nil
+}
+
+// Save - take fetch job and insert or replace it
+func (j *jobsRepos) Save(ctx context.Context, job *models.FetchJob) error {
+	_, err := j.db.ExecContext(ctx, `INSERT INTO fetch_jobs (id, url, source, full_snapshot, principal, status, error,
+			created, updated, restored, discontinued, started, finished)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, error = excluded.error,
+			created = excluded.created, updated = excluded.updated, restored = excluded.restored,
+			discontinued = excluded.discontinued, finished = excluded.finished`,
+		job.Id.Hex(), job.URL, job.Source, job.FullSnapshot, job.Principal, job.Status, job.Error,
+		job.Result.Created, job.Result.Updated, job.Result.Restored, job.Result.Discontinued,
+		job.Started, job.Finished)
+	if err != nil {
+		return fmt.Errorf("repos: jobs: Save: %w", err)
+	}
+	return nil
+}
+
+// newJobsRepos - return new jobsRepos
+func newJobsRepos(db *sql.DB, log *logrus.Entry) *jobsRepos {
+	return &jobsRepos{
+		db:  db,
+		log: log,
+	}
+}
@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"strings"
+)
+
+// if page size equal zero, use default value instead of zero
+const defaultPageSize = 100
+
+// columns of the list which can be used for ordering, text columns are compared by bytes like in MongoDB
+var orderColumns = map[string]string{
+	"name":          `q.name COLLATE "C"`,
+	"price":         `q.price`,
+	"currency":      `q.currency COLLATE "C"`,
+	"price_source":  `q.price_source COLLATE "C"`,
+	"updated":       `q.updated`,
+	"price_updates": `q.price_updates`,
+	"revision":      `q.revision`,
+}
+
+// List - take options with ordering, paging, filters and price mode
+// choose price of product for the price mode and convert it into currency of the list in one query,
+// page token is applied as condition on ordered columns (keyset pagination)
+// return page of products with sources and without history
+func (p *productsRepos) List(ctx context.Context, listOpts *models.ListOptions) ([]*models.Product, error) {
+	field, desc, err := listOpts.Order()
+	if err != nil {
+		return nil, err
+	}
+
+	var a args
+	query := listQuery(&a, listOpts)
+
+	// products with equal values are ordered by id
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+
+	var where []string
+	if listOpts.Currency != "" {
+		// there is no rate for currency of product
+		where = append(where, `q.price IS NOT NULL`)
+	}
+	if token := listOpts.PageToken; token != nil {
+		id := a.add(token.Id.Hex())
+		if field == "" {
+			where = append(where, fmt.Sprintf(`q.id %s %s`, op, id))
+		} else {
+			value := tokenValue(&a, token.Value)
+			where = append(where, fmt.Sprintf(`(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND q.id %[2]s %[4]s))`,
+				orderColumns[field], op, value, id))
+		}
+	}
+
+	order := `q.id ` + direction
+	if field != "" {
+		order = orderColumns[field] + ` ` + direction + `, ` + order
+	}
+
+	pageSize, pageNumber := int64(listOpts.PageSize), int64(listOpts.PageNumber)
+	limit := pageSize
+	if pageSize == 0 {
+		limit = defaultPageSize
+	}
+	var skip int64
+	if pageNumber != 0 && listOpts.PageToken == nil {
+		skip = pageSize * pageNumber
+	}
+
+	query = `SELECT ` + productColumns + ` FROM (` + query + `) q`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += fmt.Sprintf(` ORDER BY %s LIMIT %s OFFSET %s`, order, a.add(limit), a.add(skip))
+
+	products, err := findMany(ctx, p.db, query, a...)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// listQuery - return query which selects productColumns of filtered products
+// with price, currency, price_source, updated and price_updates of the source chosen for the price mode,
+// price is converted into currency of the list, it is null if there is no rate
+func listQuery(a *args, listOpts *models.ListOptions) string {
+	rates := ratesTable(a, listOpts.Rates)
+
+	var (
+		join  string
+		where []string
+	)
+	if !listOpts.IncludeDiscontinued {
+		where = append(where, `p.discontinued IS NULL`)
+	}
+
+	switch listOpts.PriceMode {
+	case models.PriceModeLowest:
+		// prices of sources are compared in currency of the list
+		// or in base currency of rates if currency isn't set
+		compared := convertExpr(a, `s.price`, `s.currency`, listOpts.Rates, listOpts.Currency)
+		if listOpts.Currency == "" {
+			compared = `COALESCE(` + compared + `, s.price)`
+		}
+		join = `LEFT JOIN LATERAL (SELECT s.* FROM product_sources s
+			WHERE s.product_id = p.id AND s.discontinued IS NULL
+			ORDER BY ` + compared + ` ASC NULLS LAST, s.position LIMIT 1) src ON true`
+	case models.PriceModeSource:
+		join = `JOIN product_sources src ON src.product_id = p.id AND src.source = ` + a.add(listOpts.Source)
+	}
+
+	price, currency, priceSource, updated, priceUpdates := `p.price`, `p.currency`, `p.price_source`, `p.updated`, `p.price_updates`
+	if join != "" {
+		// products without sources keep their own price
+		price = `COALESCE(src.price, p.price)`
+		currency = `COALESCE(src.currency, p.currency)`
+		priceSource = `COALESCE(src.source, p.price_source)`
+		updated = `COALESCE(src.updated, p.updated)`
+		priceUpdates = `COALESCE(src.price_updates, p.price_updates)`
+	}
+	if listOpts.Currency != "" {
+		price = convertExpr(a, price, currency, listOpts.Rates, listOpts.Currency)
+		currency = a.add(listOpts.Currency) + `::text`
+	}
+
+	query := fmt.Sprintf(`WITH list_rates (currency, rate) AS (%s)
+		SELECT p.id, p.name, %s AS price, %s AS currency, %s AS price_source, %s AS updated,
+			%s AS price_updates, p.revision, p.discontinued
+		FROM products p %s`, rates, price, currency, priceSource, updated, priceUpdates, join)
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	return query
+}
+
+// ratesTable - return query with rows (currency, rate) of exchange rates
+func ratesTable(a *args, rates models.ExchangeRates) string {
+	if len(rates) == 0 {
+		return `SELECT NULL::text, NULL::numeric WHERE false`
+	}
+
+	values := make([]string, 0, len(rates))
+	for currency, rate := range rates {
+		values = append(values, fmt.Sprintf(`(%s::text, %s::numeric)`, a.add(currency), a.add(rate.String())))
+	}
+	return `VALUES ` + strings.Join(values, `, `)
+}
+
+// convertExpr - take expressions with price and its currency, rates and currency to convert into
+// return expression with converted price, it is null if there is no rate for currency
+// if to is empty, price is converted into base currency of rates
+// converted price is rounded to 9 fractional digits like decoded prices, so ordering and page tokens compare the same values
+func convertExpr(a *args, price, currency string, rates models.ExchangeRates, to string) string {
+	inBase := fmt.Sprintf(`(%s * (SELECT r.rate FROM list_rates r WHERE r.currency = %s))`, price, currency)
+	if to == "" {
+		return fmt.Sprintf(`round(%s, %d)`, inBase, models.DecimalDigits)
+	}
+
+	converted := `NULL`
+	if rateTo, ok := rates[to]; ok && rateTo.Sign() != 0 {
+		converted = fmt.Sprintf(`round(%s / %s::numeric, %d)`, inBase, a.add(rateTo.String()), models.DecimalDigits)
+	}
+	return fmt.Sprintf(`(CASE WHEN %s = %s THEN %s ELSE %s END)`, currency, a.add(to), price, converted)
+}
+
+// tokenValue - add value of page token as argument and return its placeholder with type
+func tokenValue(a *args, value interface{}) string {
+	switch v := value.(type) {
+	case models.Decimal:
+		return a.add(v.String()) + `::numeric`
+	case string:
+		return a.add(v) + `::text COLLATE "C"`
+	}
+	return a.add(value)
+}
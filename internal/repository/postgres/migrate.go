@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationsLock - key of advisory lock, so only one server applies migrations
+const migrationsLock = 7295174
+
+// migration - one file from migrations, version is number before "_" in its name
+type migration struct {
+	version int
+	name    string
+}
+
+// migrate - apply migrations which are not in schema_migrations, each in its own transaction
+// return error if database has migrations which are unknown to this binary
+func migrate(ctx context.Context, db *sql.DB, log *logrus.Entry) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		applied timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("postgres: create schema_migrations: %w", err)
+	}
+
+	known, err := listMigrations()
+	if err != nil {
+		return err
+	}
+
+	var latest sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT max(version) FROM schema_migrations`).Scan(&latest); err != nil {
+		return fmt.Errorf("postgres: read schema version: %w", err)
+	}
+	if last := known[len(known)-1].version; latest.Valid && int(latest.Int64) > last {
+		return fmt.Errorf("postgres: schema version %d is newer than the latest known migration %d", latest.Int64, last)
+	}
+
+	for _, m := range known {
+		if err := applyMigration(ctx, db, m, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMigration - apply migration if it isn't applied yet
+func applyMigration(ctx context.Context, db *sql.DB, m migration, log *logrus.Entry) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationsLock); err != nil {
+		return fmt.Errorf("postgres: lock migrations: %w", err)
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.version).Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("postgres: migration %s: %w", m.name, err)
+	}
+	if applied {
+		return nil
+	}
+
+	script, err := migrations.ReadFile("migrations/" + m.name)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return fmt.Errorf("postgres: migration %s: %w", m.name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+		return fmt.Errorf("postgres: migration %s: %w", m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("postgres: migration %s: %w", m.name, err)
+	}
+
+	log.WithField("migration", m.name).Info("postgres: applied migration")
+	return nil
+}
+
+// listMigrations - return embedded migrations sorted by version
+func listMigrations() ([]migration, error) {
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []migration
+	for _, entry := range entries {
+		prefix := strings.SplitN(entry.Name(), "_", 2)[0]
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("postgres: invalid name of migration %s", entry.Name())
+		}
+		result = append(result, migration{version: version, name: entry.Name()})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("postgres: no migrations")
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].version < result[j].version
+	})
+	return result, nil
+}
@@ -0,0 +1,43 @@
+-- products with the latest price, prices of sources and history of prices
+CREATE TABLE products (
+    id            text PRIMARY KEY,
+    name          text        NOT NULL UNIQUE,
+    price         numeric     NOT NULL,
+    currency      text        NOT NULL,
+    price_source  text        NOT NULL,
+    updated       timestamptz NOT NULL,
+    price_updates bigint      NOT NULL DEFAULT 0,
+    revision      bigint      NOT NULL DEFAULT 0,
+    discontinued  timestamptz
+);
+
+CREATE INDEX products_price_idx ON products (price, id);
+CREATE INDEX products_updated_idx ON products (updated, id);
+
+CREATE TABLE product_sources (
+    product_id    text        NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    source        text        NOT NULL,
+    -- order in which sources were added
+    position      integer     NOT NULL,
+    price         numeric     NOT NULL,
+    currency      text        NOT NULL,
+    updated       timestamptz NOT NULL,
+    price_updates bigint      NOT NULL DEFAULT 0,
+    discontinued  timestamptz,
+    PRIMARY KEY (product_id, source)
+);
+
+CREATE INDEX product_sources_source_idx ON product_sources (source) WHERE discontinued IS NULL;
+
+CREATE TABLE price_history (
+    id         bigserial PRIMARY KEY,
+    product_id text        NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price      numeric     NOT NULL,
+    currency   text        NOT NULL,
+    source     text        NOT NULL,
+    changed    timestamptz NOT NULL,
+    kind       text        NOT NULL,
+    principal  text        NOT NULL
+);
+
+CREATE INDEX price_history_product_idx ON price_history (product_id, id);
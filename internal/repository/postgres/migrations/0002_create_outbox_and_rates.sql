@@ -0,0 +1,19 @@
+-- product change events written in the same transaction as the change
+CREATE TABLE outbox (
+    id           text PRIMARY KEY,
+    product_id   text        NOT NULL,
+    seq          bigint      NOT NULL,
+    type         text        NOT NULL,
+    payload      jsonb       NOT NULL,
+    created      timestamptz NOT NULL,
+    published_at timestamptz
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+
+-- value of one unit of currency in base currency
+CREATE TABLE rates (
+    currency text PRIMARY KEY,
+    rate     numeric     NOT NULL,
+    updated  timestamptz NOT NULL
+);
@@ -0,0 +1,16 @@
+-- state of fetches, interrupted fetches are kept with changes made before shutdown
+CREATE TABLE fetch_jobs (
+    id            text PRIMARY KEY,
+    url           text        NOT NULL,
+    source        text        NOT NULL,
+    full_snapshot boolean     NOT NULL,
+    principal     text        NOT NULL,
+    status        text        NOT NULL,
+    error         text        NOT NULL,
+    created       bigint      NOT NULL,
+    updated       bigint      NOT NULL,
+    restored      bigint      NOT NULL,
+    discontinued  bigint      NOT NULL,
+    started       timestamptz NOT NULL,
+    finished      timestamptz
+);
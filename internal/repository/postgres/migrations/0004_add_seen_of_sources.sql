@@ -0,0 +1,5 @@
+-- id of the last full snapshot fetch which had the product, prices of the source
+-- which weren't seen by the fetch are discontinued after it
+ALTER TABLE product_sources ADD COLUMN seen text NOT NULL DEFAULT '';
+
+CREATE INDEX product_sources_seen_idx ON product_sources (source, seen) WHERE discontinued IS NULL;
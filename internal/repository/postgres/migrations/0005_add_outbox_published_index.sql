@@ -0,0 +1,2 @@
+-- published events are removed by relay after retention time
+CREATE INDEX outbox_published_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// outboxRepos - outbox events in outbox table, payload is saved as JSON
+type outboxRepos struct {
+	db  *sql.DB
+	log *logrus.Entry
+}
+
+// addEvent - take event type and product snapshot and insert new event into outbox
+// must be called with transaction which changed the product
+func addEvent(ctx context.Context, q querier, eventType string, product *models.Product) error {
+	event := models.NewOutboxEvent(eventType, product)
+
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("repos: outbox: add: %w", err)
+	}
+
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO outbox (id, product_id, seq, type, payload, created) VALUES ($1, $2, $3, $4, $5, $6)`,
+		event.Id.Hex(), event.ProductId.Hex(), int64(event.Seq), event.Type, payload, event.Created)
+	if err != nil {
+		return fmt.Errorf("repos: outbox: add: %w", err)
+	}
+	return nil
+}
+
+// Pending - take limit and return the oldest not published events
+func (o *outboxRepos) Pending(ctx context.Context, limit int64) ([]*models.OutboxEvent, error) {
+	rows, err := o.db.QueryContext(ctx,
+		`SELECT id, product_id, seq, type, payload, created FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT $1`,
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*models.OutboxEvent
+	for rows.Next() {
+		var (
+			id, productId string
+			seq           int64
+			payload       []byte
+			event         = &models.OutboxEvent{}
+		)
+		if err := rows.Scan(&id, &productId, &seq, &event.Type, &payload, &event.Created); err != nil {
+			return nil, err
+		}
+		if event.Id, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, fmt.Errorf("repos: outbox: Pending: %w", err)
+		}
+		if event.ProductId, err = primitive.ObjectIDFromHex(productId); err != nil {
+			return nil, fmt.Errorf("repos: outbox: Pending: %w", err)
+		}
+		event.Seq = uint64(seq)
+		if err := json.Unmarshal(payload, &event.Payload); err != nil {
+			return nil, fmt.Errorf("repos: outbox: Pending: decoding payload: %w", err)
+		}
+		result = append(result, event)
+	}
+	return result, rows.Err()
+}
+
+// MarkPublished - take id of the event and save time when it was published
+func (o *outboxRepos) MarkPublished(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := o.db.ExecContext(ctx, `UPDATE outbox SET published_at = $1 WHERE id = $2`, time.Now(), id.Hex()); err != nil {
+		return fmt.Errorf("repos: outbox: MarkPublished: %w", err)
+	}
+	return nil
+}
+
+// DeletePublished - take time and remove events published before it
+// return number of removed events
+func (o *outboxRepos) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	result, err := o.db.ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("repos: outbox: DeletePublished: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// newOutboxRepos - return new outboxRepos
+func newOutboxRepos(db *sql.DB, log *logrus.Entry) *outboxRepos {
+	return &outboxRepos{
+		db:  db,
+		log: log,
+	}
+}
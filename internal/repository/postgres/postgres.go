@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+func init() {
+	repository.Register("postgres", open)
+}
+
+// open - connect to PostgreSQL from config, apply migrations and return repositories on it
+func open(cfg *configs.Config, log *logrus.Entry) (*repository.Repository, error) {
+	if cfg.Storage.PostgresDSN == "" {
+		return nil, fmt.Errorf("postgres: STORAGE_POSTGRES_DSN is required")
+	}
+
+	db, err := sql.Open("postgres", cfg.Storage.PostgresDSN)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("postgres: ping: %w", err)
+	}
+	if err := migrate(ctx, db, log); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return NewRepository(db, log), nil
+}
+
+// NewRepository - return repositories on PostgreSQL database with applied migrations
+func NewRepository(db *sql.DB, log *logrus.Entry) *repository.Repository {
+	return &repository.Repository{
+		Products: newProductsRepos(db, log),
+		Outbox:   newOutboxRepos(db, log),
+		Rates:    newRatesRepos(db, log),
+		Jobs:     newJobsRepos(db, log),
+		Closer:   db.Close,
+		Pinger:   db.PingContext,
+	}
+}
+
+// querier - common methods of sql.DB and sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// withTx - run fn inside transaction, it is committed if fn returns nil and rolled back otherwise
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// isUniqueViolation - check that error is violation of unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// args - arguments of query, add returns placeholder of added argument
+type args []interface{}
+
+func (a *args) add(v interface{}) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
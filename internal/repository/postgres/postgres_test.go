@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/ArturChopikian/grpc-server/internal/repository/conformance"
+	"github.com/sirupsen/logrus"
+	"os"
+	"testing"
+)
+
+// connection string of throwaway database for tests, all rows of its tables are deleted
+const testDSNEnv = "POSTGRES_TEST_DSN"
+
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	conformance.Test(t, func(t *testing.T) *repository.Repository {
+		cfg, err := configs.NewConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Storage.Driver = "postgres"
+		cfg.Storage.PostgresDSN = dsn
+
+		repos, err := repository.Open(cfg, logrus.NewEntry(logrus.New()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = repos.Close() })
+
+		truncate(t, dsn)
+		return repos
+	})
+}
+
+// truncate - delete rows of all tables, migrations are kept
+func truncate(t *testing.T, dsn string) {
+	t.Helper()
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(context.Background(),
+		"TRUNCATE products, product_sources, price_history, outbox, rates, fetch_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,493 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// columns of products table in order of scanProduct
+const productColumns = `id, name, price, currency, price_source, updated, price_updates, revision, discontinued`
+
+// productsRepos - products in products, product_sources and price_history tables
+type productsRepos struct {
+	db  *sql.DB
+	log *logrus.Entry
+}
+
+// Get - takes a name and return the product with this name and history of its price
+// or NotFoundProductError if product not found
+func (p *productsRepos) Get(ctx context.Context, name string) (*models.Product, error) {
+	product, err := findOne(ctx, p.db, `name = $1`, false, name)
+	if err != nil {
+		if err == models.NotFoundProductError {
+			return nil, err
+		}
+		return nil, fmt.Errorf("repos: Get: %w", err)
+	}
+	return product, nil
+}
+
+// GetById - takes an id and return the product with this id and history of its price
+// or NotFoundProductError if product not found
+func (p *productsRepos) GetById(ctx context.Context, id primitive.ObjectID) (*models.Product, error) {
+	product, err := findOne(ctx, p.db, `id = $1`, false, id.Hex())
+	if err != nil {
+		if err == models.NotFoundProductError {
+			return nil, err
+		}
+		return nil, fmt.Errorf("repos: GetById: %w", err)
+	}
+	return product, nil
+}
+
+// GetMany - takes ids and names and return all found products without history,
+// missing products are skipped
+func (p *productsRepos) GetMany(ctx context.Context, ids []primitive.ObjectID, names []string) ([]*models.Product, error) {
+	if len(ids) == 0 && len(names) == 0 {
+		return nil, nil
+	}
+	hexIds := make([]string, 0, len(ids))
+	for _, id := range ids {
+		hexIds = append(hexIds, id.Hex())
+	}
+
+	products, err := findMany(ctx, p.db,
+		`SELECT `+productColumns+` FROM products WHERE id = ANY($1) OR name = ANY($2) ORDER BY id`,
+		pq.Array(hexIds), pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// Create - take the product and insert it with its sources and history
+// together with product.created event in outbox
+// return ProductExistsError if product with the same name exists
+func (p *productsRepos) Create(ctx context.Context, product *models.Product) error {
+	product.Revision = 1
+
+	err := withTx(ctx, p.db, func(tx *sql.Tx) error {
+		if err := saveProduct(ctx, tx, product, true, product.History); err != nil {
+			return err
+		}
+		return addEvent(ctx, tx, models.EventProductCreated, product)
+	})
+	if err != nil {
+		return p.wrap("Create", err)
+	}
+	return nil
+}
+
+// UpdatePrice - take id and change of price for product from the source,
+// apply it like models.Product.ApplyPriceChange and write product.price_updated event in the same transaction
+func (p *productsRepos) UpdatePrice(ctx context.Context, id primitive.ObjectID, change *models.PriceChange) error {
+	_, err := p.update(ctx, id, models.EventProductPriceUpdated, func(product *models.Product) error {
+		product.ApplyPriceChange(change)
+		product.Revision++
+		return nil
+	})
+	if err != nil {
+		return p.wrap("UpdatePrice", err)
+	}
+	return nil
+}
+
+// Update - take id and manual changes of product
+// change of price is applied like in UpdatePrice
+// return updated product, NotFoundProductError if product not found
+// or ProductExistsError if new name is taken
+func (p *productsRepos) Update(ctx context.Context, id primitive.ObjectID, upd *models.ProductUpdate) (*models.Product, error) {
+	product, err := p.update(ctx, id, models.EventProductUpdated, func(product *models.Product) error {
+		if upd.Name != nil {
+			product.Name = *upd.Name
+			product.Revision++
+		}
+		if upd.Price != nil {
+			product.ApplyPriceChange(upd.Price)
+			product.Revision++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, p.wrap("Update", err)
+	}
+	return product, nil
+}
+
+// Discontinue - take id and time, mark the product as discontinued (soft delete)
+// and write product.discontinued event in outbox in the same transaction
+// return NotFoundProductError if product not found
+func (p *productsRepos) Discontinue(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := p.update(ctx, id, models.EventProductDiscontinued, func(product *models.Product) error {
+		product.Discontinued = &at
+		product.Revision++
+		return nil
+	})
+	if err != nil {
+		return p.wrap("Discontinue", err)
+	}
+	return nil
+}
+
+// Restore - take id and source, make discontinued product and its price from the source active again,
+// if source is empty only product is restored
+// write product.restored event in outbox in the same transaction
+// return restored product or NotFoundProductError if product or its source not found
+func (p *productsRepos) Restore(ctx context.Context, id primitive.ObjectID, source string) (*models.Product, error) {
+	product, err := p.update(ctx, id, models.EventProductRestored, func(product *models.Product) error {
+		if !product.Restore(source) {
+			return models.NotFoundProductError
+		}
+		product.Revision++
+		return nil
+	})
+	if err != nil {
+		return nil, p.wrap("Restore", err)
+	}
+	return product, nil
+}
+
+// MarkSeen - take source, ids of products from full snapshot of its feed and id of fetch job
+// stamp prices of the source of the products with the job, it isn't change of products,
+// so revision isn't incremented and events aren't written
+func (p *productsRepos) MarkSeen(ctx context.Context, source string, ids []primitive.ObjectID, job primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	hexIds := make([]string, 0, len(ids))
+	for _, id := range ids {
+		hexIds = append(hexIds, id.Hex())
+	}
+	_, err := p.db.ExecContext(ctx, `UPDATE product_sources SET seen = $3 WHERE source = $1 AND product_id = ANY($2)`,
+		source, pq.Array(hexIds), job.Hex())
+	if err != nil {
+		return fmt.Errorf("repos: MarkSeen: %w", err)
+	}
+	return nil
+}
+
+// DiscontinueMissing - take source, id of fetch job of full snapshot of its feed and time
+// mark active prices from this source which weren't stamped with the job by MarkSeen as discontinued
+// products without active sources become discontinued
+// write product.discontinued or product.updated events in outbox in the same transaction
+// return number of discontinued products
+func (p *productsRepos) DiscontinueMissing(ctx context.Context, source string, job primitive.ObjectID, at time.Time) (int64, error) {
+	var count int64
+
+	err := withTx(ctx, p.db, func(tx *sql.Tx) error {
+		count = 0
+
+		products, err := findMany(ctx, tx, `SELECT `+productColumns+` FROM products p
+			WHERE EXISTS (SELECT 1 FROM product_sources s
+				WHERE s.product_id = p.id AND s.source = $1 AND s.discontinued IS NULL AND s.seen <> $2)
+			ORDER BY p.id FOR UPDATE`, source, job.Hex())
+		if err != nil {
+			return err
+		}
+
+		for _, product := range products {
+			eventType := models.EventProductUpdated
+			if product.DiscontinueSource(source, at) {
+				eventType = models.EventProductDiscontinued
+				count++
+			}
+			product.Revision++
+
+			if err := saveProduct(ctx, tx, product, false, nil); err != nil {
+				return err
+			}
+			if err := addEvent(ctx, tx, eventType, product); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repos: DiscontinueMissing: %w", err)
+	}
+	return count, nil
+}
+
+// update - take id, type of event and fn which changes the product,
+// lock the product, apply fn, save it and write event with changed product in the same transaction
+// return changed product with history or NotFoundProductError if product not found
+func (p *productsRepos) update(ctx context.Context, id primitive.ObjectID, eventType string, fn func(product *models.Product) error) (*models.Product, error) {
+	var result *models.Product
+
+	err := withTx(ctx, p.db, func(tx *sql.Tx) error {
+		product, err := findOne(ctx, tx, `id = $1`, true, id.Hex())
+		if err != nil {
+			return err
+		}
+
+		known := len(product.History)
+		if err := fn(product); err != nil {
+			return err
+		}
+		if err := saveProduct(ctx, tx, product, false, product.History[known:]); err != nil {
+			return err
+		}
+
+		result = product
+		return addEvent(ctx, tx, eventType, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findOne - take condition with one argument and return the first product which matches it
+// with its sources and history, lock it for update if forUpdate is true
+// return NotFoundProductError if product not found
+func findOne(ctx context.Context, q querier, cond string, forUpdate bool, arg interface{}) (*models.Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products WHERE ` + cond
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	product, err := scanProduct(q.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.NotFoundProductError
+		}
+		return nil, fmt.Errorf("finding product: %w", err)
+	}
+
+	if err := loadSources(ctx, q, []*models.Product{product}); err != nil {
+		return nil, err
+	}
+	if product.History, err = loadHistory(ctx, q, product.Id); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// findMany - take query which selects productColumns and return found products with sources without history
+func findMany(ctx context.Context, q querier, query string, queryArgs ...interface{}) ([]*models.Product, error) {
+	rows, err := q.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// rows must be closed before the next query of the same transaction
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := loadSources(ctx, q, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanner - common method of sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct - scan product from row with productColumns
+func scanProduct(row scanner) (*models.Product, error) {
+	var (
+		id, price    string
+		priceUpdates int64
+		revision     int64
+		discontinued sql.NullTime
+		product      = &models.Product{}
+	)
+
+	err := row.Scan(&id, &product.Name, &price, &product.Currency, &product.PriceSource,
+		&product.Updated, &priceUpdates, &revision, &discontinued)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.Id, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if product.Price, err = models.ParseRoundedDecimal(price); err != nil {
+		return nil, err
+	}
+	product.PriceUpdates = uint32(priceUpdates)
+	product.Revision = uint64(revision)
+	if discontinued.Valid {
+		product.Discontinued = &discontinued.Time
+	}
+	return product, nil
+}
+
+// loadSources - load prices of sources of products in order they were added
+func loadSources(ctx context.Context, q querier, products []*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	byId := make(map[string]*models.Product, len(products))
+	ids := make([]string, 0, len(products))
+	for _, p := range products {
+		byId[p.Id.Hex()] = p
+		ids = append(ids, p.Id.Hex())
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT product_id, source, price, currency, updated, price_updates, discontinued, seen
+		FROM product_sources WHERE product_id = ANY($1) ORDER BY product_id, position`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("finding sources: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			productId, price string
+			seen             string
+			priceUpdates     int64
+			discontinued     sql.NullTime
+			sp               = &models.SourcePrice{}
+		)
+		err := rows.Scan(&productId, &sp.Source, &price, &sp.Currency, &sp.Updated, &priceUpdates, &discontinued, &seen)
+		if err != nil {
+			return fmt.Errorf("decoding sources: %w", err)
+		}
+		if seen != "" {
+			if sp.Seen, err = primitive.ObjectIDFromHex(seen); err != nil {
+				return fmt.Errorf("decoding sources: %w", err)
+			}
+		}
+		if sp.Price, err = models.ParseRoundedDecimal(price); err != nil {
+			return fmt.Errorf("decoding sources: %w", err)
+		}
+		sp.PriceUpdates = uint32(priceUpdates)
+		if discontinued.Valid {
+			sp.Discontinued = &discontinued.Time
+		}
+
+		if p := byId[productId]; p != nil {
+			p.Sources = append(p.Sources, sp)
+		}
+	}
+	return rows.Err()
+}
+
+// loadHistory - load history of price of the product in order of changes
+func loadHistory(ctx context.Context, q querier, id primitive.ObjectID) ([]*models.PriceChange, error) {
+	rows, err := q.QueryContext(ctx, `SELECT price, currency, source, changed, kind, principal
+		FROM price_history WHERE product_id = $1 ORDER BY id`, id.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("finding history: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.PriceChange
+	for rows.Next() {
+		var (
+			price  string
+			change = &models.PriceChange{}
+		)
+		if err := rows.Scan(&price, &change.Currency, &change.Source, &change.Changed, &change.Kind, &change.Principal); err != nil {
+			return nil, fmt.Errorf("decoding history: %w", err)
+		}
+		if change.Price, err = models.ParseRoundedDecimal(price); err != nil {
+			return nil, fmt.Errorf("decoding history: %w", err)
+		}
+		result = append(result, change)
+	}
+	return result, rows.Err()
+}
+
+// saveProduct - insert product or update existing one, insert or update its sources
+// and append new changes to history of price
+func saveProduct(ctx context.Context, q querier, p *models.Product, insert bool, changes []*models.PriceChange) error {
+	values := []interface{}{
+		p.Id.Hex(), p.Name, p.Price.String(), p.Currency, p.PriceSource,
+		p.Updated, int64(p.PriceUpdates), int64(p.Revision), p.Discontinued,
+	}
+
+	var err error
+	if insert {
+		_, err = q.ExecContext(ctx, `INSERT INTO products (`+productColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`, values...)
+	} else {
+		_, err = q.ExecContext(ctx, `UPDATE products SET name = $2, price = $3, currency = $4, price_source = $5,
+			updated = $6, price_updates = $7, revision = $8, discontinued = $9 WHERE id = $1`, values...)
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ProductExistsError
+		}
+		return err
+	}
+
+	for i, sp := range p.Sources {
+		_, err := q.ExecContext(ctx, `INSERT INTO product_sources
+			(product_id, source, position, price, currency, updated, price_updates, discontinued, seen)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (product_id, source) DO UPDATE SET price = excluded.price, currency = excluded.currency,
+				updated = excluded.updated, price_updates = excluded.price_updates, discontinued = excluded.discontinued,
+				seen = excluded.seen`,
+			p.Id.Hex(), sp.Source, i, sp.Price.String(), sp.Currency, sp.Updated, int64(sp.PriceUpdates), sp.Discontinued,
+			seenHex(sp.Seen))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range changes {
+		_, err := q.ExecContext(ctx, `INSERT INTO price_history (product_id, price, currency, source, changed, kind, principal)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			p.Id.Hex(), c.Price.String(), c.Currency, c.Source, c.Changed, c.Kind, c.Principal)
+		if err != nil {
+			return err
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// only the latest changes are kept like in MongoDB
+	_, err = q.ExecContext(ctx, `DELETE FROM price_history WHERE product_id = $1 AND id <= (
+		SELECT id FROM price_history WHERE product_id = $1 ORDER BY id DESC OFFSET $2 LIMIT 1)`,
+		p.Id.Hex(), models.HistoryLimit)
+	return err
+}
+
+// seenHex - return id of fetch job which saw the price or empty string if no full snapshot had it
+func seenHex(job primitive.ObjectID) string {
+	if job.IsZero() {
+		return ""
+	}
+	return job.Hex()
+}
+
+// wrap - return errors of models as is, so callers can check them, and wrap others
+func (p *productsRepos) wrap(method string, err error) error {
+	if errors.Is(err, models.NotFoundProductError) || errors.Is(err, models.ProductExistsError) {
+		return err
+	}
+	return fmt.Errorf("repos: %s: %w", method, err)
+}
+
+// newProductsRepos - return new productsRepos
+func newProductsRepos(db *sql.DB, log *logrus.Entry) *productsRepos {
+	return &productsRepos{
+		db:  db,
+		log: log,
+	}
+}
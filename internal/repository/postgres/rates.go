@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// ratesRepos - exchange rates in rates table
+type ratesRepos struct {
+	db  *sql.DB
+	log *logrus.Entry
+}
+
+// List - return all exchange rates
+func (r *ratesRepos) List(ctx context.Context) (models.ExchangeRates, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT currency, rate FROM rates`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := models.ExchangeRates{}
+	for rows.Next() {
+		var currency, rate string
+		if err := rows.Scan(&currency, &rate); err != nil {
+			return nil, err
+		}
+		if result[currency], err = models.ParseRoundedDecimal(rate); err != nil {
+			return nil, fmt.Errorf("repos: rates: List: %w", err)
+		}
+	}
+	return result, rows.Err()
+}
+
+// Set - take rates and add or replace them
+// if replace is true, rates which are not in the list are removed
+func (r *ratesRepos) Set(ctx context.Context, rates models.ExchangeRates, replace bool) error {
+	now := time.Now()
+
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if replace {
+			currencies := make([]string, 0, len(rates))
+			for currency := range rates {
+				currencies = append(currencies, currency)
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM rates WHERE NOT (currency = ANY($1))`, pq.Array(currencies)); err != nil {
+				return err
+			}
+		}
+		for currency, rate := range rates {
+			_, err := tx.ExecContext(ctx, `INSERT INTO rates (currency, rate, updated) VALUES ($1, $2, $3)
+				ON CONFLICT (currency) DO UPDATE SET rate = excluded.rate, updated = excluded.updated`,
+				currency, rate.String(), now)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("repos: rates: Set: %w", err)
+	}
+	return nil
+}
+
+// newRatesRepos - return new ratesRepos
+func newRatesRepos(db *sql.DB, log *logrus.Entry) *ratesRepos {
+	return &ratesRepos{
+		db:  db,
+		log: log,
+	}
+}
@@ -3,12 +3,13 @@ package repository
 import (
 	"context"
 	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/mapping"
 	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
 	"time"
 )
 
@@ -18,132 +19,706 @@ const defaultPageSize = 100
 // productRepos - define all methods for communicating with MongoDB collection
 type productsRepos struct {
 	conn *mongo.Collection
+	// the same collection with read preference for List, it can read from secondaries
+	readConn *mongo.Collection
+	outbox   *outboxRepos
+	log      *logrus.Entry
 }
 
 // Get - takes a name and return the product with this name
 // or NotFoundProductError if product not found
 // or error if some go wrong
 func (p *productsRepos) Get(ctx context.Context, name string) (*models.Product, error) {
-	filter := bson.M{"name": name}
-	product := &models.Product{}
+	product, err := p.findOne(ctx, bson.M{"name": name})
+	if err != nil {
+		if err == models.NotFoundProductError {
+			return nil, err
+		}
+		return nil, fmt.Errorf("repos: Get: %w", err)
+	}
+	return product, nil
+}
+
+// GetById - takes an id and return the product with this id
+// or NotFoundProductError if product not found
+// or error if some go wrong
+func (p *productsRepos) GetById(ctx context.Context, id primitive.ObjectID) (*models.Product, error) {
+	product, err := p.findOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		if err == models.NotFoundProductError {
+			return nil, err
+		}
+		return nil, fmt.Errorf("repos: GetById: %w", err)
+	}
+	return product, nil
+}
+
+// GetMany - takes ids and names and return all found products without history,
+// missing products are skipped
+func (p *productsRepos) GetMany(ctx context.Context, ids []primitive.ObjectID, names []string) ([]*models.Product, error) {
+	if len(ids) == 0 && len(names) == 0 {
+		return nil, nil
+	}
+	// $in doesn't accept null, so nil slices replaced with empty
+	if ids == nil {
+		ids = []primitive.ObjectID{}
+	}
+	if names == nil {
+		names = []string{}
+	}
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"name": bson.M{"$in": names}},
+	}}
+	opts := options.Find().SetProjection(bson.M{"history": 0})
+
+	cur, err := p.conn.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
 
+	result, err := decodeProducts(ctx, cur)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findOne - take filter and return the first product which matches it
+func (p *productsRepos) findOne(ctx context.Context, filter bson.M) (*models.Product, error) {
 	result := p.conn.FindOne(ctx, filter)
 	if err := result.Err(); err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, models.NotFoundProductError
 		}
-		log.Println(err)
-		return nil, fmt.Errorf("repos: Get: finding product by name: %v", err)
+		return nil, fmt.Errorf("finding product: %w", err)
 	}
 
-	if err := result.Decode(&product); err != nil {
-		log.Println(err)
-		return nil, fmt.Errorf("repos: Get: decoding finded product: %v", err)
+	product, err := decodeProduct(result)
+	if err != nil {
+		return nil, err
 	}
 
 	return product, nil
 }
 
+// decodeProduct - decode product of FindOne or FindOneAndUpdate by mapping layer,
+// so all reads map fields of document in the same way
+// return mongo.ErrNoDocuments if product not found
+func decodeProduct(result *mongo.SingleResult) (*models.Product, error) {
+	doc, err := result.DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+	return mapping.ProductFromBSON(doc)
+}
+
+// decodeProducts - decode all products of cursor by mapping layer and close it
+func decodeProducts(ctx context.Context, cur *mongo.Cursor) ([]*models.Product, error) {
+	defer cur.Close(ctx)
+
+	var result []*models.Product
+	for cur.Next(ctx) {
+		product, err := mapping.ProductFromBSON(cur.Current)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, product)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // Create - take the product and insert it into collection
+// together with product.created event in outbox
+// return ProductExistsError if product with the same name exists
 func (p *productsRepos) Create(ctx context.Context, product *models.Product) error {
-	_, err := p.conn.InsertOne(ctx, &product)
+	product.Revision = 1
+
+	doc, err := mapping.ProductToBSON(product)
+	if err != nil {
+		return fmt.Errorf("repos: Create: %w", err)
+	}
+
+	err = p.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := p.conn.InsertOne(sc, doc); err != nil {
+			return err
+		}
+		return p.outbox.add(sc, models.EventProductCreated, product)
+	})
 	if err != nil {
-		log.Println(err)
-		return fmt.Errorf("repos: Create: %v", err)
+		// unique index of names
+		if mongo.IsDuplicateKeyError(err) {
+			return models.ProductExistsError
+		}
+		return fmt.Errorf("repos: Create: %w", err)
 	}
 	return nil
 }
 
-// UpdatePrice - take id and new price for product
-// increase price_update by 1
-// replace old price to new
+// UpdatePrice - take id and change of price for product from the source
+// if product has price from this source:
+// increase price_update of product and source by 1
+// replace old price of the source to new
 // update time when price changed
-func (p *productsRepos) UpdatePrice(ctx context.Context, id primitive.ObjectID, price float64) error {
+// if it is the first price from the source - add source to the product
+// in both cases price of product becomes the latest price,
+// change is added to the history of price
+// and product.price_updated event is written in outbox in the same transaction
+func (p *productsRepos) UpdatePrice(ctx context.Context, id primitive.ObjectID, change *models.PriceChange) error {
+	err := p.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		product, err := p.setPrice(sc, id, change)
+		if err != nil {
+			return err
+		}
+		return p.outbox.add(sc, models.EventProductPriceUpdated, product)
+	})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.NotFoundProductError
+		}
+		return fmt.Errorf("repos: UpdatePrice: %w", err)
+	}
+	return nil
+}
+
+// Update - take id and manual changes of product
+// change of price is applied like in UpdatePrice
+// return updated product, NotFoundProductError if product not found
+// or ProductExistsError if new name is taken
+func (p *productsRepos) Update(ctx context.Context, id primitive.ObjectID, upd *models.ProductUpdate) (*models.Product, error) {
+	// nothing to change
+	if upd.Name == nil && upd.Price == nil {
+		return p.GetById(ctx, id)
+	}
+
+	var product *models.Product
+
+	err := p.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+
+		if upd.Name != nil {
+			update := bson.M{
+				"$inc": bson.M{"revision": 1},
+				"$set": bson.M{"name": *upd.Name},
+			}
+			opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+			if product, err = decodeProduct(p.conn.FindOneAndUpdate(sc, bson.M{"_id": id}, update, opts)); err != nil {
+				return err
+			}
+		}
+		if upd.Price != nil {
+			if product, err = p.setPrice(sc, id, upd.Price); err != nil {
+				return err
+			}
+		}
+		return p.outbox.add(sc, models.EventProductUpdated, product)
+	})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, models.NotFoundProductError
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, models.ProductExistsError
+		}
+		return nil, fmt.Errorf("repos: Update: %w", err)
+	}
+	return product, nil
+}
+
+// setPrice - take id and change of price, must be called inside transaction
+// return product after change or mongo.ErrNoDocuments if product not found
+func (p *productsRepos) setPrice(sc mongo.SessionContext, id primitive.ObjectID, change *models.PriceChange) (*models.Product, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// price from known source
+	filter := bson.M{"_id": id, "sources.source": change.Source}
+	update := bson.M{
+		"$inc": bson.M{"price_updates": 1, "revision": 1, "sources.$.price_updates": 1},
+		"$set": bson.M{
+			"price":              change.Price,
+			"currency":           change.Currency,
+			"price_source":       change.Source,
+			"updated":            change.Changed,
+			"sources.$.price":    change.Price,
+			"sources.$.currency": change.Currency,
+			"sources.$.updated":  change.Changed,
+		},
+		"$push": bson.M{"history": pushChange(change)},
+	}
+
+	product, err := decodeProduct(p.conn.FindOneAndUpdate(sc, filter, update, opts))
+	if err != mongo.ErrNoDocuments {
+		return product, err
+	}
+
+	// the first price from the source
+	filter = bson.M{"_id": id}
+	update = bson.M{
+		"$inc": bson.M{"revision": 1},
+		"$set": bson.M{
+			"price":        change.Price,
+			"currency":     change.Currency,
+			"price_source": change.Source,
+			"updated":      change.Changed,
+		},
+		"$push": bson.M{
+			"history": pushChange(change),
+			"sources": models.NewSourcePrice(change),
+		},
+	}
+
+	return decodeProduct(p.conn.FindOneAndUpdate(sc, filter, update, opts))
+}
+
+// pushChange - return $push of change into history which keeps only the latest models.HistoryLimit changes
+func pushChange(change *models.PriceChange) bson.M {
+	return bson.M{"$each": bson.A{change}, "$slice": -models.HistoryLimit}
+}
+
+// Discontinue - take id and time, mark the product as discontinued (soft delete)
+// and write product.discontinued event in outbox in the same transaction
+// return NotFoundProductError if product not found
+func (p *productsRepos) Discontinue(ctx context.Context, id primitive.ObjectID, at time.Time) error {
 	filter := bson.M{"_id": id}
-	// update price, updated time and increase update price counter by 1
-	update := bson.D{
-		{"$inc", bson.D{{"price_updates", 1}}},
-		{"$set", bson.D{{"price", price}}},
-		{"$set", bson.D{{"updated", time.Now()}}},
+	update := bson.M{
+		"$inc": bson.M{"revision": 1},
+		"$set": bson.M{"discontinued": at},
 	}
 
-	res, err := p.conn.UpdateOne(ctx, filter, update)
+	_, err := p.findOneAndUpdate(ctx, filter, update, models.EventProductDiscontinued)
 	if err != nil {
-		log.Println(err)
-		return fmt.Errorf("repos: IncreasePriceUpdates: %v", err)
+		if err == models.NotFoundProductError {
+			return err
+		}
+		return fmt.Errorf("repos: Discontinue: %w", err)
+	}
+	return nil
+}
+
+// Restore - take id and source, make discontinued product and its price from the source active again,
+// if source is empty only product is restored
+// write product.restored event in outbox in the same transaction
+// return restored product or NotFoundProductError if product not found
+func (p *productsRepos) Restore(ctx context.Context, id primitive.ObjectID, source string) (*models.Product, error) {
+	filter := bson.M{"_id": id}
+	set := bson.M{"discontinued": nil}
+	update := bson.M{
+		"$inc": bson.M{"revision": 1},
+		"$set": set,
 	}
-	if res.ModifiedCount == 0 {
-		log.Println(err)
-		return fmt.Errorf("repos: IncreasePriceUpdates: nothing updated")
+	if source != "" {
+		filter["sources.source"] = source
+		set["sources.$.discontinued"] = nil
+	}
+
+	product, err := p.findOneAndUpdate(ctx, filter, update, models.EventProductRestored)
+	if err != nil {
+		if err == models.NotFoundProductError {
+			return nil, err
+		}
+		return nil, fmt.Errorf("repos: Restore: %w", err)
+	}
+	return product, nil
+}
+
+// MarkSeen - take source, ids of products from full snapshot of its feed and id of fetch job
+// stamp prices of the source of the products with the job, it isn't change of products,
+// so revision isn't incremented and events aren't written
+func (p *productsRepos) MarkSeen(ctx context.Context, source string, ids []primitive.ObjectID, job primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"s.source": source}},
+	})
+	_, err := p.conn.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "sources.source": source},
+		bson.M{"$set": bson.M{"sources.$[s].seen": job}}, opts)
+	if err != nil {
+		return fmt.Errorf("repos: MarkSeen: %w", err)
 	}
 	return nil
 }
 
-// List - take orderBy map, pageSize, pageNumber
+// DiscontinueMissing - take source, id of fetch job of full snapshot of its feed and time
+// mark active prices from this source which weren't stamped with the job by MarkSeen as discontinued
+// products without active sources become discontinued
+// write product.discontinued or product.updated events in outbox in the same transaction
+// return number of discontinued products
+func (p *productsRepos) DiscontinueMissing(ctx context.Context, source string, job primitive.ObjectID, at time.Time) (int64, error) {
+	filter := bson.M{
+		"sources": bson.M{"$elemMatch": bson.M{"source": source, "discontinued": nil, "seen": bson.M{"$ne": job}}},
+	}
+
+	var count int64
+
+	err := p.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		count = 0
+
+		ids, err := p.findIds(sc, filter)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		byIds := bson.M{"_id": bson.M{"$in": ids}}
+
+		// discontinue price of the source
+		update := bson.M{
+			"$inc": bson.M{"revision": 1},
+			"$set": bson.M{"sources.$[s].discontinued": at},
+		}
+		opts := options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"s.source": source}},
+		})
+		if _, err := p.conn.UpdateMany(sc, byIds, update, opts); err != nil {
+			return err
+		}
+
+		// discontinue products which don't have active sources
+		withoutSources := bson.M{
+			"_id":          bson.M{"$in": ids},
+			"discontinued": nil,
+			"sources":      bson.M{"$not": bson.M{"$elemMatch": bson.M{"discontinued": nil}}},
+		}
+		discontinuedIds, err := p.findIds(sc, withoutSources)
+		if err != nil {
+			return err
+		}
+		if len(discontinuedIds) > 0 {
+			byDiscontinuedIds := bson.M{"_id": bson.M{"$in": discontinuedIds}}
+			if _, err := p.conn.UpdateMany(sc, byDiscontinuedIds, bson.M{"$set": bson.M{"discontinued": at}}); err != nil {
+				return err
+			}
+		}
+		count = int64(len(discontinuedIds))
+
+		// read updated products for events
+		cur, err := p.conn.Find(sc, byIds, options.Find().SetProjection(bson.M{"history": 0}))
+		if err != nil {
+			return err
+		}
+		products, err := decodeProducts(sc, cur)
+		if err != nil {
+			return err
+		}
+
+		isDiscontinued := make(map[primitive.ObjectID]bool, len(discontinuedIds))
+		for _, id := range discontinuedIds {
+			isDiscontinued[id] = true
+		}
+		var discontinued, updated []*models.Product
+		for _, product := range products {
+			if isDiscontinued[product.Id] {
+				discontinued = append(discontinued, product)
+			} else {
+				updated = append(updated, product)
+			}
+		}
+
+		if len(discontinued) > 0 {
+			if err := p.outbox.addMany(sc, models.EventProductDiscontinued, discontinued); err != nil {
+				return err
+			}
+		}
+		if len(updated) > 0 {
+			return p.outbox.addMany(sc, models.EventProductUpdated, updated)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repos: DiscontinueMissing: %w", err)
+	}
+	return count, nil
+}
+
+// findIds - take filter and return ids of all products which match it
+func (p *productsRepos) findIds(ctx context.Context, filter bson.M) ([]primitive.ObjectID, error) {
+	cur, err := p.conn.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var found []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(found))
+	for _, f := range found {
+		ids = append(ids, f.Id)
+	}
+	return ids, nil
+}
+
+// findOneAndUpdate - take filter and update, apply update to the product
+// and write event with updated product in outbox in the same transaction
+// return updated product or NotFoundProductError if product not found
+func (p *productsRepos) findOneAndUpdate(ctx context.Context, filter, update bson.M, eventType string) (*models.Product, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var product *models.Product
+
+	err := p.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		if product, err = decodeProduct(p.conn.FindOneAndUpdate(sc, filter, update, opts)); err != nil {
+			return err
+		}
+		return p.outbox.add(sc, eventType, product)
+	})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, models.NotFoundProductError
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+// withTransaction - run fn inside MongoDB transaction,
+// so changes of the product and its outbox events are committed or aborted together
+func (p *productsRepos) withTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := p.conn.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// List - take options with orderBy map, pageSize, pageNumber, filters and price mode
 // ---
 // orderBy map represent all fields for ordering look like:
-// "order_by": {
-//    "price": 1
-//  }
+//
+//	"order_by": {
+//	   "price": 1
+//	 }
+//
 // Where "price" is name of field and "1" it is determines ascending(1)/descending(-1) sort
 // ---
 // pageSize is maximum products per one page
 // ---
 // pageNumber is current page and represented how many pages need to skip
 // ---
+// pageToken is the last product of previous page, it is used instead of pageNumber if it is set
+// ---
+// discontinued products are skipped if IncludeDiscontinued is false
+// ---
+// for lowest and source price modes price, currency, updated, price_updates and price_source are
+// replaced with values of chosen source, so ordering by them uses the chosen price
+// ---
+// if currency is set, price is converted into it and ordering by price uses converted value
+// ---
+// products are read with read preference and read concern of List from config,
+// so the list can lag behind the latest changes if secondaries are allowed
+// ---
 // Return list of product's pointers
 // or error if something went wrong
-func (p *productsRepos) List(ctx context.Context, orderBy map[string]int32, pageSize int32, pageNumber int32) ([]*models.Product, error) {
-	opts := &options.FindOptions{}
+func (p *productsRepos) List(ctx context.Context, listOpts *models.ListOptions) ([]*models.Product, error) {
+	pageSize, pageNumber := int64(listOpts.PageSize), int64(listOpts.PageNumber)
+
+	// if page size == 0 we have default value for it
+	limit := pageSize
+	if pageSize == 0 {
+		limit = defaultPageSize
+	}
 
 	// if page number == 0 we don't need to pass some products
-	if pageNumber == 0 {
-		opts.SetSkip(0)
-	} else {
-		opts.SetSkip(int64(pageSize) * int64(pageNumber))
+	var skip int64
+	if pageNumber != 0 {
+		skip = pageSize * pageNumber
 	}
 
-	// set up all order settings
-	for key, value := range orderBy {
-		opts.SetSort(bson.D{{key, value}})
+	// set up all order settings, products with equal values are ordered by id
+	field, desc, err := listOpts.Order()
+	if err != nil {
+		return nil, err
+	}
+	direction := 1
+	if desc {
+		direction = -1
+	}
+	sort := bson.D{{Key: "_id", Value: direction}}
+	if field != "" {
+		sort = append(bson.D{{Key: field, Value: direction}}, sort...)
 	}
 
-	// if page size == 0 we have default value for it
-	if pageSize == 0 {
-		opts.SetLimit(defaultPageSize)
+	// keyset pagination: products after the last product of previous page
+	var after bson.M
+	if token := listOpts.PageToken; token != nil {
+		skip = 0
+		after = afterToken(token)
+	}
+
+	filter := bson.M{}
+	if !listOpts.IncludeDiscontinued {
+		filter["discontinued"] = nil
+	}
+	if listOpts.PriceMode == models.PriceModeSource {
+		filter["sources.source"] = listOpts.Source
+	}
+
+	// history of price is returned only for one product
+	projection := bson.M{"history": 0}
+
+	var cur *mongo.Cursor
+
+	if listOpts.PriceMode == models.PriceModeLatest && listOpts.Currency == "" {
+		if after != nil {
+			filter = bson.M{"$and": bson.A{filter, after}}
+		}
+		opts := options.Find().
+			SetSkip(skip).
+			SetLimit(limit).
+			SetProjection(projection).
+			SetSort(sort)
+
+		cur, err = p.readConn.Find(ctx, filter, opts)
 	} else {
-		opts.SetLimit(int64(pageSize))
+		pipeline := bson.A{bson.M{"$match": filter}}
+		pipeline = append(pipeline, priceStages(listOpts)...)
+		if after != nil {
+			pipeline = append(pipeline, bson.M{"$match": after})
+		}
+		pipeline = append(pipeline, bson.M{"$sort": sort}, bson.M{"$skip": skip}, bson.M{"$limit": limit})
+
+		cur, err = p.readConn.Aggregate(ctx, pipeline)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	cur, err := p.conn.Find(ctx, bson.D{}, opts)
+	result, err := decodeProducts(ctx, cur)
 	if err != nil {
-		log.Println("repos: List: error while finding:", err)
 		return nil, err
 	}
+	return result, nil
+}
 
-	var result []*models.Product
+// afterToken - take page token and return filter of products which are after it in the order of list
+func afterToken(token *models.PageToken) bson.M {
+	op := "$gt"
+	if token.Desc {
+		op = "$lt"
+	}
+	if token.Field == "" {
+		return bson.M{"_id": bson.M{op: token.Id}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{token.Field: bson.M{op: token.Value}},
+		bson.M{token.Field: token.Value, "_id": bson.M{op: token.Id}},
+	}}
+}
 
-	for cur.Next(ctx) {
-		p := &models.Product{}
+// priceStages - return aggregation stages which choose source of price for the price mode,
+// replace price fields of product with fields of this source
+// and convert price into currency of the list
+// products without sources keep their own price
+func priceStages(listOpts *models.ListOptions) bson.A {
+	sources := bson.M{"$ifNull": bson.A{"$sources", bson.A{}}}
+	first := func(input interface{}, cond bson.M) bson.M {
+		return bson.M{"$arrayElemAt": bson.A{
+			bson.M{"$filter": bson.M{"input": input, "as": "s", "cond": cond}},
+			0,
+		}}
+	}
 
-		err := cur.Decode(p)
-		if err != nil {
-			log.Println("repos: List: error while decoding:", err)
-			return nil, err
+	var stages bson.A
+
+	switch listOpts.PriceMode {
+	case models.PriceModeLowest:
+		// prices of sources are compared in currency of the list
+		// or in base currency of rates if currency isn't set
+		compared := convertExpr("$$s.price", "$$s.currency", listOpts.Rates, listOpts.Currency)
+		if listOpts.Currency == "" {
+			compared = bson.M{"$ifNull": bson.A{compared, "$$s.price"}}
 		}
-		result = append(result, p)
+
+		active := bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$$s.discontinued", nil}}, nil}}
+		stages = append(stages,
+			bson.M{"$addFields": bson.M{"_active": bson.M{"$filter": bson.M{"input": sources, "as": "s", "cond": active}}}},
+			bson.M{"$addFields": bson.M{"_compared": bson.M{"$map": bson.M{"input": "$_active", "as": "s", "in": bson.M{
+				"s": "$$s",
+				"v": compared,
+			}}}}},
+			bson.M{"$addFields": bson.M{"_lowest": first("$_compared", bson.M{"$eq": bson.A{"$$s.v", bson.M{"$min": "$_compared.v"}}})}},
+			bson.M{"$addFields": bson.M{"_src": "$_lowest.s"}},
+		)
+	case models.PriceModeSource:
+		stages = append(stages,
+			bson.M{"$addFields": bson.M{"_src": first(sources, bson.M{"$eq": bson.A{"$$s.source", listOpts.Source}})}},
+		)
 	}
 
-	if err := cur.Err(); err != nil {
-		log.Println("repos: List: error while get Err from cursor:", err)
-		return nil, err
+	if listOpts.PriceMode != models.PriceModeLatest {
+		stages = append(stages, bson.M{"$addFields": bson.M{
+			"price":         bson.M{"$ifNull": bson.A{"$_src.price", "$price"}},
+			"currency":      bson.M{"$ifNull": bson.A{"$_src.currency", "$currency"}},
+			"price_source":  bson.M{"$ifNull": bson.A{"$_src.source", "$price_source"}},
+			"updated":       bson.M{"$ifNull": bson.A{"$_src.updated", "$updated"}},
+			"price_updates": bson.M{"$ifNull": bson.A{"$_src.price_updates", "$price_updates"}},
+		}})
 	}
-	return result, nil
+
+	if listOpts.Currency != "" {
+		stages = append(stages,
+			bson.M{"$addFields": bson.M{
+				"price":    convertExpr("$price", "$currency", listOpts.Rates, listOpts.Currency),
+				"currency": listOpts.Currency,
+			}},
+			// there is no rate for currency of product
+			bson.M{"$match": bson.M{"price": bson.M{"$ne": nil}}},
+		)
+	}
+
+	return append(stages,
+		bson.M{"$project": bson.M{"history": 0, "_src": 0, "_active": 0, "_compared": 0, "_lowest": 0}},
+	)
+}
+
+// convertExpr - take expressions with price and its currency, rates and currency to convert into
+// return expression with converted price, it is null if there is no rate for currency
+// if to is empty, price is converted into base currency of rates
+// rates are saved as Decimal128, so prices are converted exactly up to 34 digits and rounded to 9 fractional digits
+// like decoded prices, so sort and page tokens compare the same values
+func convertExpr(price, currency interface{}, rates models.ExchangeRates, to string) bson.M {
+	var rate interface{} = bson.M{"$literal": nil}
+
+	var branches bson.A
+	for from, value := range rates {
+		branches = append(branches, bson.M{
+			"case": bson.M{"$eq": bson.A{currency, from}},
+			"then": value,
+		})
+	}
+	if len(branches) != 0 {
+		rate = bson.M{"$switch": bson.M{"branches": branches, "default": nil}}
+	}
+
+	inBase := bson.M{"$multiply": bson.A{price, rate}}
+	if to == "" {
+		return bson.M{"$round": bson.A{inBase, models.DecimalDigits}}
+	}
+
+	var converted interface{}
+	if rateTo, ok := rates[to]; ok && rateTo.Sign() != 0 {
+		converted = bson.M{"$round": bson.A{bson.M{"$divide": bson.A{inBase, rateTo}}, models.DecimalDigits}}
+	}
+	return bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{currency, to}}, price, converted}}
 }
 
 // newProductsRepos - return new productsRepos
-func newProductsRepos(conn *mongo.Collection) *productsRepos {
+func newProductsRepos(conn, readConn *mongo.Collection, outbox *outboxRepos, log *logrus.Entry) *productsRepos {
 	return &productsRepos{
-		conn: conn,
+		conn:     conn,
+		readConn: readConn,
+		outbox:   outbox,
+		log:      log,
 	}
 }
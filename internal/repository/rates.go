@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// rate - document of rates collection, id is ISO 4217 code of currency
+type rate struct {
+	Currency string         `bson:"_id"`
+	Rate     models.Decimal `bson:"rate"`
+	Updated  time.Time      `bson:"updated"`
+}
+
+// ratesRepos - exchange rates which are maintained by rpc and saved in MongoDB collection
+type ratesRepos struct {
+	conn *mongo.Collection
+	log  *logrus.Entry
+}
+
+// List - return all exchange rates
+func (r *ratesRepos) List(ctx context.Context) (models.ExchangeRates, error) {
+	cur, err := r.conn.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*rate
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	result := make(models.ExchangeRates, len(found))
+	for _, f := range found {
+		result[f.Currency] = f.Rate
+	}
+	return result, nil
+}
+
+// Set - take rates and add or replace them in collection
+// if replace is true, rates which are not in the list are removed
+func (r *ratesRepos) Set(ctx context.Context, rates models.ExchangeRates, replace bool) error {
+	now := time.Now()
+
+	writes := make([]mongo.WriteModel, 0, len(rates)+1)
+	if replace {
+		currencies := make([]string, 0, len(rates))
+		for currency := range rates {
+			currencies = append(currencies, currency)
+		}
+		writes = append(writes, mongo.NewDeleteManyModel().
+			SetFilter(bson.M{"_id": bson.M{"$nin": currencies}}))
+	}
+	for currency, value := range rates {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": currency}).
+			SetReplacement(&rate{Currency: currency, Rate: value, Updated: now}).
+			SetUpsert(true))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	if _, err := r.conn.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(true)); err != nil {
+		return fmt.Errorf("repos: rates: Set: %w", err)
+	}
+	return nil
+}
+
+// newRatesRepos - return new ratesRepos
+func newRatesRepos(conn *mongo.Collection, log *logrus.Entry) *ratesRepos {
+	return &ratesRepos{
+		conn: conn,
+		log:  log,
+	}
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileRatesRepos - exchange rates which are loaded from local JSON file
+// file is read again when it was modified
+type fileRatesRepos struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	rates   models.ExchangeRates
+}
+
+// List - return rates from the file
+func (r *fileRatesRepos) List(ctx context.Context) (models.ExchangeRates, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("repos: rates file: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rates != nil && info.ModTime().Equal(r.modTime) {
+		return r.rates, nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("repos: rates file: %w", err)
+	}
+
+	rates := models.ExchangeRates{}
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("repos: rates file: decoding %s: %w", r.path, err)
+	}
+
+	r.rates = rates
+	r.modTime = info.ModTime()
+	return rates, nil
+}
+
+// Set - rates from the file can't be changed
+func (r *fileRatesRepos) Set(ctx context.Context, rates models.ExchangeRates, replace bool) error {
+	return models.ReadOnlyRatesError
+}
+
+// newFileRatesRepos - return new fileRatesRepos
+func newFileRatesRepos(path string) *fileRatesRepos {
+	return &fileRatesRepos{
+		path: path,
+	}
+}
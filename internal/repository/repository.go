@@ -2,24 +2,146 @@ package repository
 
 import (
 	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/database"
+	"github.com/ArturChopikian/grpc-server/database/migrations"
 	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
 )
 
 type ProductsReposInterface interface {
 	Get(ctx context.Context, name string) (*models.Product, error)
+	GetById(ctx context.Context, id primitive.ObjectID) (*models.Product, error)
+	GetMany(ctx context.Context, ids []primitive.ObjectID, names []string) ([]*models.Product, error)
 	Create(ctx context.Context, product *models.Product) error
-	UpdatePrice(ctx context.Context, id primitive.ObjectID, price float64) error
-	List(ctx context.Context, orderBy map[string]int32, pageSize int32, pageNumber int32) ([]*models.Product, error)
+	UpdatePrice(ctx context.Context, id primitive.ObjectID, change *models.PriceChange) error
+	Update(ctx context.Context, id primitive.ObjectID, upd *models.ProductUpdate) (*models.Product, error)
+	Discontinue(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	Restore(ctx context.Context, id primitive.ObjectID, source string) (*models.Product, error)
+	MarkSeen(ctx context.Context, source string, ids []primitive.ObjectID, job primitive.ObjectID) error
+	DiscontinueMissing(ctx context.Context, source string, job primitive.ObjectID, at time.Time) (int64, error)
+	List(ctx context.Context, opts *models.ListOptions) ([]*models.Product, error)
 }
 
+type OutboxReposInterface interface {
+	Pending(ctx context.Context, limit int64) ([]*models.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id primitive.ObjectID) error
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type RatesReposInterface interface {
+	List(ctx context.Context) (models.ExchangeRates, error)
+	Set(ctx context.Context, rates models.ExchangeRates, replace bool) error
+}
+
+type FetchJobsReposInterface interface {
+	Get(ctx context.Context, id primitive.ObjectID) (*models.FetchJob, error)
+	Save(ctx context.Context, job *models.FetchJob) error
+}
+
+// Repository - repositories of one storage,
+// Closer releases resources of the storage, Pinger checks that the storage is available,
+// both can be nil
 type Repository struct {
 	Products ProductsReposInterface
+	Outbox   OutboxReposInterface
+	Rates    RatesReposInterface
+	Jobs     FetchJobsReposInterface
+	Closer   func() error
+	Pinger   func(ctx context.Context) error
 }
 
-func NewRepository(coll *mongo.Collection) *Repository {
-	return &Repository{
-		Products: newProductsRepos(coll),
+// Close - release resources of the storage
+func (r *Repository) Close() error {
+	if r.Closer == nil {
+		return nil
+	}
+	return r.Closer()
+}
+
+// Ping - check that the storage is available
+func (r *Repository) Ping(ctx context.Context) error {
+	if r.Pinger == nil {
+		return nil
+	}
+	return r.Pinger(ctx)
+}
+
+func init() {
+	Register("mongodb", openMongoDB)
+}
+
+// time for applying migrations on start, backfills of big collections can be slow
+const migrateTimeout = 10 * time.Minute
+
+// openMongoDB - connect to MongoDB, apply or check migrations and return repositories on its collections
+func openMongoDB(cfg *configs.Config, log *logrus.Entry) (*Repository, error) {
+	coll, err := database.NewMongoDBCollection(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(coll.Database(), cfg, log); err != nil {
+		_ = coll.Database().Client().Disconnect(context.Background())
+		return nil, err
 	}
+
+	repos, err := NewRepository(coll, cfg, log)
+	if err != nil {
+		_ = coll.Database().Client().Disconnect(context.Background())
+		return nil, err
+	}
+	repos.Closer = func() error {
+		return coll.Database().Client().Disconnect(context.Background())
+	}
+	repos.Pinger = func(ctx context.Context) error {
+		return coll.Database().Client().Ping(ctx, readpref.Primary())
+	}
+	return repos, nil
+}
+
+// migrate - apply migrations if it is enabled in config,
+// otherwise return error if schema is newer than the server and warn if it is older
+func migrate(db *mongo.Database, cfg *configs.Config, log *logrus.Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	runner := migrations.NewRunner(db, cfg, log)
+	if cfg.MongoDB.Migrate {
+		return runner.Up(ctx)
+	}
+
+	if err := runner.Check(ctx); err != nil {
+		return err
+	}
+	current, latest, err := runner.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if current < latest {
+		log.Warnf("repos: schema version %d is older than %d, run migrations", current, latest)
+	}
+	return nil
+}
+
+// NewRepository - return repositories on MongoDB collection of products,
+// outbox, rates and jobs collections are in the same database
+// List of products reads with read preference and read concern from config
+func NewRepository(coll *mongo.Collection, cfg *configs.Config, log *logrus.Entry) (*Repository, error) {
+	readConn, err := database.NewMongoDBReadCollection(coll, cfg)
+	if err != nil {
+		return nil, err
+	}
+	outbox := newOutboxRepos(coll.Database().Collection(cfg.MongoDB.OutboxCollection), log)
+
+	return &Repository{
+		Products: newProductsRepos(coll, readConn, outbox, log),
+		Outbox:   outbox,
+		Rates:    newRatesRepos(coll.Database().Collection(cfg.MongoDB.RatesCollection), log),
+		Jobs:     newJobsRepos(coll.Database().Collection(cfg.MongoDB.JobsCollection), log),
+	}, nil
 }
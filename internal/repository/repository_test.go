@@ -0,0 +1,55 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/database"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/ArturChopikian/grpc-server/internal/repository/conformance"
+	"github.com/sirupsen/logrus"
+	"os"
+	"testing"
+	"time"
+)
+
+// URI of MongoDB for tests, each run uses new database which is dropped after it
+const testURIEnv = "MONGODB_TEST_URI"
+
+func TestConformance(t *testing.T) {
+	uri := os.Getenv(testURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", testURIEnv)
+	}
+
+	conformance.Test(t, func(t *testing.T) *repository.Repository {
+		cfg, err := configs.NewConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Storage.Driver = "mongodb"
+		cfg.MongoDB.URI = uri
+		cfg.MongoDB.Database = fmt.Sprintf("conformance_%d", time.Now().UnixNano())
+		cfg.MongoDB.Collection = "products"
+		log := logrus.NewEntry(logrus.New())
+
+		repos, err := repository.Open(cfg, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = repos.Close()
+
+			client, err := database.NewMongoDBClient(cfg, log)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer client.Disconnect(context.Background())
+			if err := client.Database(cfg.MongoDB.Database).Drop(context.Background()); err != nil {
+				t.Error(err)
+			}
+		})
+		return repos
+	})
+}
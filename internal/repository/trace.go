@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/tracing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// traceRepos - wrap repositories of storage opened by driver, so every operation has its span
+func traceRepos(repos *Repository, driver string) {
+	if repos.Products != nil {
+		repos.Products = &tracedProducts{next: repos.Products, driver: driver}
+	}
+	if repos.Outbox != nil {
+		repos.Outbox = &tracedOutbox{next: repos.Outbox, driver: driver}
+	}
+	if repos.Rates != nil {
+		repos.Rates = &tracedRates{next: repos.Rates, driver: driver}
+	}
+	if repos.Jobs != nil {
+		repos.Jobs = &tracedJobs{next: repos.Jobs, driver: driver}
+	}
+}
+
+// startSpan - start span of operation of repository
+// return ctx with the span and function which ends it,
+// error is recorded in the span if it is not expected result of operation like not found product
+func startSpan(ctx context.Context, driver, repos, operation string) (context.Context, func(err error)) {
+	// operations outside of traced calls, like polls of outbox, don't start their own traces,
+	// span from ctx without trace does nothing
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		ctx, span = tracing.Tracer().Start(ctx, "repository."+repos+"."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.system", driver)),
+		)
+	}
+
+	return ctx, func(err error) {
+		if err != nil && !expectedError(err) {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// tracedProducts - products repository which records spans of its operations
+type tracedProducts struct {
+	next   ProductsReposInterface
+	driver string
+}
+
+func (r *tracedProducts) Get(ctx context.Context, name string) (p *models.Product, err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "get")
+	defer func() { end(err) }()
+	return r.next.Get(ctx, name)
+}
+
+func (r *tracedProducts) GetById(ctx context.Context, id primitive.ObjectID) (p *models.Product, err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "get_by_id")
+	defer func() { end(err) }()
+	return r.next.GetById(ctx, id)
+}
+
+func (r *tracedProducts) GetMany(ctx context.Context, ids []primitive.ObjectID, names []string) (ps []*models.Product, err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "get_many")
+	defer func() { end(err) }()
+	return r.next.GetMany(ctx, ids, names)
+}
+
+func (r *tracedProducts) Create(ctx context.Context, product *models.Product) (err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, product)
+}
+
+func (r *tracedProducts) UpdatePrice(ctx context.Context, id primitive.ObjectID, change *models.PriceChange) (err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "update_price")
+	defer func() { end(err) }()
+	return r.next.UpdatePrice(ctx, id, change)
+}
+
+func (r *tracedProducts) Update(ctx context.Context, id primitive.ObjectID, upd *models.ProductUpdate) (p *models.Product, err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "update")
+	defer func() { end(err) }()
+	return r.next.Update(ctx, id, upd)
+}
+
+func (r *tracedProducts) Discontinue(ctx context.Context, id primitive.ObjectID, at time.Time) (err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "discontinue")
+	defer func() { end(err) }()
+	return r.next.Discontinue(ctx, id, at)
+}
+
+func (r *tracedProducts) Restore(ctx context.Context, id primitive.ObjectID, source string) (p *models.Product, err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "restore")
+	defer func() { end(err) }()
+	return r.next.Restore(ctx, id, source)
+}
+
+func (r *tracedProducts) MarkSeen(ctx context.Context, source string, ids []primitive.ObjectID, job primitive.ObjectID) (err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "mark_seen")
+	defer func() { end(err) }()
+	return r.next.MarkSeen(ctx, source, ids, job)
+}
+
+func (r *tracedProducts) DiscontinueMissing(ctx context.Context, source string, job primitive.ObjectID, at time.Time) (n int64, err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "discontinue_missing")
+	defer func() { end(err) }()
+	return r.next.DiscontinueMissing(ctx, source, job, at)
+}
+
+func (r *tracedProducts) List(ctx context.Context, opts *models.ListOptions) (ps []*models.Product, err error) {
+	ctx, end := startSpan(ctx, r.driver, "products", "list")
+	defer func() { end(err) }()
+	return r.next.List(ctx, opts)
+}
+
+// tracedOutbox - outbox repository which records spans of its operations
+type tracedOutbox struct {
+	next   OutboxReposInterface
+	driver string
+}
+
+func (r *tracedOutbox) Pending(ctx context.Context, limit int64) (events []*models.OutboxEvent, err error) {
+	ctx, end := startSpan(ctx, r.driver, "outbox", "pending")
+	defer func() { end(err) }()
+	return r.next.Pending(ctx, limit)
+}
+
+func (r *tracedOutbox) MarkPublished(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := startSpan(ctx, r.driver, "outbox", "mark_published")
+	defer func() { end(err) }()
+	return r.next.MarkPublished(ctx, id)
+}
+
+func (r *tracedOutbox) DeletePublished(ctx context.Context, before time.Time) (deleted int64, err error) {
+	ctx, end := startSpan(ctx, r.driver, "outbox", "delete_published")
+	defer func() { end(err) }()
+	return r.next.DeletePublished(ctx, before)
+}
+
+// tracedRates - rates repository which records spans of its operations
+type tracedRates struct {
+	next   RatesReposInterface
+	driver string
+}
+
+func (r *tracedRates) List(ctx context.Context) (rates models.ExchangeRates, err error) {
+	ctx, end := startSpan(ctx, r.driver, "rates", "list")
+	defer func() { end(err) }()
+	return r.next.List(ctx)
+}
+
+func (r *tracedRates) Set(ctx context.Context, rates models.ExchangeRates, replace bool) (err error) {
+	ctx, end := startSpan(ctx, r.driver, "rates", "set")
+	defer func() { end(err) }()
+	return r.next.Set(ctx, rates, replace)
+}
+
+// tracedJobs - fetch jobs repository which records spans of its operations
+type tracedJobs struct {
+	next   FetchJobsReposInterface
+	driver string
+}
+
+func (r *tracedJobs) Get(ctx context.Context, id primitive.ObjectID) (job *models.FetchJob, err error) {
+	ctx, end := startSpan(ctx, r.driver, "jobs", "get")
+	defer func() { end(err) }()
+	return r.next.Get(ctx, id)
+}
+
+func (r *tracedJobs) Save(ctx context.Context, job *models.FetchJob) (err error) {
+	ctx, end := startSpan(ctx, r.driver, "jobs", "save")
+	defer func() { end(err) }()
+	return r.next.Save(ctx, job)
+}
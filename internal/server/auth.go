@@ -0,0 +1,33 @@
+package server
+
+import (
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/auth"
+	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+)
+
+var (
+	// readMethods - methods of ProductsService which only read products and rates
+	readMethods = []string{"List", "StreamProducts", "GetProduct", "BatchGetProducts", "GetPriceHistory", "ListExchangeRates"}
+	// ingestMethods - methods of ProductsService which change products and rates
+	ingestMethods = []string{"Fetch", "CreateProduct", "UpdateProduct", "DeleteProduct", "RestoreProduct", "SetExchangeRates"}
+)
+
+// authPolicy - return policy where reader roles call read methods and ingest roles call all methods of ProductsService
+func authPolicy(cfg *configs.AuthConfig) *auth.Policy {
+	service := pb.ProductsService_ServiceDesc.ServiceName
+	policy := &auth.Policy{
+		Services:  []string{service},
+		Roles:     make(map[string][]string),
+		Anonymous: make(map[string]bool),
+	}
+	for _, method := range readMethods {
+		fullMethod := "/" + service + "/" + method
+		policy.Roles[fullMethod] = cfg.ReaderRoles
+		policy.Anonymous[fullMethod] = cfg.AnonymousRead
+	}
+	for _, method := range ingestMethods {
+		policy.Roles["/"+service+"/"+method] = cfg.IngestRoles
+	}
+	return policy
+}
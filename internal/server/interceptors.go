@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path"
+	"runtime/debug"
+	"time"
+)
+
+// recoveryUnary - return interceptor which turns panic of handler into Internal error,
+// panic is logged with stack, so the process keeps serving other calls
+func recoveryUnary(log *logrus.Entry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, log, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// recoveryStream - the same as recoveryUnary for streaming calls
+func recoveryStream(log *logrus.Entry) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), log, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered - log recovered panic and return error for caller, details of panic are not sent to it
+func recovered(ctx context.Context, log *logrus.Entry, method string, r interface{}) error {
+	log.WithContext(ctx).WithFields(logrus.Fields{
+		"method": method,
+		"panic":  r,
+		"stack":  string(debug.Stack()),
+	}).Error("server: handler panicked")
+	return status.Error(codes.Internal, "internal error")
+}
+
+// deadlines - default and maximum deadlines of calls by method
+type deadlines struct {
+	defaultTimeout time.Duration
+	maxTimeout     time.Duration
+	methods        map[string]int
+	maxMethods     map[string]int
+}
+
+// newDeadlines - take timeouts from config
+func newDeadlines(cfg *configs.ServerConfig) *deadlines {
+	return &deadlines{
+		defaultTimeout: time.Duration(cfg.DefaultTimeout) * time.Second,
+		maxTimeout:     time.Duration(cfg.MaxTimeout) * time.Second,
+		methods:        cfg.MethodTimeouts,
+		maxMethods:     cfg.MethodMaxTimeouts,
+	}
+}
+
+// timeouts - return default and maximum timeout of method,
+// it can be set for full name of method like /products.ProductsService/Fetch or only for its name
+func (d *deadlines) timeouts(fullMethod string) (timeout, limit time.Duration) {
+	timeout, limit = d.defaultTimeout, d.maxTimeout
+	if seconds, ok := lookupMethod(d.methods, fullMethod); ok {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if seconds, ok := lookupMethod(d.maxMethods, fullMethod); ok {
+		limit = time.Duration(seconds) * time.Second
+	}
+	return timeout, limit
+}
+
+// apply - return ctx with default deadline if caller didn't set it
+// or with maximum deadline if deadline of caller is later, zero timeout means no deadline
+func (d *deadlines) apply(ctx context.Context, fullMethod string) (context.Context, context.CancelFunc) {
+	timeout, limit := d.timeouts(fullMethod)
+
+	deadline, ok := ctx.Deadline()
+	switch {
+	case !ok && timeout > 0:
+		return context.WithTimeout(ctx, timeout)
+	case ok && limit > 0 && time.Until(deadline) > limit:
+		return context.WithTimeout(ctx, limit)
+	}
+	return ctx, func() {}
+}
+
+func (d *deadlines) unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := d.apply(ctx, info.FullMethod)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+func (d *deadlines) stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := d.apply(ss.Context(), info.FullMethod)
+		defer cancel()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// lookupMethod - find value for full name of method or for its name
+func lookupMethod(values map[string]int, fullMethod string) (int, bool) {
+	if v, ok := values[fullMethod]; ok {
+		return v, true
+	}
+	v, ok := values[path.Base(fullMethod)]
+	return v, ok
+}
+
+// validationUnary - return interceptor which rejects invalid requests before they reach handler,
+// validate must return InvalidArgument error for them
+func validationUnary(validate func(req interface{}) error) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validate(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// validationStream - the same as validationUnary for every received message of streaming calls
+func validationStream(validate func(req interface{}) error) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ss.Context(), validate: validate})
+	}
+}
+
+// serverStream - stream of call with its own ctx, received messages are validated if validate is set
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	validate func(req interface{}) error
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if s.validate != nil {
+		return s.validate(m)
+	}
+	return nil
+}
+
+// chainUnary - return interceptor which calls interceptors in order like grpc.ChainUnaryInterceptor,
+// it is used for calls which don't come through gRPC server
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, h := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, h)
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/auth"
+	"github.com/ArturChopikian/grpc-server/internal/metrics"
+	"github.com/ArturChopikian/grpc-server/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"net"
+	"path"
+)
+
+// rateLimits - limiters of methods of service, every caller has its own bucket in limiter of each method
+type rateLimits struct {
+	limiters map[string]*ratelimit.Limiter
+}
+
+// newRateLimits - take rates and bursts from config for every method of service
+func newRateLimits(cfg *configs.LimitsConfig, service grpc.ServiceDesc) *rateLimits {
+	var methods []string
+	for _, m := range service.Methods {
+		methods = append(methods, m.MethodName)
+	}
+	for _, s := range service.Streams {
+		methods = append(methods, s.StreamName)
+	}
+
+	limits := &rateLimits{limiters: make(map[string]*ratelimit.Limiter, len(methods))}
+	for _, method := range methods {
+		fullMethod := "/" + service.ServiceName + "/" + method
+		rate, burst := cfg.Rate, cfg.Burst
+		if v, ok := lookupMethod(cfg.MethodRates, fullMethod); ok {
+			rate = v
+		}
+		if v, ok := lookupMethod(cfg.MethodBursts, fullMethod); ok {
+			burst = v
+		}
+		if limiter := ratelimit.NewLimiter(rate, burst); limiter != nil {
+			limits.limiters[fullMethod] = limiter
+		}
+	}
+	return limits
+}
+
+// check - return ResourceExhausted error with time after which caller can retry
+// if caller exceeded rate of method, methods of other services aren't limited
+func (r *rateLimits) check(ctx context.Context, fullMethod string) error {
+	limiter, ok := r.limiters[fullMethod]
+	if !ok {
+		return nil
+	}
+	if delay := limiter.Allow(caller(ctx)); delay > 0 {
+		metrics.RateLimited.WithLabelValues(path.Base(fullMethod)).Inc()
+		return ratelimit.ExhaustedError(fmt.Sprintf("rate limit of %s is exceeded", path.Base(fullMethod)), delay)
+	}
+	return nil
+}
+
+func (r *rateLimits) unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := r.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (r *rateLimits) stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := r.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// caller - return key of caller: authenticated principal or IP address of anonymous caller
+func caller(ctx context.Context) string {
+	if p := auth.FromContext(ctx); p.Name != auth.Anonymous {
+		return "principal:" + p.Name
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return auth.Anonymous
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "address:" + p.Addr.String()
+	}
+	return "address:" + host
+}
@@ -1,28 +1,61 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/auth"
 	grpc_handler "github.com/ArturChopikian/grpc-server/internal/delivery/grpc"
 	"github.com/ArturChopikian/grpc-server/internal/delivery/grpc/pb"
+	"github.com/ArturChopikian/grpc-server/internal/gateway"
+	"github.com/ArturChopikian/grpc-server/internal/health"
+	"github.com/ArturChopikian/grpc-server/internal/logging"
 	"github.com/ArturChopikian/grpc-server/internal/repository"
 	"github.com/ArturChopikian/grpc-server/internal/usecase"
-	"go.mongodb.org/mongo-driver/mongo"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
-	"log"
 	"net"
+	"net/http"
+	"sync"
+	"time"
 )
 
+// time for running fetches to save their jobs after they are interrupted
+const interruptTimeout = 10 * time.Second
+
 type ProductServers struct {
-	handler   grpc_handler.ProductsHandlerInterface
-	cfg       *configs.Config
-	mongoColl *mongo.Collection
-	server    *grpc.Server
-	lis       net.Listener
+	handler  grpc_handler.ProductsHandlerInterface
+	cfg      *configs.Config
+	repos    *repository.Repository
+	useCases *usecase.UseCases
+	server   *grpc.Server
+	lis      net.Listener
+	log      *logrus.Entry
+
+	// chain of unary interceptors of server, calls of gateway pass it too
+	unary grpc.UnaryServerInterceptor
+	// HTTP/JSON gateway and gRPC-Web, nil if gateway is disabled
+	gateway    *http.Server
+	gatewayLis net.Listener
 }
 
-func NewProductsServer(cfg *configs.Config, coll *mongo.Collection) (*ProductServers, error) {
+func NewProductsServer(cfg *configs.Config, repos *repository.Repository, log *logrus.Entry) (*ProductServers, error) {
+
+	unary, stream, err := interceptors(cfg, log)
+	if err != nil {
+		return &ProductServers{}, err
+	}
+
+	server, err := newGRPCServer(cfg, log, unary, stream)
+	if err != nil {
+		return &ProductServers{}, err
+	}
 
 	address := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 
@@ -31,31 +64,218 @@ func NewProductsServer(cfg *configs.Config, coll *mongo.Collection) (*ProductSer
 		return &ProductServers{}, err
 	}
 
+	gatewayLis, err := gatewayListener(cfg, log)
+	if err != nil {
+		_ = lis.Close()
+		return &ProductServers{}, err
+	}
+
 	return &ProductServers{
-		cfg:       cfg,
-		mongoColl: coll,
-		server:    grpc.NewServer(),
-		lis:       lis,
+		cfg:        cfg,
+		repos:      repos,
+		server:     server,
+		lis:        lis,
+		log:        log,
+		unary:      chainUnary(unary),
+		gatewayLis: gatewayLis,
 	}, nil
 }
 
 func (ps *ProductServers) MapHandler() {
-	repositories := repository.NewRepository(ps.mongoColl)
-	useCases := usecase.NewUseCases(repositories)
-	handlers := grpc_handler.NewProductsHandler(useCases, ps.cfg)
+	ps.useCases = usecase.NewUseCases(ps.repos, ps.cfg, ps.log)
+	handlers := grpc_handler.NewProductsHandler(ps.useCases, ps.cfg, ps.log)
 
 	pb.RegisterProductsServiceServer(ps.server, handlers)
 	reflection.Register(ps.server)
+
+	if ps.gatewayLis != nil {
+		gw := gateway.New(handlers, ps.unary, ps.cfg.Server.MaxRecvMsgSize, ps.log)
+		ps.gateway = &http.Server{Handler: webHandler(&ps.cfg.Gateway, ps.server, gw.Handler())}
+	}
+}
+
+// interceptors - return chains of unary and streaming interceptors:
+// every call is recorded as span, trace context of caller is continued,
+// every call is logged with its request id, which is also added to all lines logged with ctx of the call,
+// latency and status codes of calls are recorded in metrics,
+// panics of handlers become Internal errors, callers are authenticated and authorized if it is enabled,
+// callers which exceed rate limits of methods are rejected,
+// calls get default or maximum deadline of their method and invalid requests are rejected before handlers
+func interceptors(cfg *configs.Config, log *logrus.Entry) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor, error) {
+	grpc_prometheus.EnableHandlingTimeHistogram()
+	deadlines := newDeadlines(&cfg.Server)
+	rateLimits := newRateLimits(&cfg.Limits, pb.ProductsService_ServiceDesc)
+
+	unary := []grpc.UnaryServerInterceptor{
+		otelgrpc.UnaryServerInterceptor(),
+		logging.UnaryServerInterceptor(log),
+		grpc_prometheus.UnaryServerInterceptor,
+		recoveryUnary(log),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		otelgrpc.StreamServerInterceptor(),
+		logging.StreamServerInterceptor(log),
+		grpc_prometheus.StreamServerInterceptor,
+		recoveryStream(log),
+	}
+
+	authenticators, err := auth.NewAuthenticators(&cfg.Auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(authenticators) > 0 {
+		policy := authPolicy(&cfg.Auth)
+		unary = append(unary, auth.UnaryServerInterceptor(authenticators, policy))
+		stream = append(stream, auth.StreamServerInterceptor(authenticators, policy))
+	}
+
+	unary = append(unary, rateLimits.unary(), deadlines.unary(), validationUnary(grpc_handler.ValidateRequest))
+	stream = append(stream, rateLimits.stream(), deadlines.stream(), validationStream(grpc_handler.ValidateRequest))
+	return unary, stream, nil
+}
+
+// newGRPCServer - return gRPC server with TLS and message sizes from config and chains of interceptors
+func newGRPCServer(cfg *configs.Config, log *logrus.Entry,
+	unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if cfg.Server.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.Server.MaxRecvMsgSize))
+	}
+	if cfg.Server.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(cfg.Server.MaxSendMsgSize))
+	}
+
+	creds, err := serverCredentials(&cfg.Server, log)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	return grpc.NewServer(opts...), nil
+}
+
+// MapHealth - register gRPC health service with statuses from checker
+func (ps *ProductServers) MapHealth(checker *health.Checker) {
+	healthpb.RegisterHealthServer(ps.server, checker.Server())
+}
+
+// gatewayListener - return listener of HTTP/JSON gateway with TLS of the server
+// or nil if gateway is disabled
+func gatewayListener(cfg *configs.Config, log *logrus.Entry) (net.Listener, error) {
+	if cfg.Gateway.Port == "" {
+		return nil, nil
+	}
+	if err := checkCORS(&cfg.Gateway); err != nil {
+		return nil, err
+	}
+	config, err := serverTLSConfig(&cfg.Server, log, []string{"http/1.1"})
+	if err != nil {
+		return nil, err
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%s", cfg.Gateway.Host, cfg.Gateway.Port))
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		lis = tls.NewListener(lis, config)
+	}
+	return lis, nil
 }
 
+// Run - serve calls and requests of gateway until server is stopped,
+// metrics of all registered methods are reported from start
 func (ps *ProductServers) Run() error {
-	return ps.server.Serve(ps.lis)
+	grpc_prometheus.Register(ps.server)
+	if ps.gateway == nil {
+		return ps.server.Serve(ps.lis)
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- ps.server.Serve(ps.lis)
+	}()
+	go func() {
+		if err := ps.gateway.Serve(ps.gatewayLis); !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+			return
+		}
+		errs <- nil
+	}()
+	return <-errs
+}
+
+// Shutdown - stop accepting new calls and wait until running calls finish or ctx is done,
+// then interrupt running fetches, so they save their jobs, and close all connections
+func (ps *ProductServers) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ps.server.GracefulStop()
+		}()
+		if ps.gateway != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// it returns when all requests are finished or gateway is closed
+				_ = ps.gateway.Shutdown(context.Background())
+			}()
+		}
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+	}
+
+	interruptCtx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+	defer cancel()
+
+	var err error
+	if ps.useCases != nil {
+		err = ps.useCases.ProductsUC.Interrupt(interruptCtx)
+	}
+
+	// interrupted fetches send their errors to clients, other calls are cancelled
+	select {
+	case <-stopped:
+		return err
+	case <-interruptCtx.Done():
+	}
+	ps.server.Stop()
+	ps.closeGateway()
+	<-stopped
+	return err
 }
 
 func (ps *ProductServers) Stop() {
 
 	if err := ps.lis.Close(); err != nil {
-		log.Printf("ERROR: %v\n", err)
+		ps.log.Error(err)
 	}
 	ps.server.Stop()
+	ps.closeGateway()
+}
+
+// closeGateway - close listener and all connections of gateway
+func (ps *ProductServers) closeGateway() {
+	if ps.gateway != nil {
+		if err := ps.gateway.Close(); err != nil {
+			ps.log.Error(err)
+		}
+	} else if ps.gatewayLis != nil {
+		if err := ps.gatewayLis.Close(); err != nil {
+			ps.log.Error(err)
+		}
+	}
 }
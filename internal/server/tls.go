@@ -0,0 +1,183 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// serverCredentials - return TLS credentials of gRPC listener from config or nil if TLS isn't configured
+func serverCredentials(cfg *configs.ServerConfig, log *logrus.Entry) (credentials.TransportCredentials, error) {
+	// gRPC works over HTTP/2 only
+	config, err := serverTLSConfig(cfg, log, []string{"h2"})
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// serverTLSConfig - return TLS config with protocols of listener from config or nil if TLS isn't configured,
+// certificate files are checked every reload interval and loaded again if they are changed
+func serverTLSConfig(cfg *configs.ServerConfig, log *logrus.Entry, nextProtos []string) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("server: TLS client CA file requires certificate and key of the server")
+		}
+		return nil, nil
+	}
+
+	minVersion, err := tlsVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth := tls.NoClientCert
+	if cfg.TLSClientCAFile != "" {
+		switch cfg.TLSClientAuth {
+		case "", "request":
+			clientAuth = tls.VerifyClientCertIfGiven
+		case "require":
+			clientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return nil, fmt.Errorf("server: unknown TLS client auth %q", cfg.TLSClientAuth)
+		}
+	} else if cfg.TLSClientAuth == "require" {
+		return nil, fmt.Errorf("server: TLS client auth require needs TLS client CA file")
+	}
+
+	r := &certReloader{
+		certFile:   cfg.TLSCertFile,
+		keyFile:    cfg.TLSKeyFile,
+		caFile:     cfg.TLSClientCAFile,
+		minVersion: minVersion,
+		clientAuth: clientAuth,
+		nextProtos: nextProtos,
+		interval:   time.Duration(cfg.TLSReloadInterval) * time.Second,
+		log:        log,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         minVersion,
+		NextProtos:         nextProtos,
+		GetConfigForClient: r.configForClient,
+	}, nil
+}
+
+// tlsVersion - convert version from config into TLS version
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("server: unsupported TLS min version %q", version)
+	}
+}
+
+// certReloader - TLS config with certificate of the server and certificate authorities of clients from files,
+// so rotated certificates are used without restart of the server
+type certReloader struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	minVersion uint16
+	clientAuth tls.ClientAuthType
+	nextProtos []string
+	interval   time.Duration
+	log        *logrus.Entry
+
+	mu      sync.Mutex
+	config  *tls.Config
+	checked time.Time
+	// modification times of files which are loaded into config
+	modified map[string]time.Time
+}
+
+// configForClient - return TLS config for new connection,
+// files are checked for changes not more often than once per interval,
+// if changed files can't be loaded, for example only certificate is replaced yet, the old config is used
+func (r *certReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval > 0 && time.Since(r.checked) >= r.interval {
+		r.checked = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				r.log.WithError(err).Error("server: TLS certificates are not reloaded")
+			} else {
+				r.log.Info("server: TLS certificates are reloaded")
+			}
+		}
+	}
+	return r.config, nil
+}
+
+// changed - return true if any file was modified after it was loaded
+func (r *certReloader) changed() bool {
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modified[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// load - read files and replace config
+func (r *certReloader) load() error {
+	modified := make(map[string]time.Time)
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("server: TLS: %v", err)
+		}
+		modified[path] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("server: TLS certificate: %v", err)
+	}
+	config := &tls.Config{
+		MinVersion:   r.minVersion,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.clientAuth,
+		NextProtos:   r.nextProtos,
+	}
+
+	if r.caFile != "" {
+		pem, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("server: TLS client CA file: %v", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("server: TLS client CA file %s has no certificates", r.caFile)
+		}
+	}
+
+	r.config, r.modified = config, modified
+	return nil
+}
+
+// files - return paths of all files of config
+func (r *certReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/auth"
+	"github.com/ArturChopikian/grpc-server/internal/logging"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/rs/cors"
+	"google.golang.org/grpc"
+	"net/http"
+	"time"
+)
+
+// time for which browsers cache results of preflight requests
+const corsMaxAge = 10 * time.Minute
+
+// headers which web pages can send: credentials of auth, request id and trace context
+var corsHeaders = []string{
+	"Authorization", auth.APIKeyHeader, "Content-Type", logging.RequestIDHeader, "traceparent", "tracestate", "baggage",
+}
+
+// checkCORS - return error if * is in allowed origins with gRPC-Web,
+// gRPC-Web wrapper always allows credentials and browsers reject * with them
+func checkCORS(cfg *configs.GatewayConfig) error {
+	if !cfg.GRPCWeb {
+		return nil
+	}
+	for _, origin := range cfg.CORSOrigins {
+		if origin == "*" {
+			return fmt.Errorf("gateway: origin * can't be allowed with gRPC-Web, list origins of web pages")
+		}
+	}
+	return nil
+}
+
+// webHandler - return handler of gateway port: gRPC-Web calls are served by gRPC server if they are enabled,
+// so they pass the same interceptors as native gRPC calls, other requests are served by gateway,
+// web pages from allowed origins can make both kinds of requests,
+// callers send credentials in headers, so cookies aren't allowed for requests of gateway
+func webHandler(cfg *configs.GatewayConfig, server *grpc.Server, gateway http.Handler) http.Handler {
+	allowed := allowedOrigin(cfg.CORSOrigins)
+
+	handler := cors.New(cors.Options{
+		AllowOriginFunc:  allowed,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   corsHeaders,
+		ExposedHeaders:   []string{logging.RequestIDHeader, "Retry-After"},
+		AllowCredentials: false,
+		MaxAge:           int(corsMaxAge / time.Second),
+	}).Handler(gateway)
+	if !cfg.GRPCWeb {
+		return handler
+	}
+
+	// headers of responses of gRPC-Web calls are exposed by the wrapper itself,
+	// headers of gRPC-Web clients are allowed besides ours
+	wrapped := grpcweb.WrapServer(server, grpcweb.WithOriginFunc(allowed),
+		grpcweb.WithAllowedRequestHeaders(append([]string{"x-grpc-web", "x-user-agent", "grpc-timeout"}, corsHeaders...)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wrapped.IsGrpcWebRequest(r) || wrapped.IsAcceptableGrpcCorsRequest(r) {
+			wrapped.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// allowedOrigin - return function which allows origins from the list, * allows all origins
+func allowedOrigin(origins []string) func(origin string) bool {
+	set := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		set[origin] = true
+	}
+	return func(origin string) bool {
+		return set["*"] || set[origin]
+	}
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+)
+
+// name of tracer of the server's own spans
+const tracerName = "github.com/ArturChopikian/grpc-server"
+
+// Init - set global tracer provider with exporter from config: none, otlp, stdout or file
+// trace context is propagated from callers and to downloaded feeds even if spans aren't exported
+// return function which flushes spans and stops exporter, it must be called on shutdown
+func Init(cfg *configs.Config) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closeFn  = func() error { return nil }
+		err      error
+	)
+	switch cfg.Tracing.Exporter {
+	case "", "none":
+		return func(ctx context.Context) error { return nil }, nil
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Tracing.OTLPEndpoint)}
+		if cfg.Tracing.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		// exporter connects in background, so the server starts even if collector is down
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		f, openErr := os.OpenFile(cfg.Tracing.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if openErr != nil {
+			return nil, fmt.Errorf("tracing: file exporter: %v", openErr)
+		}
+		closeFn = f.Close
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Tracing.Exporter)
+	}
+	if err != nil {
+		_ = closeFn()
+		return nil, fmt.Errorf("tracing: %s exporter: %v", cfg.Tracing.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cfg.Tracing.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := closeFn(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// Tracer - return tracer for spans of the server
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
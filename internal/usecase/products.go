@@ -5,94 +5,643 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
+	"github.com/ArturChopikian/grpc-server/internal/auth"
+	"github.com/ArturChopikian/grpc-server/internal/metrics"
 	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/ratelimit"
 	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/ArturChopikian/grpc-server/internal/tracing"
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"io"
 	"net/http"
-	"runtime"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// number of products read from repository at once by Stream
+	streamPageSize = 100
+	// time for saving state of ended fetch
+	saveJobTimeout = 10 * time.Second
+	// number of products of full snapshot which are marked as seen at once
+	seenBatchSize = 500
+)
+
 // productUC - define business logic for products handlers
 type productUC struct {
-	productsRepos repository.ProductsReposInterface
+	productsRepos   repository.ProductsReposInterface
+	ratesRepos      repository.RatesReposInterface
+	jobsRepos       repository.FetchJobsReposInterface
+	defaultCurrency string
+	// client of downloads of feeds, it sends trace context to them
+	httpClient *http.Client
+	log        *logrus.Entry
+
+	// fetches which run at once, others wait in queue or are rejected with retry after
+	fetchQueue      *ratelimit.Queue
+	fetchRetryAfter time.Duration
+
+	// running fetches, they are cancelled by stop
+	fetchesMu sync.Mutex
+	fetches   sync.WaitGroup
+	stopCtx   context.Context
+	stop      context.CancelFunc
 }
 
-// List - take orderBy, pageSize, pageNumber
+// List - take options with orderBy, pageSize, pageNumber and filters
 // and call List method from repository
 // return list of product's pointers or error
-func (uc *productUC) List(ctx context.Context, orderBy map[string]int32, pageSize int32, pageNumber int32) ([]*models.Product, error) {
+// if currency is set or prices of sources are compared, exchange rates are added to options
+func (uc *productUC) List(ctx context.Context, opts *models.ListOptions) ([]*models.Product, error) {
+
+	if err := uc.addRates(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	return uc.productsRepos.List(ctx, opts)
+}
+
+// Stream - take options of list and call fn for each product of the list in order,
+// products are read by pages of opts.PageSize after opts.PageToken, page number is ignored
+// stop and return error if fn returns error or page token doesn't advance, so broken ordering can't loop forever
+func (uc *productUC) Stream(ctx context.Context, opts *models.ListOptions, fn func(p *models.Product) error) error {
+
+	field, desc, err := opts.Order()
+	if err != nil {
+		return err
+	}
+	if err := uc.addRates(ctx, opts); err != nil {
+		return err
+	}
+
+	page := *opts
+	page.PageNumber = 0
+	if page.PageSize <= 0 {
+		page.PageSize = streamPageSize
+	}
 
-	return uc.productsRepos.List(ctx, orderBy, pageSize, pageNumber)
+	for {
+		products, err := uc.productsRepos.List(ctx, &page)
+		if err != nil {
+			return err
+		}
+		for _, p := range products {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		if len(products) < int(page.PageSize) {
+			return nil
+		}
+		last := products[len(products)-1]
+		if page.PageToken != nil && page.PageToken.Id == last.Id {
+			return fmt.Errorf("usecase: list doesn't advance after product %s", last.Id.Hex())
+		}
+		page.PageToken = models.NewPageToken(last, field, desc)
+	}
+}
+
+// addRates - add exchange rates to options if currency is set or prices of sources are compared
+// return UnknownRateError if there is no rate for currency
+func (uc *productUC) addRates(ctx context.Context, opts *models.ListOptions) error {
+	if opts.Currency == "" && opts.PriceMode != models.PriceModeLowest {
+		return nil
+	}
+
+	rates, err := uc.ratesRepos.List(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := rates[opts.Currency]; opts.Currency != "" && !ok {
+		return fmt.Errorf("%w %s", models.UnknownRateError, opts.Currency)
+	}
+	opts.Rates = rates
+	return nil
 }
 
-// Fetch - take URL with external csv file
-// we have the pipeline
+// GetById - take id and return product with history of its price
+// or NotFoundProductError
+func (uc *productUC) GetById(ctx context.Context, id primitive.ObjectID) (*models.Product, error) {
+	return uc.productsRepos.GetById(ctx, id)
+}
+
+// GetByName - take name and return product with history of its price
+// or NotFoundProductError
+func (uc *productUC) GetByName(ctx context.Context, name string) (*models.Product, error) {
+	return uc.productsRepos.Get(ctx, name)
+}
+
+// BatchGet - take ids and names and return found products
+func (uc *productUC) BatchGet(ctx context.Context, ids []primitive.ObjectID, names []string) ([]*models.Product, error) {
+	return uc.productsRepos.GetMany(ctx, ids, names)
+}
+
+// Create - take name, price and its currency (default currency if empty) and create new product manually
+// return created product or ProductExistsError if product with this name exists
+func (uc *productUC) Create(ctx context.Context, name string, price models.Decimal, currency string) (*models.Product, error) {
+	if err := uc.checkNameFree(ctx, name); err != nil {
+		return nil, err
+	}
+	if currency == "" {
+		currency = uc.defaultCurrency
+	}
+
+	change := models.NewPriceChange(price, currency, models.ManualSource, models.PriceChangeManual, auth.FromContext(ctx).Name)
+	product := createProduct(name, change)
+
+	if err := uc.productsRepos.Create(ctx, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Update - take id and new values of fields, nil value means field is not changed
+// price is changed for the source, "manual" by default, in the currency, default currency if empty
+// if price changed, it is recorded in the history with the principal from ctx
+// return updated product, NotFoundProductError or ProductExistsError if new name is taken
+func (uc *productUC) Update(ctx context.Context, id primitive.ObjectID, name *string, price *models.Decimal, currency, source string) (*models.Product, error) {
+	product, err := uc.productsRepos.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	upd := &models.ProductUpdate{}
+
+	if name != nil && *name != product.Name {
+		if err := uc.checkNameFree(ctx, *name); err != nil {
+			return nil, err
+		}
+		upd.Name = name
+	}
+	if source == "" {
+		source = models.ManualSource
+	}
+	if currency == "" {
+		currency = uc.defaultCurrency
+	}
+	if price != nil {
+		if sp := product.SourcePrice(source); sp == nil || !sp.Price.Equal(*price) || sp.Currency != currency {
+			upd.Price = models.NewPriceChange(*price, currency, source, models.PriceChangeManual, auth.FromContext(ctx).Name)
+		}
+	}
+
+	// nothing changed
+	if upd.Name == nil && upd.Price == nil {
+		return product, nil
+	}
+	return uc.productsRepos.Update(ctx, id, upd)
+}
+
+// Delete - take id and mark product as discontinued (soft delete)
+// return NotFoundProductError if product not exists
+func (uc *productUC) Delete(ctx context.Context, id primitive.ObjectID) error {
+	product, err := uc.productsRepos.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+	// already deleted, keep the first time of deletion
+	if product.Discontinued != nil {
+		return nil
+	}
+	return uc.productsRepos.Discontinue(ctx, id, time.Now())
+}
+
+// Restore - take id and restore discontinued product
+// return restored product or NotFoundProductError if product not exists
+func (uc *productUC) Restore(ctx context.Context, id primitive.ObjectID) (*models.Product, error) {
+	product, err := uc.productsRepos.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if product.Discontinued == nil {
+		return product, nil
+	}
+	return uc.productsRepos.Restore(ctx, id, "")
+}
+
+// PriceHistory - take product and source
+// return changes of product's price from this source or all changes if source is empty
+func (uc *productUC) PriceHistory(product *models.Product, source string) []*models.PriceChange {
+	if source == "" {
+		return product.History
+	}
+
+	var result []*models.PriceChange
+	for _, c := range product.History {
+		if c.Source == source {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// checkNameFree - return ProductExistsError if product with this name exists
+func (uc *productUC) checkNameFree(ctx context.Context, name string) error {
+	_, err := uc.productsRepos.Get(ctx, name)
+	if err == nil {
+		return models.ProductExistsError
+	}
+	if errors.Is(err, models.NotFoundProductError) {
+		return nil
+	}
+	return err
+}
+
+// Fetch - take options with URL of external csv file and source of prices
+// wait until the number of running fetches is below the limit,
+// run fetch pipeline and save its state as fetch job when it starts and when it ends
+// if queue of waiting fetches is full ResourceExhausted error with retry delay is returned
+// running fetch is cancelled by Interrupt, its job is saved as interrupted together with
+// changes made before it and Unavailable error is returned
+func (uc *productUC) Fetch(ctx context.Context, opts *models.FetchOptions) (*models.FetchResult, error) {
+
+	stopCtx, done, err := uc.startFetch()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	ctx, span := tracing.Tracer().Start(ctx, "usecase.Fetch", trace.WithAttributes(
+		attribute.String("fetch.url", opts.URL),
+		attribute.Bool("fetch.full_snapshot", opts.FullSnapshot),
+	))
+	defer span.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	release, err := uc.waitFetch(ctx, stopCtx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+		return nil, err
+	}
+	defer release()
+
+	metrics.FetchesRunning.Inc()
+	defer metrics.FetchesRunning.Dec()
+	started := time.Now()
+
+	fetchOpts := *opts
+	if fetchOpts.Source == "" {
+		fetchOpts.Source = models.SourceFromURL(opts.URL)
+	}
+
+	// job is saved as running before the pipeline starts
+	job := models.NewFetchJob(&fetchOpts, auth.FromContext(ctx).Name)
+	span.SetAttributes(
+		attribute.String("fetch.source", fetchOpts.Source),
+		attribute.String("fetch.job_id", job.Id.Hex()),
+	)
+	if err := uc.jobsRepos.Save(ctx, job); err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+		return nil, storageError(err, "fetch job is not saved")
+	}
+
+	result, err := uc.fetch(ctx, &fetchOpts, job.Id)
+
+	jobStatus := models.FetchJobSucceeded
+	switch {
+	case err != nil && stopCtx.Err() != nil:
+		jobStatus = models.FetchJobInterrupted
+		err = status.Error(codes.Unavailable, "fetch is interrupted by shutdown of server")
+	case err != nil:
+		jobStatus = models.FetchJobFailed
+	}
+	job.Finish(jobStatus, result, err)
+	metrics.FetchDuration.WithLabelValues(jobStatus).Observe(time.Since(started).Seconds())
+
+	span.SetAttributes(
+		attribute.String("fetch.status", jobStatus),
+		attribute.Int64("fetch.created", job.Result.Created),
+		attribute.Int64("fetch.updated", job.Result.Updated),
+		attribute.Int64("fetch.restored", job.Result.Restored),
+		attribute.Int64("fetch.discontinued", job.Result.Discontinued),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+
+	// job is saved even if the call is cancelled, but in the trace of the fetch
+	saveCtx, cancelSave := context.WithTimeout(trace.ContextWithSpan(context.Background(), span), saveJobTimeout)
+	defer cancelSave()
+	if saveErr := uc.jobsRepos.Save(saveCtx, job); saveErr != nil {
+		uc.log.WithContext(ctx).WithError(saveErr).WithFields(logrus.Fields{
+			"job_id": job.Id.Hex(),
+			"status": jobStatus,
+		}).Error("usecase: fetch job is not saved")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// startFetch - register running fetch
+// return context which is cancelled by Interrupt and function which must be called when fetch ends
+// or Unavailable error if fetches are interrupted
+func (uc *productUC) startFetch() (context.Context, func(), error) {
+	uc.fetchesMu.Lock()
+	defer uc.fetchesMu.Unlock()
+
+	if uc.stopCtx.Err() != nil {
+		return nil, nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	uc.fetches.Add(1)
+	return uc.stopCtx, uc.fetches.Done, nil
+}
+
+// waitFetch - take free slot of fetch from queue
+// return function which frees it or ResourceExhausted error if queue is full,
+// Unavailable error if waiting fetch is interrupted or error of ctx
+func (uc *productUC) waitFetch(ctx, stopCtx context.Context) (func(), error) {
+	metrics.FetchesQueued.Inc()
+	waiting := time.Now()
+	release, err := uc.fetchQueue.Acquire(ctx)
+	metrics.FetchesQueued.Dec()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("fetch.queue_ms", time.Since(waiting).Milliseconds()))
+
+	switch {
+	case err == nil:
+		return release, nil
+	case errors.Is(err, ratelimit.QueueFullError):
+		metrics.FetchesRejected.Inc()
+		return nil, ratelimit.ExhaustedError("too many fetches are running", uc.fetchRetryAfter)
+	case stopCtx.Err() != nil:
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	default:
+		return nil, status.FromContextError(err).Err()
+	}
+}
+
+// Interrupt - stop accepting new fetches, cancel running ones
+// and wait until they save their jobs or ctx is done
+func (uc *productUC) Interrupt(ctx context.Context) error {
+	uc.fetchesMu.Lock()
+	uc.stop()
+	uc.fetchesMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		uc.fetches.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("usecase: running fetches are not stopped: %v", ctx.Err())
+	}
+}
+
+// fetch - we have the pipeline
 //
-//			->check->
+//	->check->
+//
+//	->check->
+//				-> update
 //
-//			->check->
-//						-> update
 // start->	->check-> ->
-//						-> create
-//			->check->
 //
-//			->check->
+//				-> create
+//	->check->
+//
+//	->check->
 //
 // start stage goroutine parse scv file form URL and line by line transmit to the next stage
 //
 // check stage it is 5 goroutine which get data from start and check product
-// if this product exists (in mongoDb collection) and price changed - transmit to the next stage (update)
+// if this product exists (in mongoDb collection) and price of the source changed
+// or product or its price from the source was discontinued - transmit to the next stage (update)
 // if this product not exist (in mongoDb collection) - transmit to the next stage (create)
 //
 // create stage get data and insert new product into collection
 //
-// update stage get data and update product price, updated time and counted of updated price
-func (uc *productUC) Fetch(ctx context.Context, url string) error {
-
+// update stage get data, restore discontinued product and update price of the source, updated time and counted of updated price
+//
+// if file is full snapshot of the feed, prices of the source of products from the file are stamped with id of the job,
+// after all stages prices of the source which aren't stamped are marked as discontinued,
+// products without active prices are marked as discontinued too
+// first error stops all stages, in this case nothing is discontinued
+// counters of result are returned even if fetch failed
+func (uc *productUC) fetch(ctx context.Context, opts *models.FetchOptions, job primitive.ObjectID) (*models.FetchResult, error) {
+
+	parentCtx := ctx
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	type checkData struct {
-		name  string
-		price float64
+		name     string
+		price    models.Decimal
+		currency string
 	}
 
 	type updateData struct {
-		id    primitive.ObjectID
-		price float64
+		id             primitive.ObjectID
+		price          models.Decimal
+		currency       string
+		priceChanged   bool
+		restoreProduct bool
+		restoreSource  bool
+	}
+
+	// who started fetch, all changes of prices are recorded with it
+	principal := auth.FromContext(ctx).Name
+
+	// all prices from the file belong to one source
+	source := opts.Source
+	if source == "" {
+		source = models.SourceFromURL(opts.URL)
+	}
+
+	// currency of prices without the third column
+	currency := opts.Currency
+	if currency == "" {
+		currency = uc.defaultCurrency
+	}
+
+	result := &models.FetchResult{}
+
+	// keep only the first error and stop all stages
+	errCh := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+		}
+		cancel()
+	}
+
+	// ids of existing products from the file which prices of the source aren't stamped with the job yet,
+	// created products are stamped by creation
+	var (
+		seenMu sync.Mutex
+		seen   []primitive.ObjectID
+	)
+	// flushSeen - stamp prices of the source of products from the batch with the job
+	flushSeen := func(ctx context.Context, batch []primitive.ObjectID) error {
+		if err := uc.productsRepos.MarkSeen(ctx, source, batch, job); err != nil {
+			return storageError(err, "products of fetch are not marked as seen")
+		}
+		return nil
+	}
+	// markSeen - add product of full snapshot to the batch of seen products, full batch is stamped,
+	// it is called after changes of the product, so new prices of sources are stamped too
+	markSeen := func(ctx context.Context, id primitive.ObjectID) error {
+		if !opts.FullSnapshot {
+			return nil
+		}
+		seenMu.Lock()
+		seen = append(seen, id)
+		var batch []primitive.ObjectID
+		if len(seen) >= seenBatchSize {
+			batch, seen = seen, nil
+		}
+		seenMu.Unlock()
+
+		if batch == nil {
+			return nil
+		}
+		return flushSeen(ctx, batch)
+	}
+
+	// rows sent to stages and not accepted by them yet
+	checkQueue := metrics.FetchStageQueue.WithLabelValues("check")
+	createQueue := metrics.FetchStageQueue.WithLabelValues("create")
+	updateQueue := metrics.FetchStageQueue.WithLabelValues("update")
+
+	// changes - return changes of existing product by row of the file or nil if product is unchanged
+	changes := func(product *models.Product, price models.Decimal, currency string) *updateData {
+		sp := product.SourcePrice(source)
+		data := &updateData{
+			id:             product.Id,
+			price:          price,
+			currency:       currency,
+			priceChanged:   sp == nil || !sp.Price.Equal(price) || sp.Currency != currency,
+			restoreProduct: product.Discontinued != nil,
+			restoreSource:  sp != nil && sp.Discontinued != nil,
+		}
+		if !data.priceChanged && !data.restoreProduct && !data.restoreSource {
+			metrics.FetchRows.WithLabelValues(metrics.RowUnchanged).Inc()
+			return nil
+		}
+		return data
 	}
 
-	create := func(ctx context.Context, products <-chan *models.Product, errCh chan error) {
+	// apply - restore product and its source and change price of the source
+	apply := func(ctx context.Context, d *updateData) error {
+		if d.restoreProduct || d.restoreSource {
+			restoreSource := ""
+			if d.restoreSource {
+				restoreSource = source
+			}
+			if _, err := uc.productsRepos.Restore(ctx, d.id, restoreSource); err != nil {
+				return storageError(err, "product of fetch is not restored")
+			}
+			atomic.AddInt64(&result.Restored, 1)
+			metrics.FetchRows.WithLabelValues(metrics.RowRestored).Inc()
+		}
+		if d.priceChanged {
+			change := models.NewPriceChange(d.price, d.currency, source, models.PriceChangeFetch, principal)
+			if err := uc.productsRepos.UpdatePrice(ctx, d.id, change); err != nil {
+				return storageError(err, "price of fetch is not updated")
+			}
+			atomic.AddInt64(&result.Updated, 1)
+			metrics.FetchRows.WithLabelValues(metrics.RowUpdated).Inc()
+		}
+		return nil
+	}
+
+	create := func(ctx context.Context, products <-chan *models.Product) {
+		ctx, end := startStage(ctx, "create")
+		var rows int64
+		defer func() { end(attribute.Int64("fetch.rows", rows)) }()
+
 		for p := range products {
+			createQueue.Dec()
+			rows++
 			err := uc.productsRepos.Create(ctx, p)
+			if errors.Is(err, models.ProductExistsError) {
+				// product is created after the check by the same name earlier in the file,
+				// other fetch or CreateProduct, so the row updates it
+				existing, err := uc.productsRepos.Get(ctx, p.Name)
+				if err != nil {
+					fail(storageError(err, "existing product of fetch is not read"))
+					return
+				}
+				if data := changes(existing, p.Price, p.Currency); data != nil {
+					if err := apply(ctx, data); err != nil {
+						fail(err)
+						return
+					}
+				}
+				if err := markSeen(ctx, existing.Id); err != nil {
+					fail(err)
+					return
+				}
+				continue
+			}
 			if err != nil {
-				errCh <- status.Errorf(codes.Internal, err.Error())
+				fail(storageError(err, "product of fetch is not created"))
 				return
 			}
+			atomic.AddInt64(&result.Created, 1)
+			metrics.FetchRows.WithLabelValues(metrics.RowCreated).Inc()
 		}
 	}
 
-	update := func(ctx context.Context, inData <-chan *updateData, errCh chan error) {
+	update := func(ctx context.Context, inData <-chan *updateData) {
+		ctx, end := startStage(ctx, "update")
+		var rows int64
+		defer func() { end(attribute.Int64("fetch.rows", rows)) }()
+
 		for d := range inData {
-			err := uc.productsRepos.UpdatePrice(ctx, d.id, d.price)
-			if err != nil {
-				errCh <- status.Errorf(codes.Internal, err.Error())
+			updateQueue.Dec()
+			rows++
+			if err := apply(ctx, d); err != nil {
+				fail(err)
+				return
+			}
+			if err := markSeen(ctx, d.id); err != nil {
+				fail(err)
 				return
 			}
 		}
 	}
 
-	check := func(ctx context.Context, inData <-chan *checkData, errCh chan error) (<-chan *models.Product, <-chan *updateData) {
+	check := func(ctx context.Context, inData <-chan *checkData) (<-chan *models.Product, <-chan *updateData) {
 
 		createChan := make(chan *models.Product)
 		updateChan := make(chan *updateData)
 
+		// one span for all goroutines of the stage
+		ctx, end := startStage(ctx, "check")
+		var rows int64
+
 		var wg sync.WaitGroup
 
 		wg.Add(5)
@@ -101,18 +650,41 @@ func (uc *productUC) Fetch(ctx context.Context, url string) error {
 			go func() {
 				defer wg.Done()
 				for d := range inData {
+					checkQueue.Dec()
+					atomic.AddInt64(&rows, 1)
 					product, err := uc.productsRepos.Get(ctx, d.name)
 
 					if err != nil {
 						if errors.Is(err, models.NotFoundProductError) {
-							createChan <- createProduct(d.name, d.price)
+							change := models.NewPriceChange(d.price, d.currency, source, models.PriceChangeFetch, principal)
+							createQueue.Inc()
+							select {
+							case createChan <- createSeenProduct(d.name, change, job):
+							case <-ctx.Done():
+								createQueue.Dec()
+								return
+							}
 							continue
 						}
-						errCh <- status.Errorf(codes.Internal, err.Error())
+						fail(storageError(err, "product of fetch is not checked"))
 						return
 					}
-					if product.Price != d.price {
-						updateChan <- &updateData{id: product.Id, price: d.price}
+
+					data := changes(product, d.price, d.currency)
+					if data == nil {
+						if err := markSeen(ctx, product.Id); err != nil {
+							fail(err)
+							return
+						}
+						continue
+					}
+
+					updateQueue.Inc()
+					select {
+					case updateChan <- data:
+					case <-ctx.Done():
+						updateQueue.Dec()
+						return
 					}
 				}
 			}()
@@ -122,35 +694,82 @@ func (uc *productUC) Fetch(ctx context.Context, url string) error {
 			wg.Wait()
 			close(createChan)
 			close(updateChan)
+			end(attribute.Int64("fetch.rows", rows))
 		}()
 
 		return createChan, updateChan
 	}
 
-	start := func(ctx context.Context, resBody io.ReadCloser, errCh chan error) <-chan *checkData {
+	// invalid row stops fetch
+	reject := func(err error) {
+		metrics.FetchRows.WithLabelValues(metrics.RowRejected).Inc()
+		fail(err)
+	}
+
+	start := func(ctx context.Context, req *http.Request, resBody *countingBody, downloadStarted time.Time, end func(...attribute.KeyValue)) <-chan *checkData {
 		checkChan := make(chan *checkData)
 
 		reader := csv.NewReader(resBody)
 
 		go func() {
+			var rows int64
+			defer func() { end(attribute.Int64("fetch.rows", rows), attribute.Int64("fetch.bytes", resBody.n)) }()
 			defer close(checkChan)
 			defer resBody.Close()
 
-			for {
+			// lines can have the third column with currency
+			reader.FieldsPerRecord = -1
+
+			for first := true; ; first = false {
 				line, err := reader.Read()
 				if err == io.EOF {
+					metrics.FetchDownloadDuration.Observe(time.Since(downloadStarted).Seconds())
 					break
 				}
+				var parseErr *csv.ParseError
+				switch {
+				case errors.As(err, &parseErr):
+					reject(feedParseError(parseErr.Line, "%v", parseErr))
+					return
+				case err != nil && ctx.Err() == nil:
+					// download is broken, not cancelled by fetch
+					fail(uc.feedError(req.URL, 0, err))
+					return
+				case err != nil:
+					return
+				}
+				lineNumber, _ := reader.FieldPos(0)
+				if len(line) < 2 || len(line) > 3 {
+					reject(feedParseError(lineNumber, "line %q: expected NAME,PRICE[,CURRENCY]", line))
+					return
+				}
+				// skip header
+				if first && strings.EqualFold(strings.TrimSpace(line[1]), "price") {
+					continue
+				}
+
 				name := line[0]
-				price, err := strconv.ParseFloat(line[1], 64)
+				price, err := models.ParseDecimal(line[1])
 				if err != nil {
-					errCh <- status.Errorf(codes.InvalidArgument, err.Error())
+					reject(feedParseError(lineNumber, "line %q: %v", line, err))
+					return
+				}
+
+				lineCurrency := currency
+				if len(line) == 3 && strings.TrimSpace(line[2]) != "" {
+					lineCurrency = strings.ToUpper(strings.TrimSpace(line[2]))
+				}
+				if !models.ValidCurrency(lineCurrency) {
+					reject(feedParseError(lineNumber, "line %q: invalid currency %q", line, lineCurrency))
 					return
 				}
 
+				checkQueue.Inc()
 				select {
-				case checkChan <- &checkData{name: name, price: price}:
+				case checkChan <- &checkData{name: name, price: price, currency: lineCurrency}:
+					rows++
 				case <-ctx.Done():
+					checkQueue.Dec()
 					return
 				}
 			}
@@ -158,22 +777,37 @@ func (uc *productUC) Fetch(ctx context.Context, url string) error {
 		return checkChan
 	}
 
-	// get external csv file by url
-	resp, err := http.Get(url)
+	// get external csv file by url, download is stopped if fetch is cancelled
+	// span of download stage covers request of file and reading of all its rows
+	downloadCtx, endDownload := startStage(ctx, "download")
+	req, err := http.NewRequestWithContext(downloadCtx, http.MethodGet, opts.URL, nil)
 	if err != nil {
-		return err
+		endDownload()
+		return result, apierror.InvalidField("url", "url: %v", err)
+	}
+	downloadStarted := time.Now()
+	resp, err := uc.httpClient.Do(req)
+	if err != nil {
+		endDownload()
+		if err := parentCtx.Err(); err != nil {
+			return result, status.FromContextError(err).Err()
+		}
+		return result, uc.feedError(req.URL, 0, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		endDownload()
+		return result, uc.feedError(req.URL, resp.StatusCode, nil)
 	}
-
-	errCh := make(chan error, 1)
 
 	// start goroutine which parse csv file line by line and send in to data channel
-	dataCh := start(ctx, resp.Body, errCh)
+	dataCh := start(downloadCtx, req, &countingBody{ReadCloser: resp.Body}, downloadStarted, endDownload)
 
 	// check run 5 goroutines which receive (name and price) from dataCh chan and check
-	// if product exist in the database and after check if the price has changed
+	// if product exist in the database and after check if the price has changed or product was discontinued
 	// if not send data to createCh
 	// if yes send data to updateCh
-	createCh, updateCh := check(ctx, dataCh, errCh)
+	createCh, updateCh := check(ctx, dataCh)
 
 	// run 2 goroutines
 	var wg sync.WaitGroup
@@ -182,26 +816,44 @@ func (uc *productUC) Fetch(ctx context.Context, url string) error {
 	// run goroutine which receive data from createCh and create new product
 	go func() {
 		defer wg.Done()
-		create(ctx, createCh, errCh)
+		create(ctx, createCh)
 	}()
 
 	// run goroutine which receive data from updateCh and update existing product
 	go func() {
 		defer wg.Done()
-		update(ctx, updateCh, errCh)
+		update(ctx, updateCh)
 	}()
 
 	wg.Wait()
-	close(errCh)
 
-	fmt.Println("num of goroutine: ", runtime.NumGoroutine())
 	// check if somewhere have error
 	select {
 	case err := <-errCh:
-		return err
+		return result, err
 	default:
-		return nil
 	}
+	// fetch was canceled by caller
+	if err := parentCtx.Err(); err != nil {
+		return result, status.FromContextError(err).Err()
+	}
+
+	if opts.FullSnapshot {
+		if len(seen) > 0 {
+			if err := flushSeen(ctx, seen); err != nil {
+				return result, err
+			}
+		}
+
+		n, err := uc.productsRepos.DiscontinueMissing(ctx, source, job, time.Now())
+		if err != nil {
+			return result, storageError(err, "missing products are not discontinued")
+		}
+		result.Discontinued = n
+		metrics.FetchDiscontinued.Add(float64(n))
+	}
+
+	return result, nil
 }
 
 //func (uc *productUC) Fetch(ctx context.Context, url string) error {
@@ -257,20 +909,99 @@ func (uc *productUC) Fetch(ctx context.Context, url string) error {
 //	return nil
 //}
 
+// storageError - return error for clients without details of failure of storage,
+// failure with message is kept as cause for the log of the call, cancellation of ctx isn't failure
+func storageError(err error, message string) error {
+	return apierror.FromError(fmt.Errorf("%s: %w", message, err))
+}
+
+// feedError - take url of CSV file, HTTP status of response or error of download
+// return FEED_UNREACHABLE error, error of download is kept as cause for the log of the call,
+// only failures of network and server of the file can be retried
+func (uc *productUC) feedError(u *url.URL, httpStatus int, err error) error {
+	metadata := map[string]string{"host": u.Host}
+	if httpStatus == 0 {
+		return apierror.WithCause(apierror.Reason(codes.Unavailable, apierror.ReasonFeedUnreachable,
+			"CSV file can't be downloaded", metadata, uc.fetchRetryAfter), err)
+	}
+
+	metadata["http_status"] = strconv.Itoa(httpStatus)
+	message := fmt.Sprintf("CSV file can't be downloaded: server of file answered %d", httpStatus)
+	if httpStatus < http.StatusInternalServerError && httpStatus != http.StatusTooManyRequests {
+		return apierror.Reason(codes.FailedPrecondition, apierror.ReasonFeedUnreachable, message, metadata, 0)
+	}
+	return apierror.Reason(codes.Unavailable, apierror.ReasonFeedUnreachable, message, metadata, uc.fetchRetryAfter)
+}
+
+// feedParseError - return FEED_PARSE_ERROR error of invalid line of CSV file
+func feedParseError(line int, format string, args ...interface{}) error {
+	return apierror.Reason(codes.InvalidArgument, apierror.ReasonFeedParseError, fmt.Sprintf(format, args...),
+		map[string]string{"line": strconv.Itoa(line)}, 0)
+}
+
+// startStage - start span of stage of fetch pipeline: download, check, create or update
+// return ctx with the span and function which ends it with attributes
+func startStage(ctx context.Context, stage string) (context.Context, func(attrs ...attribute.KeyValue)) {
+	ctx, span := tracing.Tracer().Start(ctx, "fetch."+stage)
+	return ctx, func(attrs ...attribute.KeyValue) {
+		span.SetAttributes(attrs...)
+		span.End()
+	}
+}
+
+// countingBody - body of downloaded file which counts read bytes in metrics
+type countingBody struct {
+	io.ReadCloser
+	// bytes read from the body
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	metrics.FetchDownloadBytes.Add(float64(n))
+	return n, err
+}
+
 // newProductUC - return pointer of productUC
-func newProductUC(repos repository.ProductsReposInterface) *productUC {
-	return &productUC{productsRepos: repos}
+func newProductUC(repos repository.ProductsReposInterface, rates repository.RatesReposInterface,
+	jobs repository.FetchJobsReposInterface, cfg *configs.Config, log *logrus.Entry) *productUC {
+	stopCtx, stop := context.WithCancel(context.Background())
+
+	return &productUC{
+		productsRepos:   repos,
+		ratesRepos:      rates,
+		jobsRepos:       jobs,
+		defaultCurrency: cfg.Currency.Default,
+		httpClient:      &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		log:             log,
+		fetchQueue:      ratelimit.NewQueue(cfg.Limits.MaxFetches, cfg.Limits.FetchQueueSize),
+		fetchRetryAfter: time.Duration(cfg.Limits.FetchRetryAfter) * time.Second,
+		stopCtx:         stopCtx,
+		stop:            stop,
+	}
+}
+
+// createSeenProduct - return new product from fetch of full snapshot with price of the source stamped with the job
+func createSeenProduct(name string, change *models.PriceChange, job primitive.ObjectID) *models.Product {
+	product := createProduct(name, change)
+	product.Sources[0].Seen = job
+	return product
 }
 
-// createProduct - take name and price
+// createProduct - take name and first price of product
 // define all fields for models.Product
 // return - pointer for this product
-func createProduct(name string, price float64) *models.Product {
+func createProduct(name string, change *models.PriceChange) *models.Product {
 	return &models.Product{
 		Id:           primitive.NewObjectID(),
 		Name:         name,
-		Price:        price,
-		Updated:      time.Now(),
+		Price:        change.Price,
+		Currency:     change.Currency,
+		PriceSource:  change.Source,
+		Updated:      change.Changed,
 		PriceUpdates: 0,
+		Sources:      []*models.SourcePrice{models.NewSourcePrice(change)},
+		History:      []*models.PriceChange{change},
 	}
 }
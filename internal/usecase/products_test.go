@@ -0,0 +1,250 @@
+package usecase
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
+	"github.com/ArturChopikian/grpc-server/internal/apierror"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+	_ "github.com/ArturChopikian/grpc-server/internal/repository/memory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name string
+		// files served by earlier fetches of the same URL
+		before []string
+		csv    string
+		// HTTP status of the file, 0 - 200
+		status       int
+		fullSnapshot bool
+		currency     string
+		want         *models.FetchResult
+		wantCode     codes.Code
+		wantReason   string
+		// prices of products after fetch: name -> price and currency
+		wantPrices map[string]string
+	}{
+		{
+			name:       "new products",
+			csv:        "apple,10.50\npear,3\n",
+			want:       &models.FetchResult{Created: 2},
+			wantPrices: map[string]string{"apple": "10.5 USD", "pear": "3 USD"},
+		},
+		{
+			name:       "header and currency column",
+			csv:        "name,price,currency\napple,10.50,eur\npear,3,\n",
+			currency:   "GBP",
+			want:       &models.FetchResult{Created: 2},
+			wantPrices: map[string]string{"apple": "10.5 EUR", "pear": "3 GBP"},
+		},
+		{
+			name:       "changed and unchanged prices",
+			before:     []string{"apple,10.50\npear,3\n"},
+			csv:        "apple,11\npear,3.00\nplum,-0.5\n",
+			want:       &models.FetchResult{Created: 1, Updated: 1},
+			wantPrices: map[string]string{"apple": "11 USD", "pear": "3 USD", "plum": "-0.5 USD"},
+		},
+		{
+			name:         "full snapshot discontinues missing products",
+			before:       []string{"apple,1\npear,2\nplum,3\n"},
+			csv:          "apple,1\n",
+			fullSnapshot: true,
+			want:         &models.FetchResult{Discontinued: 2},
+			wantPrices:   map[string]string{"apple": "1 USD"},
+		},
+		{
+			name:         "full snapshot restores products",
+			before:       []string{"apple,1\npear,2\n", "apple,1\n"},
+			csv:          "apple,1\npear,2\n",
+			fullSnapshot: true,
+			want:         &models.FetchResult{Restored: 1},
+			wantPrices:   map[string]string{"apple": "1 USD", "pear": "2 USD"},
+		},
+		{
+			name:       "invalid price",
+			csv:        "apple,1\npear,two\n",
+			wantCode:   codes.InvalidArgument,
+			wantReason: apierror.ReasonFeedParseError,
+		},
+		{
+			name:       "too many columns",
+			csv:        "apple,1,USD,extra\n",
+			wantCode:   codes.InvalidArgument,
+			wantReason: apierror.ReasonFeedParseError,
+		},
+		{
+			name:       "invalid currency",
+			csv:        "apple,1,dollars\n",
+			wantCode:   codes.InvalidArgument,
+			wantReason: apierror.ReasonFeedParseError,
+		},
+		{
+			name:       "broken quotes",
+			csv:        "\"apple,1\n",
+			wantCode:   codes.InvalidArgument,
+			wantReason: apierror.ReasonFeedParseError,
+		},
+		{
+			name:       "file not found",
+			status:     http.StatusNotFound,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: apierror.ReasonFeedUnreachable,
+		},
+		{
+			name:       "server of file fails",
+			status:     http.StatusBadGateway,
+			wantCode:   codes.Unavailable,
+			wantReason: apierror.ReasonFeedUnreachable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			uc, repos := newTestUseCases(t)
+			feed := newFeed(t)
+
+			for _, csv := range tt.before {
+				feed.serve(http.StatusOK, csv)
+				_, err := uc.ProductsUC.Fetch(ctx, &models.FetchOptions{URL: feed.url(), FullSnapshot: tt.fullSnapshot})
+				require.NoError(t, err)
+			}
+
+			feed.serve(tt.status, tt.csv)
+			result, err := uc.ProductsUC.Fetch(ctx, &models.FetchOptions{
+				URL:          feed.url(),
+				Currency:     tt.currency,
+				FullSnapshot: tt.fullSnapshot,
+			})
+			if tt.wantCode != codes.OK {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantCode, status.Code(err), "error: %v", err)
+				assert.Equal(t, tt.wantReason, reason(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result)
+			assert.Equal(t, tt.wantPrices, prices(t, repos))
+		})
+	}
+}
+
+func TestFetchUnreachable(t *testing.T) {
+	uc, _ := newTestUseCases(t)
+	feed := newFeed(t)
+	url := feed.url()
+	feed.server.Close()
+
+	_, err := uc.ProductsUC.Fetch(context.Background(), &models.FetchOptions{URL: url})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, apierror.ReasonFeedUnreachable, reason(err))
+}
+
+func TestFetchSavesJob(t *testing.T) {
+	ctx := context.Background()
+	uc, repos := newTestUseCases(t)
+	feed := newFeed(t)
+
+	feed.serve(http.StatusOK, "apple,1\n")
+	_, err := uc.ProductsUC.Fetch(ctx, &models.FetchOptions{URL: feed.url(), Source: "prices"})
+	require.NoError(t, err)
+	feed.serve(http.StatusOK, "apple,x\n")
+	_, err = uc.ProductsUC.Fetch(ctx, &models.FetchOptions{URL: feed.url(), Source: "prices"})
+	require.Error(t, err)
+
+	product, err := repos.Products.Get(ctx, "apple")
+	require.NoError(t, err)
+	require.Len(t, product.Sources, 1)
+	assert.Equal(t, "prices", product.Sources[0].Source)
+	assert.Equal(t, "prices", product.PriceSource)
+}
+
+// feed - HTTP server of one CSV file which can be replaced between fetches
+type feed struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	status int
+	csv    string
+}
+
+func newFeed(t *testing.T) *feed {
+	f := &feed{status: http.StatusOK}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(f.status)
+		_, _ = w.Write([]byte(f.csv))
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// serve - take HTTP status (0 - 200) and content of the file for next fetches
+func (f *feed) serve(status int, csv string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	f.status, f.csv = status, csv
+}
+
+func (f *feed) url() string {
+	return f.server.URL + "/products.csv"
+}
+
+// newTestUseCases - return use cases on empty memory storage with default config
+func newTestUseCases(t *testing.T) (*UseCases, *repository.Repository) {
+	t.Helper()
+
+	cfg, err := configs.NewConfig()
+	require.NoError(t, err)
+	cfg.Storage.Driver = "memory"
+	cfg.Currency.Default = "USD"
+
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	entry := logrus.NewEntry(log)
+
+	repos, err := repository.Open(cfg, entry)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repos.Close() })
+	return NewUseCases(repos, cfg, entry), repos
+}
+
+// prices - return prices of active products: name -> price and currency
+func prices(t *testing.T, repos *repository.Repository) map[string]string {
+	t.Helper()
+
+	products, err := repos.Products.List(context.Background(), &models.ListOptions{})
+	require.NoError(t, err)
+	result := make(map[string]string, len(products))
+	for _, p := range products {
+		result[p.Name] = p.Price.String() + " " + p.Currency
+	}
+	return result
+}
+
+// reason - return reason of ErrorInfo of gRPC error or empty string
+func reason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"context"
+	"github.com/ArturChopikian/grpc-server/internal/models"
+	"github.com/ArturChopikian/grpc-server/internal/repository"
+)
+
+// ratesUC - define business logic for exchange rates
+type ratesUC struct {
+	ratesRepos repository.RatesReposInterface
+}
+
+// List - return all exchange rates
+func (uc *ratesUC) List(ctx context.Context) (models.ExchangeRates, error) {
+	return uc.ratesRepos.List(ctx)
+}
+
+// Set - take rates and add them or replace all rates if replace is true
+// return all rates after change or ReadOnlyRatesError if rates are loaded from file
+func (uc *ratesUC) Set(ctx context.Context, rates models.ExchangeRates, replace bool) (models.ExchangeRates, error) {
+	if err := uc.ratesRepos.Set(ctx, rates, replace); err != nil {
+		return nil, err
+	}
+	return uc.ratesRepos.List(ctx)
+}
+
+// newRatesUC - return pointer of ratesUC
+func newRatesUC(repos repository.RatesReposInterface) *ratesUC {
+	return &ratesUC{ratesRepos: repos}
+}
@@ -2,19 +2,41 @@ package usecase
 
 import (
 	"context"
+	"github.com/ArturChopikian/grpc-server/configs"
 	"github.com/ArturChopikian/grpc-server/internal/models"
 	"github.com/ArturChopikian/grpc-server/internal/repository"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ProductsUCInterface interface {
-	Fetch(ctx context.Context, url string) error
-	List(ctx context.Context, orderBy map[string]int32, pageSize int32, pageNumber int32) ([]*models.Product, error)
+	Fetch(ctx context.Context, opts *models.FetchOptions) (*models.FetchResult, error)
+	Interrupt(ctx context.Context) error
+	List(ctx context.Context, opts *models.ListOptions) ([]*models.Product, error)
+	Stream(ctx context.Context, opts *models.ListOptions, fn func(p *models.Product) error) error
+	GetById(ctx context.Context, id primitive.ObjectID) (*models.Product, error)
+	GetByName(ctx context.Context, name string) (*models.Product, error)
+	BatchGet(ctx context.Context, ids []primitive.ObjectID, names []string) ([]*models.Product, error)
+	Create(ctx context.Context, name string, price models.Decimal, currency string) (*models.Product, error)
+	Update(ctx context.Context, id primitive.ObjectID, name *string, price *models.Decimal, currency, source string) (*models.Product, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	Restore(ctx context.Context, id primitive.ObjectID) (*models.Product, error)
+	PriceHistory(product *models.Product, source string) []*models.PriceChange
+}
+
+type RatesUCInterface interface {
+	List(ctx context.Context) (models.ExchangeRates, error)
+	Set(ctx context.Context, rates models.ExchangeRates, replace bool) (models.ExchangeRates, error)
 }
 
 type UseCases struct {
 	ProductsUC ProductsUCInterface
+	RatesUC    RatesUCInterface
 }
 
-func NewUseCases(repos *repository.Repository) *UseCases {
-	return &UseCases{ProductsUC: newProductUC(repos.Products)}
+func NewUseCases(repos *repository.Repository, cfg *configs.Config, log *logrus.Entry) *UseCases {
+	return &UseCases{
+		ProductsUC: newProductUC(repos.Products, repos.Rates, repos.Jobs, cfg, log),
+		RatesUC:    newRatesUC(repos.Rates),
+	}
 }